@@ -0,0 +1,462 @@
+package main
+
+import (
+  "bufio"
+  "context"
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  net_url "net/url"
+  "os"
+  "strings"
+  "time"
+  "pablotron.org/census-geocoder/geocoder"
+)
+
+// exit codes
+const (
+  exitOk = 0 // all inputs matched
+  exitError = 1 // error
+  exitNoMatch = 2 // no inputs matched
+  exitPartial = 3 // some inputs matched
+)
+
+// Command options.
+type options struct {
+  benchmark string // benchmark ID
+  vintage string // vintage ID
+  layers string // comma-separated geography layers
+  url string // base API URL
+  format string // output format
+  columns string // comma-separated output columns
+  cacheDir string // response cache directory
+  cacheTtl time.Duration // response cache TTL
+  attempts int // maximum number of attempts per request
+  rate float64 // maximum requests per second
+}
+
+// Command context.
+type command struct {
+  ctx context.Context // context
+  opts options // command options
+  args []string // positional arguments
+  stdin io.Reader // standard input
+  stdout io.Writer // standard output
+  stderr io.Writer // standard error
+}
+
+// Command handler.
+type handler func(cmd command) (int, error)
+
+// available commands
+var commands = []struct {
+  name string // command name
+  args string // argument summary
+  text string // description
+  fn handler // handler
+} {
+  { "benchmarks", "", "list available benchmarks", benchmarksCommand },
+  { "vintages", "<benchmark>", "list vintages for benchmark", vintagesCommand },
+  { "locate", "[address...]", "geocode addresses", locateCommand },
+  { "geographies", "[address...]", "geocode addresses and get geography layers", geographiesCommand },
+  { "reverse", "[x,y...]", "get geography layers for coordinates", reverseCommand },
+  { "batch", "[file.csv]", "batch geocode addresses from CSV file", batchCommand },
+}
+
+// Print usage to writer.
+func usage(w io.Writer) {
+  fmt.Fprintf(w, "Usage: %s <command> [flags] [args...]\n\nCommands:\n", os.Args[0])
+  for _, c := range(commands) {
+    fmt.Fprintf(w, "  %-12s %-14s %s\n", c.name, c.args, c.text)
+  }
+  fmt.Fprintf(w, "\nRun \"%s <command> -h\" for command flags.\n", os.Args[0])
+}
+
+// Run command with given arguments, then return exit code.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+  if len(args) < 1 {
+    usage(stderr)
+    return exitError
+  }
+
+  // find command
+  var fn handler
+  for _, c := range(commands) {
+    if c.name == args[0] {
+      fn = c.fn
+    }
+  }
+
+  if fn == nil {
+    if args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+      fmt.Fprintf(stderr, "unknown command: %s\n", args[0])
+    }
+    usage(stderr)
+    return exitError
+  }
+
+  // parse flags
+  var opts options
+  fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+  fs.SetOutput(stderr)
+  fs.StringVar(&opts.benchmark, "benchmark", geocoder.DefaultBenchmark, "benchmark ID or name")
+  fs.StringVar(&opts.vintage, "vintage", "", "vintage ID or name (default \"" + geocoder.DefaultVintage + "\" for geographies and reverse)")
+  fs.StringVar(&opts.layers, "layers", "", "comma-separated geography layer IDs or names, or \"all\"")
+  fs.StringVar(&opts.url, "url", geocoder.DefaultUrl.String(), "base API URL")
+  fs.StringVar(&opts.format, "format", string(geocoder.FormatTable), "output format (json, ndjson, csv, tsv, table, geojson, kml, or gpx)")
+  fs.StringVar(&opts.columns, "columns", "", "comma-separated output columns")
+  fs.StringVar(&opts.cacheDir, "cache", "", "response cache directory (disabled if empty)")
+  fs.IntVar(&opts.attempts, "attempts", geocoder.DefaultRetryPolicy.MaxAttempts, "maximum number of attempts per request")
+  fs.Float64Var(&opts.rate, "rate", geocoder.DefaultRateLimit, "maximum requests per second (0 for no limit)")
+  fs.DurationVar(&opts.cacheTtl, "cache-ttl", 24 * time.Hour, "response cache TTL (0 for no expiration)")
+  if err := fs.Parse(args[1:]); err != nil {
+    return exitError
+  }
+
+  // run command
+  code, err := fn(command {
+    ctx: ctx,
+    opts: opts,
+    args: fs.Args(),
+    stdin: stdin,
+    stdout: stdout,
+    stderr: stderr,
+  })
+  if err != nil {
+    fmt.Fprintf(stderr, "%s: %s\n", args[0], err)
+    return exitError
+  }
+
+  return code
+}
+
+// Create client from command options.
+func (cmd command) client() (geocoder.Client, error) {
+  url, err := net_url.Parse(cmd.opts.url)
+  if err != nil {
+    return geocoder.Client{}, err
+  }
+
+  // retry transient failures
+  retry := geocoder.DefaultRetryPolicy
+  retry.MaxAttempts = cmd.opts.attempts
+
+  opts := []geocoder.Option {
+    geocoder.WithBaseURL(url),
+    geocoder.WithDefaultBenchmark(cmd.opts.benchmark),
+    geocoder.WithDefaultVintage(cmd.opts.vintage),
+    geocoder.WithRetryPolicy(retry),
+
+    // limit request rate and concurrent batch uploads
+    geocoder.WithLimiter(geocoder.NewLimiter(cmd.opts.rate, geocoder.DefaultRateBurst, geocoder.DefaultMaxUploads)),
+  }
+
+  // set layers
+  if cmd.opts.layers != "" {
+    opts = append(opts, geocoder.WithLayers(strings.Split(cmd.opts.layers, ",")...))
+  }
+
+  // set cache
+  if cmd.opts.cacheDir != "" {
+    cache, err := geocoder.NewCache(cmd.opts.cacheDir, cmd.opts.cacheTtl)
+    if err != nil {
+      return geocoder.Client{}, err
+    }
+    opts = append(opts, geocoder.WithCache(cache))
+  }
+
+  // create client
+  return geocoder.NewClient(opts...), nil
+}
+
+// Get output format from command options.
+func (cmd command) format() (geocoder.Format, error) {
+  return geocoder.ParseFormat(cmd.opts.format)
+}
+
+// Get output columns from command options, or the given default
+// columns if no columns were given.
+//
+// The default columns are only used for formats other than JSON,
+// NDJSON, and GeoJSON, which contain the full results by default.
+func (cmd command) columns(format geocoder.Format, defaults []string) []string {
+  switch {
+  case cmd.opts.columns != "":
+    return strings.Split(cmd.opts.columns, ",")
+  case format == geocoder.FormatJSON || format == geocoder.FormatNDJSON || format == geocoder.FormatGeoJSON:
+    return nil
+  default:
+    return defaults
+  }
+}
+
+// Get inputs from positional arguments, or from non-empty lines of
+// standard input if there are no positional arguments.
+func (cmd command) inputs() ([]string, error) {
+  if len(cmd.args) > 0 {
+    return cmd.args, nil
+  }
+
+  // read lines from standard input
+  var r []string
+  scanner := bufio.NewScanner(cmd.stdin)
+  for scanner.Scan() {
+    if line := strings.TrimSpace(scanner.Text()); line != "" {
+      r = append(r, line)
+    }
+  }
+
+  return r, scanner.Err()
+}
+
+// Get exit code from number of matched inputs and total number of
+// inputs.
+func matchCode(matched, total int) int {
+  switch {
+  case matched == total:
+    return exitOk
+  case matched == 0:
+    return exitNoMatch
+  default:
+    return exitPartial
+  }
+}
+
+// List available benchmarks.
+func benchmarksCommand(cmd command) (int, error) {
+  c, err := cmd.client()
+  if err != nil {
+    return exitError, err
+  }
+
+  // get output format
+  format, err := cmd.format()
+  if err != nil {
+    return exitError, err
+  }
+
+  // get benchmarks
+  benchmarks, err := c.BenchmarksContext(cmd.ctx)
+  if err != nil {
+    return exitError, err
+  }
+
+  // write benchmarks
+  if err := geocoder.WriteBenchmarks(cmd.stdout, format, cmd.columns(format, geocoder.DefaultBenchmarkColumns), benchmarks); err != nil {
+    return exitError, err
+  }
+
+  return exitOk, nil
+}
+
+// List vintages for benchmark.
+func vintagesCommand(cmd command) (int, error) {
+  benchmark := cmd.opts.benchmark
+  if len(cmd.args) > 0 {
+    benchmark = cmd.args[0]
+  }
+
+  c, err := cmd.client()
+  if err != nil {
+    return exitError, err
+  }
+
+  // get output format
+  format, err := cmd.format()
+  if err != nil {
+    return exitError, err
+  }
+
+  // get vintages
+  vintages, err := c.VintagesContext(cmd.ctx, benchmark)
+  if err != nil {
+    return exitError, err
+  }
+
+  // write vintages
+  if err := geocoder.WriteVintages(cmd.stdout, format, cmd.columns(format, geocoder.DefaultVintageColumns), vintages); err != nil {
+    return exitError, err
+  }
+
+  return exitOk, nil
+}
+
+// default columns for geographies and reverse commands
+var geographyColumns = append(append([]string{}, geocoder.DefaultMatchColumns...), "state_fips", "county_fips", "tract", "block")
+
+// Geocode addresses with given lookup function and write matches.
+func matchCommand(cmd command, defaultColumns []string, lookup func(geocoder.Client, string) ([]geocoder.Match, error)) (int, error) {
+  c, err := cmd.client()
+  if err != nil {
+    return exitError, err
+  }
+
+  // get output format
+  format, err := cmd.format()
+  if err != nil {
+    return exitError, err
+  }
+
+  // get addresses
+  addresses, err := cmd.inputs()
+  if err != nil {
+    return exitError, err
+  }
+
+  // geocode addresses
+  matched := 0
+  results := []geocoder.Match{}
+  for _, address := range(addresses) {
+    matches, err := lookup(c, address)
+    if err != nil {
+      return exitError, err
+    }
+
+    if len(matches) > 0 {
+      matched++
+    }
+
+    results = append(results, matches...)
+  }
+
+  // write matches
+  if err := geocoder.WriteMatches(cmd.stdout, format, cmd.columns(format, defaultColumns), results); err != nil {
+    return exitError, err
+  }
+
+  return matchCode(matched, len(addresses)), nil
+}
+
+// Geocode addresses.
+func locateCommand(cmd command) (int, error) {
+  return matchCommand(cmd, geocoder.DefaultMatchColumns, func(c geocoder.Client, address string) ([]geocoder.Match, error) {
+    return c.LocationsFromBenchmarkContext(cmd.ctx, address, cmd.opts.benchmark)
+  })
+}
+
+// Geocode addresses and get geography layers.
+func geographiesCommand(cmd command) (int, error) {
+  return matchCommand(cmd, geographyColumns, func(c geocoder.Client, address string) ([]geocoder.Match, error) {
+    return c.GeographiesContext(cmd.ctx, address, cmd.opts.benchmark, cmd.opts.vintage)
+  })
+}
+
+// Get geography layers for coordinates.
+//
+// Each result is written as a match with the input coordinates and
+// geography layers.
+func reverseCommand(cmd command) (int, error) {
+  return matchCommand(cmd, []string { "x", "y", "state_fips", "county_fips", "tract", "block", "block_geoid" }, func(c geocoder.Client, input string) ([]geocoder.Match, error) {
+    coords, err := geocoder.NewCoordinates(input)
+    if err != nil {
+      return nil, err
+    }
+
+    // get geographies
+    layers, err := c.GeographiesFromCoordinatesContext(cmd.ctx, coords, cmd.opts.benchmark, cmd.opts.vintage)
+    if err != nil || len(layers) == 0 {
+      return nil, err
+    }
+
+    return []geocoder.Match {{ Coordinates: coords, Geographies: layers }}, nil
+  })
+}
+
+// Batch geocode addresses from CSV file.
+func batchCommand(cmd command) (int, error) {
+  c, err := cmd.client()
+  if err != nil {
+    return exitError, err
+  }
+
+  // get output format
+  format, err := cmd.format()
+  if err != nil {
+    return exitError, err
+  }
+
+  // open input
+  var in io.Reader = cmd.stdin
+  if len(cmd.args) > 0 {
+    f, err := os.Open(cmd.args[0])
+    if err != nil {
+      return exitError, err
+    }
+    defer f.Close()
+    in = f
+  }
+
+  // stream input rows, geocoding one upload at a time, so only one
+  // upload of input rows is held in memory
+  br := geocoder.NewBatchInputReader(in)
+  br.SkipHeader = true
+  numRows := 0
+  results := []geocoder.BatchOutputRow{}
+  for {
+    // read next group of rows
+    rows, err := readBatchRows(br, geocoder.MaxBatchSize)
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      return exitError, err
+    }
+    numRows += len(rows)
+
+    // batch geocode rows; get geographies if a vintage was given
+    var found []geocoder.BatchOutputRow
+    if cmd.opts.vintage != "" {
+      found, err = c.BatchGeographiesContext(cmd.ctx, rows, cmd.opts.benchmark, cmd.opts.vintage)
+    } else {
+      found, err = c.BatchLocationsFromBenchmarkContext(cmd.ctx, rows, cmd.opts.benchmark)
+    }
+
+    // report mismatched rows as a warning
+    var reportErr *geocoder.BatchReportError
+    if errors.As(err, &reportErr) {
+      fmt.Fprintf(cmd.stderr, "warning: %s\n", reportErr)
+    } else if err != nil {
+      return exitError, err
+    }
+
+    results = append(results, found...)
+  }
+
+  // write results
+  if err := geocoder.WriteBatchOutputRows(cmd.stdout, format, cmd.columns(format, geocoder.DefaultBatchColumns), results); err != nil {
+    return exitError, err
+  }
+
+  // count matches
+  matched := 0
+  for _, row := range(results) {
+    if row.Match {
+      matched++
+    }
+  }
+
+  return matchCode(matched, numRows), nil
+}
+
+// Read at most n rows from batch input reader.
+//
+// Returns [io.EOF] if there are no more rows.
+func readBatchRows(br geocoder.BatchInputReader, n int) ([]geocoder.BatchInputRow, error) {
+  var r []geocoder.BatchInputRow
+  for len(r) < n {
+    row, err := br.Read()
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      return nil, err
+    }
+
+    r = append(r, row)
+  }
+
+  if len(r) == 0 {
+    return nil, io.EOF
+  }
+
+  return r, nil
+}
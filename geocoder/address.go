@@ -0,0 +1,43 @@
+package geocoder
+
+// Structured street address.
+//
+// Used by [Client.AddressLocations] and [Client.AddressGeographies] to
+// send street, city, state, and zip code as separate parameters rather
+// than a single one-line address.
+type Address struct {
+  // street address (required)
+  Street string `json:"street"`
+
+  // city
+  City string `json:"city"`
+
+  // state
+  State string `json:"state"`
+
+  // zip code
+  Zip string `json:"zip"`
+}
+
+// Convert address to map of API query parameters.
+//
+// Empty fields are omitted.
+func (a Address) args() map[string]string {
+  r := map[string]string {
+    "street": a.Street,
+  }
+
+  // add optional fields
+  for k, v := range(map[string]string {
+    "city": a.City,
+    "state": a.State,
+    "zip": a.Zip,
+  }) {
+    if v != "" {
+      r[k] = v
+    }
+  }
+
+  // return result
+  return r
+}
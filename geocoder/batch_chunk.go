@@ -0,0 +1,279 @@
+package geocoder
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "sync"
+)
+
+// Maximum number of rows accepted by the Census batch geocoder in a
+// single upload.
+const MaxBatchSize = 10000
+
+// Error returned when uploading a chunk of a batch fails.
+type BatchChunkError struct {
+  // chunk index (zero-based)
+  Chunk int
+
+  // IDs of input rows in chunk
+  Ids []string
+
+  // underlying error
+  Err error
+}
+
+// Get error message.
+func (e *BatchChunkError) Error() string {
+  switch len(e.Ids) {
+  case 0:
+    return fmt.Sprintf("batch chunk %d: %s", e.Chunk, e.Err)
+  case 1:
+    return fmt.Sprintf("batch chunk %d (id %s): %s", e.Chunk, e.Ids[0], e.Err)
+  default:
+    return fmt.Sprintf("batch chunk %d (%d rows, ids %s to %s): %s", e.Chunk, len(e.Ids), e.Ids[0], e.Ids[len(e.Ids) - 1], e.Err)
+  }
+}
+
+// Get underlying error.
+func (e *BatchChunkError) Unwrap() error {
+  return e.Err
+}
+
+// Split batch input rows into chunks of at most the given size.
+func chunkBatchInputRows(rows []BatchInputRow, size int) [][]BatchInputRow {
+  r := make([][]BatchInputRow, 0, (len(rows) + size - 1) / size)
+  for len(rows) > size {
+    r = append(r, rows[:size])
+    rows = rows[size:]
+  }
+
+  // append final chunk
+  if len(rows) > 0 || len(r) == 0 {
+    r = append(r, rows)
+  }
+
+  // return result
+  return r
+}
+
+// Get batch chunk size for client.
+func (c Client) batchSize() int {
+  if c.BatchSize <= 0 || c.BatchSize > MaxBatchSize {
+    return MaxBatchSize
+  }
+
+  return c.BatchSize
+}
+
+// Get number of concurrent batch uploads for client.
+func (c Client) batchConcurrency() int {
+  if c.BatchConcurrency <= 0 {
+    return 1
+  }
+
+  return c.BatchConcurrency
+}
+
+// Batch geocode input rows with the given send function, using cached
+// rows from the given cache, then return results in input order.
+//
+// Only uncached rows are passed to the send function, and the rows it
+// returns are added to the cache.  If the cache is nil, then every row
+// is sent.  Cache keys are built from the endpoint, the fields, and the
+// address of each row.
+//
+// If the send function returns a [BatchReportError], then the rows it
+// found are still cached and merged with the cached rows.  If it
+// returns a [BatchChunkError], then the rows of the successful chunks
+// are cached and the merged rows are returned in input order along
+// with the chunk error.  Other send errors are returned as-is.  If the merged results do not match the
+// input rows, then the reconciled results are returned along with a
+// [BatchReportError] which also lists the duplicate and unexpected IDs
+// reported by the send function.
+func cachedBatch(cache *Cache, rows []BatchInputRow, endpoint string, fields map[string]string, send func([]BatchInputRow) ([]BatchOutputRow, error)) ([]BatchOutputRow, error) {
+  // get cached rows
+  var merged []BatchOutputRow
+  uncached := rows
+  keys := make(map[string]string)
+  if cache != nil {
+    uncached = nil
+    for _, row := range(rows) {
+      key := batchCacheKey(endpoint, fields, row)
+
+      // decode cached row
+      if val, ok := cache.Get(key); ok {
+        var out BatchOutputRow
+        if err := json.Unmarshal(val, &out); err == nil {
+          out.Id = row.Id
+          merged = append(merged, out)
+          continue
+        }
+      }
+
+      uncached = append(uncached, row)
+      keys[row.Id] = key
+    }
+  }
+
+  // send uncached rows
+  var sendReport BatchReport
+  var chunkErr *BatchChunkError
+  if len(uncached) > 0 || len(rows) == 0 {
+    sent, err := send(uncached)
+    if err != nil {
+      var reportErr *BatchReportError
+      if errors.As(err, &reportErr) {
+        sendReport = reportErr.Report
+      } else if !errors.As(err, &chunkErr) {
+        return []BatchOutputRow{}, err
+      }
+    }
+
+    // cache sent rows (errors are ignored because the cache is only an
+    // optimization)
+    for _, row := range(sent) {
+      if key, ok := keys[row.Id]; ok {
+        if val, err := json.Marshal(row); err == nil {
+          _ = cache.Set(key, val)
+        }
+        delete(keys, row.Id)
+      }
+    }
+
+    merged = append(merged, sent...)
+  }
+
+  // reorder results to match input, check for differences
+  r, report := ReconcileBatchOutput(rows, merged)
+  if chunkErr != nil {
+    // rows of failed chunks are missing, so return partial results
+    // with chunk error
+    return r, chunkErr
+  }
+  report.Unexpected = append(report.Unexpected, sendReport.Unexpected...)
+  for _, id := range(sendReport.Duplicates) {
+    if !slicesContain(report.Duplicates, id) {
+      report.Duplicates = append(report.Duplicates, id)
+    }
+  }
+  if !report.Ok() {
+    return r, &BatchReportError { report }
+  }
+
+  return r, nil
+}
+
+// Does the slice contain the string?
+func slicesContain(vals []string, s string) bool {
+  for _, v := range(vals) {
+    if v == s {
+      return true
+    }
+  }
+  return false
+}
+
+// Batch geocode input addresses and return results in input order.
+//
+// If the client has a [Client.Cache], then cached rows are used and
+// only the uncached rows are uploaded.  The uploaded results are added
+// to the cache.
+//
+// If a chunk fails, then the remaining uploads are cancelled and the
+// results of the chunks which succeeded are returned in input order
+// along with a [BatchChunkError].  If the merged results do not match
+// the input rows, then the reconciled results are returned along with
+// a [BatchReportError].
+func (c Client) batchUpload(ctx context.Context, rows []BatchInputRow, returnType string, fields map[string]string) ([]BatchOutputRow, error) {
+  endpoint := c.Url.JoinPath(returnType, "addressbatch").String()
+  return cachedBatch(c.Cache, rows, endpoint, fields, func(uncached []BatchInputRow) ([]BatchOutputRow, error) {
+    return c.uploadChunks(ctx, uncached, returnType, fields)
+  })
+}
+
+// Split input addresses into chunks, upload chunks to batch geocoder
+// with bounded concurrency, then return merged results.
+//
+// If a chunk fails, then the remaining uploads are cancelled and the
+// merged results of the chunks which succeeded are returned along with
+// a [BatchChunkError].
+func (c Client) uploadChunks(ctx context.Context, rows []BatchInputRow, returnType string, fields map[string]string) ([]BatchOutputRow, error) {
+  chunks := chunkBatchInputRows(rows, c.batchSize())
+
+  // cancel remaining uploads on first error
+  ctx, cancel := context.WithCancel(ctx)
+  defer cancel()
+
+  results := make([][]BatchOutputRow, len(chunks))
+  errs := make([]error, len(chunks))
+
+  // upload chunks
+  var wg sync.WaitGroup
+  sem := make(chan struct{}, c.batchConcurrency())
+  for i := range(chunks) {
+    // wait for upload slot
+    select {
+    case sem <- struct{}{}:
+    case <-ctx.Done():
+      errs[i] = ctx.Err()
+    }
+
+    if errs[i] != nil {
+      break
+    }
+
+    wg.Add(1)
+    go func(i int) {
+      defer wg.Done()
+      defer func() { <-sem }()
+
+      results[i], errs[i] = c.batchUploadChunk(ctx, chunks[i], returnType, fields)
+      if errs[i] != nil {
+        cancel()
+      }
+    }(i)
+  }
+
+  // wait for uploads to finish
+  wg.Wait()
+
+  // find failed chunk, preferring the original failure over the
+  // cancellation errors it caused in other chunks
+  failed := -1
+  for i, err := range(errs) {
+    if err != nil && (failed < 0 || (errors.Is(errs[failed], context.Canceled) && !errors.Is(err, context.Canceled))) {
+      failed = i
+    }
+  }
+
+  // merge results of successful chunks
+  size := 0
+  for _, result := range(results) {
+    size += len(result)
+  }
+
+  merged := make([]BatchOutputRow, 0, size)
+  for _, result := range(results) {
+    merged = append(merged, result...)
+  }
+
+  // check for error
+  if failed >= 0 {
+    return merged, &BatchChunkError { failed, chunkIds(chunks[failed]), errs[failed] }
+  }
+
+  // return result
+  return merged, nil
+}
+
+// Get IDs of batch input rows.
+func chunkIds(rows []BatchInputRow) []string {
+  r := make([]string, len(rows))
+  for i, row := range(rows) {
+    r[i] = row.Id
+  }
+
+  return r
+}
@@ -0,0 +1,231 @@
+package geocoder
+
+import (
+  "errors"
+  "fmt"
+  "net/http"
+  "net/http/httptest"
+  net_url "net/url"
+  "reflect"
+  "strings"
+  "sync"
+  "sync/atomic"
+  "testing"
+  "time"
+)
+
+// Batch server which echoes uploaded rows as unmatched results.
+type echoBatchServer struct {
+  *httptest.Server
+
+  mu sync.Mutex // mutex for chunks
+  chunks [][]string // IDs of received chunks
+
+  inFlight int32 // number of in-flight requests
+  maxInFlight int32 // maximum number of in-flight requests
+}
+
+// Start echo batch server.
+//
+// If failId is non-empty, then the server responds with a 500 status
+// to uploads which contain a row with the given ID.
+func newEchoBatchServer(t *testing.T, failId string) (*echoBatchServer, Client) {
+  s := &echoBatchServer{}
+  s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    // track concurrent requests
+    n := atomic.AddInt32(&s.inFlight, 1)
+    defer atomic.AddInt32(&s.inFlight, -1)
+    for {
+      max := atomic.LoadInt32(&s.maxInFlight)
+      if n <= max || atomic.CompareAndSwapInt32(&s.maxInFlight, max, n) {
+        break
+      }
+    }
+
+    // read uploaded rows
+    f, _, err := r.FormFile("addressFile")
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    defer f.Close()
+
+    rows, err := NewBatchInputReader(f).ReadAll()
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+
+    // record chunk IDs
+    ids := chunkIds(rows)
+    s.mu.Lock()
+    s.chunks = append(s.chunks, ids)
+    s.mu.Unlock()
+
+    // give concurrent uploads a chance to overlap
+    time.Sleep(10 * time.Millisecond)
+
+    // check for failure
+    for _, id := range(ids) {
+      if id == failId {
+        http.Error(w, "chunk failed", http.StatusInternalServerError)
+        return
+      }
+    }
+
+    // write unmatched result rows
+    for _, row := range(rows) {
+      fmt.Fprintf(w, "%q,%q,\"No_Match\"\n", row.Id, row.Address)
+    }
+  }))
+
+  // parse server URL
+  url, err := net_url.Parse(s.URL)
+  if err != nil {
+    s.Close()
+    t.Fatal(err)
+  }
+
+  // return server and client
+  return s, NewClient(WithBaseURL(url))
+}
+
+// Generate batch input rows with sequential IDs.
+func genBatchInputRows(n int) []BatchInputRow {
+  r := make([]BatchInputRow, n)
+  for i := range(r) {
+    r[i] = BatchInputRow { Id: fmt.Sprint(i), Address: fmt.Sprintf("%d main st", i) }
+  }
+
+  return r
+}
+
+func TestChunkBatchInputRows(t *testing.T) {
+  tests := []struct {
+    n int // number of rows
+    size int // chunk size
+    exp []int // expected chunk sizes
+  } {
+    { 0, 3, []int { 0 } },
+    { 1, 3, []int { 1 } },
+    { 3, 3, []int { 3 } },
+    { 4, 3, []int { 3, 1 } },
+    { 10, 3, []int { 3, 3, 3, 1 } },
+  }
+
+  for _, test := range(tests) {
+    t.Run(fmt.Sprintf("%d/%d", test.n, test.size), func(t *testing.T) {
+      got := []int{}
+      for _, chunk := range(chunkBatchInputRows(genBatchInputRows(test.n), test.size)) {
+        got = append(got, len(chunk))
+      }
+
+      if !reflect.DeepEqual(got, test.exp) {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestClientBatchChunks(t *testing.T) {
+  for _, concurrency := range([]int { 0, 1, 3 }) {
+    t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+      s, c := newEchoBatchServer(t, "")
+      defer s.Close()
+
+      // configure client
+      c.BatchSize = 4
+      c.BatchConcurrency = concurrency
+
+      // send rows
+      rows := genBatchInputRows(18)
+      got, err := c.BatchLocations(rows)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      // check number of uploads
+      if len(s.chunks) != 5 {
+        t.Fatalf("got %d chunks, exp 5", len(s.chunks))
+      }
+
+      // check merged results
+      if len(got) != len(rows) {
+        t.Fatalf("got %d rows, exp %d", len(got), len(rows))
+      }
+      for i, row := range(got) {
+        if row.Id != rows[i].Id || row.Match {
+          t.Fatalf("%d: got %v, exp id %s", i, row, rows[i].Id)
+        }
+      }
+
+      // check concurrency limit
+      exp := int32(concurrency)
+      if exp == 0 {
+        exp = 1
+      }
+      if s.maxInFlight > exp {
+        t.Fatalf("got %d concurrent uploads, exp at most %d", s.maxInFlight, exp)
+      }
+    })
+  }
+}
+
+func TestClientBatchChunkError(t *testing.T) {
+  s, c := newEchoBatchServer(t, "9")
+  defer s.Close()
+
+  // configure client
+  cache, err := NewCache(t.TempDir(), 0)
+  if err != nil {
+    t.Fatal(err)
+  }
+  c.BatchSize = 4
+  c.BatchConcurrency = 2
+  c.Cache = cache
+
+  // send rows, check for error
+  rows, err := c.BatchGeographies(genBatchInputRows(18), "2020", "2020")
+  var got *BatchChunkError
+  if !errors.As(err, &got) {
+    t.Fatalf("got %v, exp *BatchChunkError", err)
+  }
+
+  // check rows of successful chunks (chunks 0 and 1 finish before
+  // chunk 2 fails; later chunks may be cancelled)
+  if len(rows) < 8 {
+    t.Fatalf("got %d rows, exp at least 8", len(rows))
+  }
+  for i, row := range(rows) {
+    if i < 8 && row.Id != fmt.Sprint(i) {
+      t.Fatalf("got row %d id %s, exp %d", i, row.Id, i)
+    }
+    if row.Id == "8" || row.Id == "9" || row.Id == "10" || row.Id == "11" {
+      t.Fatalf("got row from failed chunk: %s", row.Id)
+    }
+  }
+
+  // check that rows of successful chunks were cached
+  numChunks := len(s.chunks)
+  if _, err := c.BatchGeographies(genBatchInputRows(8), "2020", "2020"); err != nil {
+    t.Fatal(err)
+  }
+  if len(s.chunks) != numChunks {
+    t.Fatalf("got %d uploads, exp %d", len(s.chunks), numChunks)
+  }
+
+  // check failed chunk
+  exp := []string { "8", "9", "10", "11" }
+  if got.Chunk != 2 || !reflect.DeepEqual(got.Ids, exp) {
+    t.Fatalf("got chunk %d %v, exp chunk 2 %v", got.Chunk, got.Ids, exp)
+  }
+
+  // check underlying error and message
+  var apiErr *APIError
+  if !errors.As(err, &apiErr) || apiErr.StatusCode != 500 {
+    t.Fatalf("got %v, exp *APIError", got.Err)
+  }
+  if !strings.Contains(err.Error(), "ids 8 to 11") {
+    t.Fatalf("got %q, exp chunk IDs", err.Error())
+  }
+}
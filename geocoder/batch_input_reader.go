@@ -2,39 +2,270 @@ package geocoder
 
 import (
   "encoding/csv"
+  "errors"
+  "fmt"
   "io"
+  "strings"
+  "unicode/utf8"
 )
 
-// Batch geocode CSV reader
+// Number of columns in a batch input row.
+const batchInputColumns = 5
+
+// Default maximum length of batch input fields, in characters.
+//
+// The Census Bureau does not document a per-field limit for batch
+// input, so this is a sanity check rather than an API limit: it is
+// longer than any valid street address, city, state, or ZIP code, and
+// catches misaligned rows (e.g. a whole record in one quoted field)
+// before they are uploaded.  Use [BatchInputReader.MaxFieldLength] to
+// change or disable it.
+const DefaultMaxFieldLength = 100
+
+var (
+  // Batch input row has an empty ID.
+  ErrMissingId = errors.New("missing id")
+
+  // Batch input row has an empty street address.
+  ErrMissingAddress = errors.New("missing address")
+
+  // Batch input row has the same ID as an earlier row.
+  ErrDuplicateId = errors.New("duplicate id")
+
+  // Batch input row has the wrong number of columns.
+  ErrFieldCount = errors.New("wrong number of columns")
+
+  // Batch input field exceeds maximum length.
+  ErrFieldTooLong = errors.New("field too long")
+)
+
+// batch input column names, in order
+var batchInputFieldNames = [batchInputColumns]string {
+  "Id", "Address", "City", "State", "Zip",
+}
+
+// Invalid batch input row.
+type BatchInputError struct {
+  // line number (1-based)
+  Line int
+
+  // column number (1-based), or 0 if the error applies to the whole
+  // row
+  Column int
+
+  // underlying error (e.g. [ErrMissingAddress])
+  Err error
+}
+
+// Get error message.
+func (e *BatchInputError) Error() string {
+  if e.Column > 0 && e.Column <= batchInputColumns {
+    return fmt.Sprintf("line %d, column %d (%s): %s", e.Line, e.Column, batchInputFieldNames[e.Column - 1], e.Err)
+  } else if e.Column > 0 {
+    return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Err)
+  } else {
+    return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+  }
+}
+
+// Get underlying error.
+func (e *BatchInputError) Unwrap() error {
+  return e.Err
+}
+
+// Batch geocode CSV reader.
+//
+// Copies of a reader share the underlying CSV reader and read state.
 type BatchInputReader struct {
+  // Skip the first row if it looks like a header row (e.g.
+  // "id,address,city,state,zip").
+  SkipHeader bool
+
+  // Skip invalid rows instead of returning an error.  Use
+  // [BatchInputReader.Errors] to get the skipped row errors.
+  Lenient bool
+
+  // Maximum field length, in characters (runes).  If zero, then
+  // [DefaultMaxFieldLength] is used.  If negative, then field lengths
+  // are not checked.
+  MaxFieldLength int
+
+  // Do not check for duplicate IDs.
+  //
+  // Duplicate checks remember every ID read so far, so memory use grows
+  // with the number of rows.  Set this to read very large inputs in
+  // constant memory, and use [ReconcileBatchOutput] to find duplicate
+  // IDs in the results instead.
+  AllowDuplicateIds bool
+
   // CSV reader
   r *csv.Reader
+
+  // read state
+  state *batchInputState
+}
+
+// Batch input reader state.
+type batchInputState struct {
+  numRows int // number of rows read
+  ids map[string]int // map of seen IDs to line numbers (grows with input)
+  errs []*BatchInputError // row errors collected in lenient mode
 }
 
 // Create batch CSV reader.
 func NewBatchInputReader(r io.Reader) BatchInputReader {
   cr := csv.NewReader(r)
   cr.FieldsPerRecord = -1
-  return BatchInputReader { cr }
+  cr.ReuseRecord = true
+  return BatchInputReader {
+    r: cr,
+    state: &batchInputState { ids: make(map[string]int) },
+  }
 }
 
-// Parse all rows in CSV as BatchInputRow items.
+// Returns true if the row looks like a header row.
+func isBatchInputHeader(row []string) bool {
+  if len(row) < 2 {
+    return false
+  }
+
+  id := strings.ToLower(strings.TrimSpace(row[0]))
+  addr := strings.ToLower(strings.TrimSpace(row[1]))
+  return (id == "id" || id == "uid" || id == "unique id" || id == "unique_id") &&
+         (strings.Contains(addr, "address") || strings.Contains(addr, "street"))
+}
+
+// Get maximum field length, or -1 if field lengths are not checked.
+func (me BatchInputReader) maxFieldLength() int {
+  switch {
+  case me.MaxFieldLength == 0:
+    return DefaultMaxFieldLength
+  case me.MaxFieldLength < 0:
+    return -1
+  default:
+    return me.MaxFieldLength
+  }
+}
+
+// Validate CSV row and convert it to BatchInputRow.
+func (me BatchInputReader) parse(row []string) (BatchInputRow, *BatchInputError) {
+  line, _ := me.r.FieldPos(0)
+
+  // check column count
+  if len(row) != batchInputColumns {
+    err := fmt.Errorf("%w: got %d, exp %d", ErrFieldCount, len(row), batchInputColumns)
+    return BatchInputRow{}, &BatchInputError { line, 0, err }
+  }
+
+  // check field lengths
+  if max := me.maxFieldLength(); max >= 0 {
+    for i, val := range(row) {
+      if n := utf8.RuneCountInString(val); n > max {
+        err := fmt.Errorf("%w: %d > %d characters", ErrFieldTooLong, n, max)
+        return BatchInputRow{}, &BatchInputError { line, i + 1, err }
+      }
+    }
+  }
+
+  // check required fields
+  id := strings.TrimSpace(row[0])
+  if id == "" {
+    return BatchInputRow{}, &BatchInputError { line, 1, ErrMissingId }
+  }
+  if strings.TrimSpace(row[1]) == "" {
+    return BatchInputRow{}, &BatchInputError { line, 2, ErrMissingAddress }
+  }
+
+  // check for duplicate ID (IDs which differ only in surrounding
+  // whitespace are duplicates)
+  if !me.AllowDuplicateIds {
+    if prev, ok := me.state.ids[id]; ok {
+      err := fmt.Errorf("%w: %s (first seen on line %d)", ErrDuplicateId, id, prev)
+      return BatchInputRow{}, &BatchInputError { line, 1, err }
+    }
+    me.state.ids[id] = line
+  }
+
+  // return result (with trimmed ID)
+  return BatchInputRow { id, row[1], row[2], row[3], row[4] }, nil
+}
+
+// Parse next valid row in CSV as BatchInputRow.
 //
-// Note: The first row of the CSV file is *not* skipped, so if it
-// contains column headers it should be removed.
-func (me BatchInputReader) ReadAll() ([]BatchInputRow, error) {
-  // read CSV rows
-  rows, err := me.r.ReadAll()
-  if err != nil {
-    return []BatchInputRow{}, err
+// Returns [io.EOF] when there are no more rows.  Invalid rows result
+// in a [BatchInputError], unless the reader is lenient, in which case
+// invalid rows are skipped and collected.
+//
+// Note: The first row of the CSV file is *not* skipped unless
+// [BatchInputReader.SkipHeader] is set.
+func (me BatchInputReader) Read() (BatchInputRow, error) {
+  for {
+    // read CSV row
+    row, err := me.r.Read()
+    if err == io.EOF {
+      return BatchInputRow{}, err
+    } else if err != nil {
+      // convert CSV parse errors to row errors
+      var parseErr *csv.ParseError
+      if !errors.As(err, &parseErr) {
+        return BatchInputRow{}, err
+      }
+
+      me.state.numRows++
+      rowErr := &BatchInputError { parseErr.StartLine, 0, parseErr.Err }
+      if !me.Lenient {
+        return BatchInputRow{}, rowErr
+      }
+
+      me.state.errs = append(me.state.errs, rowErr)
+      continue
+    }
+
+    // skip header row
+    me.state.numRows++
+    if me.state.numRows == 1 && me.SkipHeader && isBatchInputHeader(row) {
+      continue
+    }
+
+    // validate row
+    r, rowErr := me.parse(row)
+    if rowErr != nil {
+      if !me.Lenient {
+        return BatchInputRow{}, rowErr
+      }
+
+      me.state.errs = append(me.state.errs, rowErr)
+      continue
+    }
+
+    // return result
+    return r, nil
   }
+}
+
+// Parse all valid rows in CSV as BatchInputRow items.
+//
+// See [BatchInputReader.Read] for details on validation.
+func (me BatchInputReader) ReadAll() ([]BatchInputRow, error) {
+  r := []BatchInputRow{}
 
-  // populate result
-  r := make([]BatchInputRow, len(rows))
-  for i, row := range(rows) {
-    r[i] = BatchInputRow { row[0], row[1], row[2], row[3], row[4] }
+  // read rows
+  for {
+    row, err := me.Read()
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      return []BatchInputRow{}, err
+    }
+
+    r = append(r, row)
   }
 
   // return result
   return r, nil
 }
+
+// Get invalid row errors skipped by a lenient reader.
+func (me BatchInputReader) Errors() []*BatchInputError {
+  return me.state.errs
+}
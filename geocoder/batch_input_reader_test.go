@@ -0,0 +1,278 @@
+package geocoder
+
+import (
+  "bytes"
+  "encoding/csv"
+  "errors"
+  "io"
+  "mime"
+  "mime/multipart"
+  "os"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+func TestBatchInputReaderRead(t *testing.T) {
+  exp := []BatchInputRow {
+    { "1", "2525 buckelew dr", "falls church", "va", "22046" },
+    { "2", "3444 gallows rd", "annandale", "", "" },
+  }
+
+  // create reader
+  r := NewBatchInputReader(strings.NewReader("1,2525 buckelew dr,falls church,va,22046\n2,3444 gallows rd,annandale,,\n"))
+
+  // read rows one at a time
+  for i, expRow := range(exp) {
+    got, err := r.Read()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, expRow) {
+      t.Fatalf("%d: got %v, exp %v", i, got, expRow)
+    }
+  }
+
+  // check for EOF
+  if _, err := r.Read(); err != io.EOF {
+    t.Fatalf("got %v, exp io.EOF", err)
+  }
+}
+
+func TestBatchInputWriterWrite(t *testing.T) {
+  rows := getBatchInputRows(t)
+
+  // write rows one at a time
+  var buf bytes.Buffer
+  w := NewBatchInputWriter(&buf)
+  for _, row := range(rows) {
+    if err := w.Write(row); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  // flush writes
+  if err := w.Flush(); err != nil {
+    t.Fatal(err)
+  }
+
+  // read rows back
+  got, err := NewBatchInputReader(&buf).ReadAll()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // compare against expected value
+  if !reflect.DeepEqual(got, rows) {
+    t.Fatalf("got %v, exp %v", got, rows)
+  }
+}
+
+// Parse multipart batch request body, then return benchmark field and
+// uploaded rows.
+func readBatchBody(t *testing.T, body io.Reader, contentType string) (string, []BatchInputRow) {
+  // get boundary
+  _, params, err := mime.ParseMediaType(contentType)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  var benchmark string
+  var rows []BatchInputRow
+
+  // read parts
+  mr := multipart.NewReader(body, params["boundary"])
+  for {
+    p, err := mr.NextPart()
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      t.Fatal(err)
+    }
+
+    switch p.FormName() {
+    case "benchmark":
+      data, err := io.ReadAll(p)
+      if err != nil {
+        t.Fatal(err)
+      }
+      benchmark = string(data)
+    case "addressFile":
+      if rows, err = NewBatchInputReader(p).ReadAll(); err != nil {
+        t.Fatal(err)
+      }
+    }
+  }
+
+  // return results
+  return benchmark, rows
+}
+
+func TestNewBatchBody(t *testing.T) {
+  rows := genBatchInputRows(5000)
+
+  // create body, parse multipart form from body
+  body, contentType := newBatchBody(rows, map[string]string { "benchmark": "2020" })
+  defer body.Close()
+  s, got := readBatchBody(t, body, contentType)
+
+  // check fields
+  if s != "2020" {
+    t.Fatalf("got benchmark %q, exp %q", s, "2020")
+  }
+
+  // check rows
+  if !reflect.DeepEqual(got, rows) {
+    t.Fatalf("got %d rows, exp %d rows", len(got), len(rows))
+  }
+}
+
+func TestBatchInputReaderSkipHeader(t *testing.T) {
+  f, err := os.Open("testdata/data/batch-input.csv")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer f.Close()
+
+  // create reader which skips header
+  r := NewBatchInputReader(f)
+  r.SkipHeader = true
+
+  // read rows
+  rows, err := r.ReadAll()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // check IDs
+  got := chunkIds(rows)
+  exp := []string { "2022", "2020", "2010", "2000" }
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestBatchInputReaderInvalid(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    data string // CSV data
+    max int // maximum field length
+    line int // expected line
+    column int // expected column
+    exp error // expected error
+  } {
+    { "too few columns", "1,a,b,c,d\n2,a\n", 0, 2, 0, ErrFieldCount },
+    { "too many columns", "1,a,b,c,d,e\n", 0, 1, 0, ErrFieldCount },
+    { "missing id", "1,a,b,c,d\n,a,b,c,d\n", 0, 2, 1, ErrMissingId },
+    { "missing address", "1, ,b,c,d\n", 0, 1, 2, ErrMissingAddress },
+    { "duplicate id", "1,a,b,c,d\n2,a,b,c,d\n1,a,b,c,d\n", 0, 3, 1, ErrDuplicateId },
+    { "duplicate id with whitespace", "1,a,b,c,d\n 1 ,a,b,c,d\n", 0, 2, 1, ErrDuplicateId },
+    { "field too long", "1,a,b,abcdef,d\n", 5, 1, 4, ErrFieldTooLong },
+    { "default field too long", "1," + strings.Repeat("a", 101) + ",b,c,d\n", 0, 1, 2, ErrFieldTooLong },
+    { "multibyte field too long", "1," + strings.Repeat("é", 101) + ",b,c,d\n", 0, 1, 2, ErrFieldTooLong },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      r := NewBatchInputReader(strings.NewReader(test.data))
+      r.MaxFieldLength = test.max
+
+      // read rows, check for error
+      _, err := r.ReadAll()
+      var got *BatchInputError
+      if !errors.As(err, &got) {
+        t.Fatalf("got %v, exp *BatchInputError", err)
+      }
+
+      // check error
+      if got.Line != test.line || got.Column != test.column || !errors.Is(err, test.exp) {
+        t.Fatalf("got %v (line %d, column %d), exp %v (line %d, column %d)", err, got.Line, got.Column, test.exp, test.line, test.column)
+      }
+    })
+  }
+}
+
+func TestBatchInputReaderLenient(t *testing.T) {
+  data := "id,address,city,state,zip\n" +
+          "1,a,b,c,d\n" +
+          "2\n" +
+          "3,,b,c,d\n" +
+          "1,a,b,c,d\n" +
+          "4,\"a\n" +
+          "5,a,b,c,d\n"
+
+  // create lenient reader
+  r := NewBatchInputReader(strings.NewReader(data))
+  r.SkipHeader = true
+  r.Lenient = true
+
+  // read rows
+  rows, err := r.ReadAll()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // check valid rows
+  if got := chunkIds(rows); !reflect.DeepEqual(got, []string { "1" }) {
+    t.Fatalf("got %v, exp [1]", got)
+  }
+
+  // check collected errors
+  exp := []struct {
+    line int // expected line
+    err error // expected error
+  } {
+    { 3, ErrFieldCount },
+    { 4, ErrMissingAddress },
+    { 5, ErrDuplicateId },
+    { 6, csv.ErrQuote },
+  }
+
+  errs := r.Errors()
+  if len(errs) != len(exp) {
+    t.Fatalf("got %v, exp %d errors", errs, len(exp))
+  }
+  for i, e := range(exp) {
+    if errs[i].Line != e.line || !errors.Is(errs[i], e.err) {
+      t.Fatalf("%d: got %v, exp line %d: %v", i, errs[i], e.line, e.err)
+    }
+  }
+}
+
+func TestBatchInputReaderTrimId(t *testing.T) {
+  // 100 multibyte characters is more than 100 bytes, but still valid
+  addr := strings.Repeat("é", 100)
+  r := NewBatchInputReader(strings.NewReader(" 1 ," + addr + ",b,c,d\n"))
+
+  got, err := r.Read()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  exp := BatchInputRow { "1", addr, "b", "c", "d" }
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestBatchInputReaderAllowDuplicateIds(t *testing.T) {
+  r := NewBatchInputReader(strings.NewReader("1,a,b,c,d\n1,a,b,c,d\n"))
+  r.AllowDuplicateIds = true
+
+  // read rows
+  rows, err := r.ReadAll()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // check IDs
+  if got := chunkIds(rows); !reflect.DeepEqual(got, []string { "1", "1" }) {
+    t.Fatalf("got %v, exp [1 1]", got)
+  }
+
+  // check that seen IDs were not recorded
+  if len(r.state.ids) != 0 {
+    t.Fatalf("got %d seen ids, exp 0", len(r.state.ids))
+  }
+}
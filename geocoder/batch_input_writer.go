@@ -5,24 +5,38 @@ import (
   "io"
 )
 
+// Batch geocode input CSV writer.
 type BatchInputWriter struct {
   w *csv.Writer
 }
 
+// Create batch input CSV writer.
 func NewBatchInputWriter(w io.Writer) BatchInputWriter {
   return BatchInputWriter { csv.NewWriter(w) }
 }
 
+// Write row as CSV.
+//
+// Writes are buffered, so [BatchInputWriter.Flush] must be called to
+// ensure the row has been written to the underlying writer.
+func (me BatchInputWriter) Write(row BatchInputRow) error {
+  return me.w.Write([]string { row.Id, row.Address, row.City, row.State, row.Zip })
+}
+
+// Flush buffered rows to underlying writer.
+func (me BatchInputWriter) Flush() error {
+  me.w.Flush()
+  return me.w.Error()
+}
+
+// Write rows as CSV, then flush writes.
 func (me BatchInputWriter) WriteAll(rows []BatchInputRow) error {
   for _, row := range(rows) {
-    csvRow := []string { row.Id, row.Address, row.City, row.State, row.Zip }
-    if err := me.w.Write(csvRow); err != nil {
+    if err := me.Write(row); err != nil {
       return err
     }
   }
 
   // flush writes
-  me.w.Flush()
-
-  return nil
+  return me.Flush()
 }
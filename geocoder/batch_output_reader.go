@@ -15,25 +15,38 @@ type BatchOutputReader struct {
 func NewBatchOutputReader(r io.Reader) BatchOutputReader {
   cr := csv.NewReader(r)
   cr.FieldsPerRecord = -1
+  cr.ReuseRecord = true
   return BatchOutputReader { cr }
 }
 
-// Parse CSV rows as BatchOutputRow items.
-func (me BatchOutputReader) ReadAll() ([]BatchOutputRow, error) {
-  // read CSV rows
-  rows, err := me.r.ReadAll()
+// Parse next CSV row as BatchOutputRow.
+//
+// Returns [io.EOF] when there are no more rows.
+func (me BatchOutputReader) Read() (BatchOutputRow, error) {
+  // read CSV row
+  row, err := me.r.Read()
   if err != nil {
-    return []BatchOutputRow{}, err
+    return BatchOutputRow{}, err
   }
 
-  // populate result
-  r := make([]BatchOutputRow, len(rows))
-  for i := range(rows) {
-    if outRow, err := NewBatchOutputRow(rows[i]); err != nil {
+  // parse row
+  return NewBatchOutputRow(row)
+}
+
+// Parse CSV rows as BatchOutputRow items.
+func (me BatchOutputReader) ReadAll() ([]BatchOutputRow, error) {
+  r := []BatchOutputRow{}
+
+  // read rows
+  for {
+    row, err := me.Read()
+    if err == io.EOF {
+      break
+    } else if err != nil {
       return []BatchOutputRow{}, err
-    } else {
-      r[i] = outRow
     }
+
+    r = append(r, row)
   }
 
   // return result
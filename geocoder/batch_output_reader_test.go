@@ -0,0 +1,44 @@
+package geocoder
+
+import (
+  "io"
+  "os"
+  "strings"
+  "testing"
+)
+
+func TestBatchOutputReaderRead(t *testing.T) {
+  exp := getBatchOutputRows(t, "testdata/data/batch-output-geographies-2020-2020.csv")
+
+  // open output file
+  f, err := os.Open("testdata/data/batch-output-geographies-2020-2020.csv")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer f.Close()
+
+  // read rows one at a time
+  r := NewBatchOutputReader(f)
+  for i, expRow := range(exp) {
+    got, err := r.Read()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !compareBatchOutputRow(got, expRow) {
+      t.Fatalf("%d: got %v, exp %v", i, got, expRow)
+    }
+  }
+
+  // check for EOF
+  if _, err := r.Read(); err != io.EOF {
+    t.Fatalf("got %v, exp io.EOF", err)
+  }
+}
+
+func TestBatchOutputReaderReadInvalid(t *testing.T) {
+  r := NewBatchOutputReader(strings.NewReader("1,2\n"))
+  if got, err := r.Read(); err == nil {
+    t.Fatalf("got %v, exp error", got)
+  }
+}
@@ -29,16 +29,16 @@ type BatchOutputRow struct {
   TigerLine TigerLine `json:"tigerLine"`
 
   // State ID (only populated if `returntype = geographies`).
-  State string
+  State string `json:"state"`
 
   // County ID (only populated if `returntype = geographies`).
-  County string
+  County string `json:"county"`
 
   // tract (only populated if `returntype = geographies`).
-  Tract string
+  Tract string `json:"tract"`
 
   // block ID (only populated if `returntype = geographies`).
-  Block string
+  Block string `json:"block"`
 }
 
 // Create batch output row from CSV row.
@@ -111,3 +111,23 @@ func compareBatchOutputRow(a, b BatchOutputRow) bool {
          a.Tract == b.Tract &&
          a.Block == b.Block
 }
+
+// Get map of layer name to GEOIDs built from the state, county, tract,
+// and block IDs.
+func (r BatchOutputRow) geoIds() map[string][]string {
+  ids := map[string][]string {}
+  if r.State != "" {
+    ids["States"] = []string { r.State }
+    if r.County != "" {
+      ids["Counties"] = []string { r.State + r.County }
+      if r.Tract != "" {
+        ids["Census Tracts"] = []string { r.State + r.County + r.Tract }
+        if r.Block != "" {
+          ids["Census Blocks"] = []string { r.State + r.County + r.Tract + r.Block }
+        }
+      }
+    }
+  }
+
+  return ids
+}
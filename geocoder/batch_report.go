@@ -0,0 +1,121 @@
+package geocoder
+
+import (
+  "fmt"
+  "strings"
+)
+
+// Differences between batch input rows and batch output rows.
+//
+// Returned by [ReconcileBatchOutput] and as part of a
+// [BatchReportError].
+type BatchReport struct {
+  // IDs of input rows with no output row
+  Missing []string
+
+  // IDs which appear more than once in the input rows or output rows
+  Duplicates []string
+
+  // IDs of output rows which do not match an input row (e.g. an echoed
+  // CSV header)
+  Unexpected []string
+}
+
+// Returns true if the input rows and output rows match.
+func (r BatchReport) Ok() bool {
+  return len(r.Missing) == 0 && len(r.Duplicates) == 0 && len(r.Unexpected) == 0
+}
+
+// Error returned by batch methods when the output rows do not match
+// the input rows.
+//
+// The batch methods return the reconciled output rows along with this
+// error, so callers can inspect the report and still use the results.
+type BatchReportError struct {
+  Report BatchReport
+}
+
+// Get error message.
+func (e *BatchReportError) Error() string {
+  var parts []string
+  for _, v := range([]struct {
+    name string // field name
+    ids []string // IDs
+  } {
+    { "missing", e.Report.Missing },
+    { "duplicate", e.Report.Duplicates },
+    { "unexpected", e.Report.Unexpected },
+  }) {
+    if len(v.ids) > 0 {
+      parts = append(parts, fmt.Sprintf("%d %s (%s)", len(v.ids), v.name, strings.Join(v.ids, ", ")))
+    }
+  }
+
+  return "batch output does not match input: " + strings.Join(parts, ", ")
+}
+
+// Reorder batch output rows to match the order of the batch input rows
+// by ID, then return the reordered rows and a report of missing,
+// duplicate, and unexpected IDs.
+//
+// Unexpected output rows and all but the first output row for
+// duplicate IDs are omitted from the result.  Input rows with no
+// matching output row are also omitted from the result and listed in
+// the `Missing` field of the report.
+func ReconcileBatchOutput(in []BatchInputRow, out []BatchOutputRow) ([]BatchOutputRow, BatchReport) {
+  var report BatchReport
+
+  // map input IDs to input positions, check for duplicate input IDs
+  inIds := make(map[string]int, len(in))
+  dups := make(map[string]bool)
+  for i, row := range(in) {
+    if _, ok := inIds[row.Id]; ok {
+      if !dups[row.Id] {
+        dups[row.Id] = true
+        report.Duplicates = append(report.Duplicates, row.Id)
+      }
+      continue
+    }
+    inIds[row.Id] = i
+  }
+
+  // map input positions to output rows, check for duplicate and
+  // unexpected output IDs
+  found := make([]*BatchOutputRow, len(in))
+  for i, row := range(out) {
+    pos, ok := inIds[row.Id]
+    if !ok {
+      report.Unexpected = append(report.Unexpected, row.Id)
+      continue
+    }
+
+    if found[pos] != nil {
+      if !dups[row.Id] {
+        dups[row.Id] = true
+        report.Duplicates = append(report.Duplicates, row.Id)
+      }
+      continue
+    }
+
+    found[pos] = &out[i]
+  }
+
+  // build result in input order, check for missing IDs
+  r := make([]BatchOutputRow, 0, len(in))
+  for i, row := range(in) {
+    if inIds[row.Id] != i {
+      // skip duplicate input row
+      continue
+    }
+
+    if found[i] == nil {
+      report.Missing = append(report.Missing, row.Id)
+      continue
+    }
+
+    r = append(r, *found[i])
+  }
+
+  // return result and report
+  return r, report
+}
@@ -0,0 +1,98 @@
+package geocoder
+
+import (
+  "reflect"
+  "testing"
+)
+
+func TestReconcileBatchOutput(t *testing.T) {
+  // build input rows from IDs
+  in := func(ids ...string) []BatchInputRow {
+    r := make([]BatchInputRow, len(ids))
+    for i, id := range(ids) {
+      r[i] = BatchInputRow { Id: id }
+    }
+    return r
+  }
+
+  // build output rows from IDs
+  out := func(ids ...string) []BatchOutputRow {
+    r := make([]BatchOutputRow, len(ids))
+    for i, id := range(ids) {
+      r[i] = BatchOutputRow { Id: id }
+    }
+    return r
+  }
+
+  tests := []struct {
+    name string // test name
+    in []BatchInputRow // input rows
+    out []BatchOutputRow // output rows
+    exp []string // expected result IDs
+    report BatchReport // expected report
+  } {{
+    name: "reorder",
+    in: in("a", "b", "c"),
+    out: out("c", "a", "b"),
+    exp: []string { "a", "b", "c" },
+  }, {
+    name: "missing",
+    in: in("a", "b", "c"),
+    out: out("c", "a"),
+    exp: []string { "a", "c" },
+    report: BatchReport { Missing: []string { "b" } },
+  }, {
+    name: "header echo",
+    in: in("2022", "2020"),
+    out: out("2022", "id", "2020"),
+    exp: []string { "2022", "2020" },
+    report: BatchReport { Unexpected: []string { "id" } },
+  }, {
+    name: "duplicate output",
+    in: in("a", "b"),
+    out: out("b", "a", "b"),
+    exp: []string { "a", "b" },
+    report: BatchReport { Duplicates: []string { "b" } },
+  }, {
+    name: "duplicate input",
+    in: in("a", "b", "a"),
+    out: out("b", "a"),
+    exp: []string { "a", "b" },
+    report: BatchReport { Duplicates: []string { "a" } },
+  }, {
+    name: "everything",
+    in: in("a", "b", "c", "d"),
+    out: out("x", "d", "a", "a"),
+    exp: []string { "a", "d" },
+    report: BatchReport {
+      Missing: []string { "b", "c" },
+      Duplicates: []string { "a" },
+      Unexpected: []string { "x" },
+    },
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      rows, report := ReconcileBatchOutput(test.in, test.out)
+
+      // check result IDs
+      got := []string{}
+      for _, row := range(rows) {
+        got = append(got, row.Id)
+      }
+      if !reflect.DeepEqual(got, test.exp) {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+
+      // check report
+      if !reflect.DeepEqual(report, test.report) {
+        t.Fatalf("got %#v, exp %#v", report, test.report)
+      }
+
+      // check ok
+      if report.Ok() != reflect.DeepEqual(test.report, BatchReport{}) {
+        t.Fatalf("got Ok() %v", report.Ok())
+      }
+    })
+  }
+}
@@ -0,0 +1,534 @@
+package geocoder
+
+import (
+  "context"
+  "io"
+  "math"
+  "sort"
+  "strings"
+  "sync"
+)
+
+// Boundary polygon, from a shapefile or GeoJSON file.
+type Boundary struct {
+  // polygon rings; holes are found with the even-odd rule, so they do
+  // not need to be distinguished from outer rings
+  Rings [][]Coordinates
+
+  // attributes, with TIGER/Line names (e.g. "STATEFP", "GEOID20") or
+  // Census geocoder API names (e.g. "STATE", "GEOID")
+  Attributes map[string]string
+}
+
+// Get boundaries from features.
+func boundaries(fs []feature) []Boundary {
+  r := make([]Boundary, len(fs))
+  for i, f := range(fs) {
+    r[i] = Boundary { f.parts, f.props }
+  }
+  return r
+}
+
+// Read boundaries from polygon shapefile main file and DBF file.
+func ReadBoundaries(shp, dbf io.Reader) ([]Boundary, error) {
+  fs, err := readShapefileFeatures(shp, dbf)
+  if err != nil {
+    return nil, err
+  }
+
+  return boundaries(fs), nil
+}
+
+// Read boundaries from GeoJSON feature collection with Polygon or
+// MultiPolygon geometries.
+func ReadBoundariesGeoJSON(r io.Reader) ([]Boundary, error) {
+  fs, err := readGeoJSONFeatures(r)
+  if err != nil {
+    return nil, err
+  }
+
+  return boundaries(fs), nil
+}
+
+// Load boundaries from polygon shapefile (".shp", with a ".dbf" file
+// next to it) or GeoJSON file (".geojson" or ".json").
+func LoadBoundaries(path string) ([]Boundary, error) {
+  fs, err := loadFeatures(path)
+  if err != nil {
+    return nil, err
+  }
+
+  return boundaries(fs), nil
+}
+
+// Map of TIGER/Line attribute name to Census geocoder API attribute
+// name.
+//
+// Attribute names with a vintage suffix (e.g. "GEOID20") are mapped
+// without the suffix.  Attributes which are not in this map keep their
+// name.
+var tigerAttributes = map[string]string {
+  "STATEFP": "STATE",
+  "COUNTYFP": "COUNTY",
+  "TRACTCE": "TRACT",
+  "BLKGRPCE": "BLKGRP",
+  "BLOCKCE": "BLOCK",
+  "STUSPS": "STUSAB",
+  "NAME": "NAME",
+  "NAMELSAD": "NAMELSAD",
+  "ALAND": "AREALAND",
+  "AWATER": "AREAWATER",
+  "GEOID": "GEOID",
+  "MTFCC": "MTFCC",
+  "FUNCSTAT": "FUNCSTAT",
+  "INTPTLAT": "INTPTLAT",
+  "INTPTLON": "INTPTLON",
+  "UR": "UR",
+  "UACE": "UACE",
+  "HOUSING": "HU100",
+  "POP": "POP100",
+}
+
+// Convert boundary attributes to Census geocoder API attributes.
+//
+// The block group is derived from the first digit of the block code if
+// it is missing.  The "NAMELSAD" attribute is used as the name and the
+// "NAME" attribute as the base name, like the Census geocoder API; if
+// there is no "NAMELSAD" attribute (e.g. for states), then both are
+// the "NAME" attribute.
+func geographyAttributes(attrs map[string]string) map[string]any {
+  r := make(map[string]any, len(attrs))
+  for k, v := range(attrs) {
+    // strip vintage suffix
+    name := k
+    for _, suffix := range([]string { "20", "10" }) {
+      if base := strings.TrimSuffix(k, suffix); base != k && tigerAttributes[base] != "" {
+        name = base
+      }
+    }
+
+    if apiName, ok := tigerAttributes[name]; ok {
+      name = apiName
+    }
+    r[name] = v
+  }
+
+  // derive block group from block code
+  if block, ok := r["BLOCK"].(string); ok && block != "" && r["BLKGRP"] == nil {
+    r["BLKGRP"] = block[:1]
+  }
+
+  // use name with legal/statistical area description (e.g. "Fairfax
+  // County") as name, and name without it (e.g. "Fairfax") as base name
+  if lsad, ok := r["NAMELSAD"]; ok {
+    if r["BASENAME"] == nil {
+      r["BASENAME"] = r["NAME"]
+    }
+    r["NAME"] = lsad
+    delete(r, "NAMELSAD")
+  } else if r["BASENAME"] == nil && r["NAME"] != nil {
+    r["BASENAME"] = r["NAME"]
+  }
+
+  return r
+}
+
+// Polygon ring with bounding box.
+type indexedRing struct {
+  points []Coordinates // points; longitudes of wrapped rings are in [0, 360)
+  min, max Coordinates // bounding box
+  wrap bool // does the ring cross the antimeridian?
+}
+
+// Build indexed ring.
+//
+// Rings with an edge which spans more than 180 degrees of longitude
+// are assumed to cross the antimeridian, so their negative longitudes
+// are shifted by 360 degrees to get a contiguous ring and a small
+// bounding box.
+func newIndexedRing(ring []Coordinates) indexedRing {
+  r := indexedRing { points: ring }
+
+  // check for antimeridian crossing
+  for i := 1; i < len(ring); i++ {
+    if math.Abs(ring[i].X - ring[i - 1].X) > 180 {
+      r.wrap = true
+      break
+    }
+  }
+
+  // shift negative longitudes of wrapped ring
+  if r.wrap {
+    r.points = make([]Coordinates, len(ring))
+    for i, p := range(ring) {
+      if p.X < 0 {
+        p.X += 360
+      }
+      r.points[i] = p
+    }
+  }
+
+  // get bounding box
+  r.min = Coordinates { X: math.Inf(1), Y: math.Inf(1) }
+  r.max = Coordinates { X: math.Inf(-1), Y: math.Inf(-1) }
+  for _, p := range(r.points) {
+    r.min.X, r.min.Y = math.Min(r.min.X, p.X), math.Min(r.min.Y, p.Y)
+    r.max.X, r.max.Y = math.Max(r.max.X, p.X), math.Max(r.max.Y, p.Y)
+  }
+
+  return r
+}
+
+// Get point in the longitude range of the ring.
+func (r indexedRing) point(p Coordinates) Coordinates {
+  if r.wrap && p.X < 0 {
+    p.X += 360
+  }
+
+  return p
+}
+
+// Boundary with ring bounding boxes and converted attributes.
+type indexedBoundary struct {
+  rings []indexedRing // polygon rings
+  attrs map[string]any // Census geocoder API attributes
+}
+
+// Does the boundary contain the point?
+//
+// Uses the even-odd rule, so points inside holes are outside the
+// boundary.
+func (b indexedBoundary) contains(p Coordinates) bool {
+  in := false
+  for _, ring := range(b.rings) {
+    // a point outside the bounding box of a ring crosses it an even
+    // number of times, so the ring can be skipped
+    q := ring.point(p)
+    if q.X < ring.min.X || q.X > ring.max.X || q.Y < ring.min.Y || q.Y > ring.max.Y {
+      continue
+    }
+
+    pts := ring.points
+    for i, j := 0, len(pts) - 1; i < len(pts); j, i = i, i + 1 {
+      a, c := pts[i], pts[j]
+      if (a.Y > q.Y) != (c.Y > q.Y) && q.X < (c.X - a.X) * (q.Y - a.Y) / (c.Y - a.Y) + a.X {
+        in = !in
+      }
+    }
+  }
+
+  return in
+}
+
+// maximum number of children of an R-tree node
+const rtreeNodeSize = 16
+
+// R-tree node.
+type rtreeNode struct {
+  min, max Coordinates // bounding box
+  children []*rtreeNode // child nodes, or nil for leaf entries
+  index int // boundary index (leaf entries)
+}
+
+// Does the node bounding box contain the point?
+func (n *rtreeNode) contains(p Coordinates) bool {
+  return p.X >= n.min.X && p.X <= n.max.X && p.Y >= n.min.Y && p.Y <= n.max.Y
+}
+
+// Pack nodes into parent nodes with the Sort-Tile-Recursive (STR)
+// algorithm: sort the nodes by center longitude, cut them into
+// vertical slices, sort each slice by center latitude, and then group
+// runs of nodes into parents.
+func packRTree(nodes []*rtreeNode) []*rtreeNode {
+  center := func(n *rtreeNode) Coordinates {
+    return Coordinates { X: (n.min.X + n.max.X) / 2, Y: (n.min.Y + n.max.Y) / 2 }
+  }
+
+  // get number of parents and slice size
+  numParents := (len(nodes) + rtreeNodeSize - 1) / rtreeNodeSize
+  numSlices := int(math.Ceil(math.Sqrt(float64(numParents))))
+  sliceSize := numSlices * rtreeNodeSize
+
+  // sort by longitude, then sort slices by latitude
+  sort.Slice(nodes, func(i, j int) bool { return center(nodes[i]).X < center(nodes[j]).X })
+  for i := 0; i < len(nodes); i += sliceSize {
+    end := i + sliceSize
+    if end > len(nodes) {
+      end = len(nodes)
+    }
+
+    slice := nodes[i:end]
+    sort.Slice(slice, func(i, j int) bool { return center(slice[i]).Y < center(slice[j]).Y })
+  }
+
+  // group nodes into parents
+  r := make([]*rtreeNode, 0, numParents)
+  for i := 0; i < len(nodes); i += rtreeNodeSize {
+    end := i + rtreeNodeSize
+    if end > len(nodes) {
+      end = len(nodes)
+    }
+
+    children := nodes[i:end]
+    parent := &rtreeNode { min: children[0].min, max: children[0].max, children: children }
+    for _, c := range(children[1:]) {
+      parent.min.X, parent.min.Y = math.Min(parent.min.X, c.min.X), math.Min(parent.min.Y, c.min.Y)
+      parent.max.X, parent.max.Y = math.Max(parent.max.X, c.max.X), math.Max(parent.max.Y, c.max.Y)
+    }
+    r = append(r, parent)
+  }
+
+  return r
+}
+
+// Boundary layer with R-tree spatial index.
+//
+// The R-tree is bulk loaded with one entry per ring, so multipart
+// boundaries which are far apart (e.g. islands) and rings which cross
+// the antimeridian get small bounding boxes.
+type boundaryLayer struct {
+  boundaries []indexedBoundary // boundaries
+  root *rtreeNode // R-tree root, or nil if the layer is empty
+  wrap bool // does any ring cross the antimeridian?
+}
+
+// Build indexed boundary layer.
+func newBoundaryLayer(bs []Boundary) *boundaryLayer {
+  l := &boundaryLayer { boundaries: make([]indexedBoundary, 0, len(bs)) }
+
+  // build boundaries and R-tree entries
+  var nodes []*rtreeNode
+  for _, b := range(bs) {
+    ib := indexedBoundary { attrs: geographyAttributes(b.Attributes) }
+    for _, ring := range(b.Rings) {
+      // skip empty rings
+      if len(ring) == 0 {
+        continue
+      }
+
+      r := newIndexedRing(ring)
+      l.wrap = l.wrap || r.wrap
+      ib.rings = append(ib.rings, r)
+      nodes = append(nodes, &rtreeNode { min: r.min, max: r.max, index: len(l.boundaries) })
+    }
+
+    // skip empty boundaries
+    if len(ib.rings) == 0 {
+      continue
+    }
+
+    l.boundaries = append(l.boundaries, ib)
+  }
+
+  // pack entries into tree
+  for len(nodes) > 1 {
+    nodes = packRTree(nodes)
+  }
+  if len(nodes) > 0 {
+    l.root = nodes[0]
+  }
+
+  return l
+}
+
+// Add indices of boundaries with a ring bounding box which contains the
+// point to set.
+func (l *boundaryLayer) search(p Coordinates, set map[int]bool) {
+  if l.root == nil || !l.root.contains(p) {
+    return
+  }
+
+  stack := []*rtreeNode { l.root }
+  for len(stack) > 0 {
+    n := stack[len(stack) - 1]
+    stack = stack[:len(stack) - 1]
+
+    if n.children == nil {
+      set[n.index] = true
+      continue
+    }
+
+    for _, c := range(n.children) {
+      if c.contains(p) {
+        stack = append(stack, c)
+      }
+    }
+  }
+}
+
+// Get attributes of boundaries which contain point.
+func (l *boundaryLayer) lookup(p Coordinates) []map[string]any {
+  // find candidate boundaries; wrapped rings are indexed with
+  // longitudes in [0, 360)
+  set := make(map[int]bool)
+  l.search(p, set)
+  if l.wrap && p.X < 0 {
+    l.search(Coordinates { X: p.X + 360, Y: p.Y }, set)
+  }
+
+  // sort candidates so results are in boundary order
+  ids := make([]int, 0, len(set))
+  for i := range(set) {
+    ids = append(ids, i)
+  }
+  sort.Ints(ids)
+
+  r := []map[string]any{}
+  for _, i := range(ids) {
+    if b := l.boundaries[i]; b.contains(p) {
+      // copy attributes so callers can modify results
+      attrs := make(map[string]any, len(b.attrs))
+      for k, v := range(b.attrs) {
+        attrs[k] = v
+      }
+      r = append(r, attrs)
+    }
+  }
+
+  return r
+}
+
+// Offline reverse geocoder which finds the boundary polygons that
+// contain a point.
+//
+// Boundaries are grouped into named layers, and lookups return a
+// [GeographyLayers] with the attributes of the matching boundaries in
+// each layer, like [GeographiesFromCoordinates()].  Use the Census
+// geocoder API layer names (e.g. "States", "Counties", "Census Tracts",
+// and "2020 Census Blocks") so the [GeographyLayers] accessors (e.g.
+// [GeographyLayers.Blocks()]) work with the results.
+//
+// Only coordinate lookups are supported; other lookups return
+// [ErrNotSupported].  Benchmarks and vintages are ignored.
+type BoundaryGeocoder struct {
+  mu sync.RWMutex // mutex for layers
+  layers map[string]*boundaryLayer // layers by name
+}
+
+// make sure BoundaryGeocoder implements Geocoder
+var _ Geocoder = (*BoundaryGeocoder)(nil)
+
+// Create empty offline reverse geocoder.
+func NewBoundaryGeocoder() *BoundaryGeocoder {
+  return &BoundaryGeocoder { layers: make(map[string]*boundaryLayer) }
+}
+
+// Create offline reverse geocoder from map of layer name to boundary
+// file (see [LoadBoundaries]).
+func LoadBoundaryGeocoder(paths map[string]string) (*BoundaryGeocoder, error) {
+  g := NewBoundaryGeocoder()
+  for name, path := range(paths) {
+    if err := g.LoadLayer(name, path); err != nil {
+      return nil, err
+    }
+  }
+
+  return g, nil
+}
+
+// Add layer with given name and boundaries, replacing any existing
+// layer with the same name.
+func (g *BoundaryGeocoder) AddLayer(name string, bs []Boundary) {
+  l := newBoundaryLayer(bs)
+
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  g.layers[name] = l
+}
+
+// Load layer with given name from boundary file (see
+// [LoadBoundaries]).
+func (g *BoundaryGeocoder) LoadLayer(name, path string) error {
+  bs, err := LoadBoundaries(path)
+  if err != nil {
+    return err
+  }
+
+  g.AddLayer(name, bs)
+  return nil
+}
+
+// Get sorted layer names.
+func (g *BoundaryGeocoder) Layers() []string {
+  g.mu.RLock()
+  defer g.mu.RUnlock()
+
+  r := make([]string, 0, len(g.layers))
+  for name := range(g.layers) {
+    r = append(r, name)
+  }
+  sort.Strings(r)
+
+  return r
+}
+
+// Get geography layers which contain coordinates.
+//
+// If layer names are given, then only those layers are included in the
+// result; [AllLayers] or no layer names include every layer.  Layers
+// with no boundaries which contain the coordinates are empty.
+func (g *BoundaryGeocoder) Lookup(coords Coordinates, layers ...string) GeographyLayers {
+  // build set of selected layers
+  var selected map[string]bool
+  if len(layers) > 0 {
+    selected = make(map[string]bool, len(layers))
+    for _, name := range(layers) {
+      selected[name] = true
+    }
+    if selected[AllLayers] {
+      selected = nil
+    }
+  }
+
+  g.mu.RLock()
+  defer g.mu.RUnlock()
+
+  r := make(GeographyLayers, len(g.layers))
+  for name, l := range(g.layers) {
+    if selected == nil || selected[name] {
+      r[name] = l.lookup(coords)
+    }
+  }
+
+  return r
+}
+
+// Not supported; returns [ErrNotSupported].
+func (g *BoundaryGeocoder) LocationsFromBenchmarkContext(ctx context.Context, address, benchmark string) ([]Match, error) {
+  return []Match{}, ErrNotSupported
+}
+
+// Not supported; returns [ErrNotSupported].
+func (g *BoundaryGeocoder) GeographiesContext(ctx context.Context, address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  return []Match{}, ErrNotSupported
+}
+
+// Not supported; returns [ErrNotSupported].
+func (g *BoundaryGeocoder) AddressLocationsFromBenchmarkContext(ctx context.Context, address Address, benchmark string) ([]Match, error) {
+  return []Match{}, ErrNotSupported
+}
+
+// Not supported; returns [ErrNotSupported].
+func (g *BoundaryGeocoder) AddressGeographiesContext(ctx context.Context, address Address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  return []Match{}, ErrNotSupported
+}
+
+// Get geography layers which contain coordinates.  The benchmark and
+// vintage are ignored.
+func (g *BoundaryGeocoder) GeographiesFromCoordinatesContext(ctx context.Context, coords Coordinates, benchmark, vintage string, layers ...string) (GeographyLayers, error) {
+  if err := ctx.Err(); err != nil {
+    return GeographyLayers{}, err
+  }
+
+  return g.Lookup(coords, layers...), nil
+}
+
+// Not supported; returns [ErrNotSupported].
+func (g *BoundaryGeocoder) BatchLocationsFromBenchmarkContext(ctx context.Context, rows []BatchInputRow, benchmark string) ([]BatchOutputRow, error) {
+  return []BatchOutputRow{}, ErrNotSupported
+}
+
+// Not supported; returns [ErrNotSupported].
+func (g *BoundaryGeocoder) BatchGeographiesContext(ctx context.Context, rows []BatchInputRow, benchmark, vintage string) ([]BatchOutputRow, error) {
+  return []BatchOutputRow{}, ErrNotSupported
+}
@@ -0,0 +1,483 @@
+package geocoder
+
+import (
+  "bytes"
+  "context"
+  "errors"
+  "fmt"
+  "math/rand"
+  "reflect"
+  "sort"
+  "testing"
+)
+
+// test boundary files, by layer name
+var testBoundaryPaths = map[string]string {
+  "States": "testdata/boundaries/states.geojson",
+  "Counties": "testdata/boundaries/counties.geojson",
+  "Census Tracts": "testdata/boundaries/tracts.geojson",
+  "2020 Census Blocks": "testdata/boundaries/blocks.geojson",
+}
+
+// Load test boundary geocoder.
+func newTestBoundaryGeocoder(t *testing.T) *BoundaryGeocoder {
+  g, err := LoadBoundaryGeocoder(testBoundaryPaths)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return g
+}
+
+// Get GEOIDs of geographies in each layer.
+func layerGeoIds(layers GeographyLayers) map[string][]string {
+  r := make(map[string][]string, len(layers))
+  for name, rows := range(layers) {
+    ids := []string{}
+    for _, row := range(rows) {
+      ids = append(ids, fmt.Sprint(row["GEOID"]))
+    }
+    r[name] = ids
+  }
+  return r
+}
+
+func TestGeographyAttributes(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    val map[string]string // boundary attributes
+    exp map[string]any // expected result
+  } {{
+    name: "state",
+    val: map[string]string { "STATEFP": "24", "STUSPS": "MD", "NAME": "Maryland", "GEOID": "24" },
+    exp: map[string]any { "STATE": "24", "STUSAB": "MD", "NAME": "Maryland", "BASENAME": "Maryland", "GEOID": "24" },
+  }, {
+    name: "county",
+    val: map[string]string { "STATEFP": "24", "COUNTYFP": "033", "NAME": "Prince George's", "NAMELSAD": "Prince George's County", "ALAND": "1" },
+    exp: map[string]any { "STATE": "24", "COUNTY": "033", "NAME": "Prince George's County", "BASENAME": "Prince George's", "AREALAND": "1" },
+  }, {
+    name: "block",
+    val: map[string]string { "STATEFP20": "24", "COUNTYFP20": "033", "TRACTCE20": "802404", "BLOCKCE20": "1000", "GEOID20": "240338024041000", "POP20": "3" },
+    exp: map[string]any { "STATE": "24", "COUNTY": "033", "TRACT": "802404", "BLOCK": "1000", "BLKGRP": "1", "GEOID": "240338024041000", "POP100": "3" },
+  }, {
+    name: "api names",
+    val: map[string]string { "STATE": "24", "NAME": "Maryland", "BASENAME": "MD", "CD118": "05" },
+    exp: map[string]any { "STATE": "24", "NAME": "Maryland", "BASENAME": "MD", "CD118": "05" },
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      if got := geographyAttributes(test.val); !reflect.DeepEqual(got, test.exp) {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestBoundaryGeocoderLookup(t *testing.T) {
+  g := newTestBoundaryGeocoder(t)
+
+  if got, exp := g.Layers(), []string { "2020 Census Blocks", "Census Tracts", "Counties", "States" }; !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+
+  tests := []struct {
+    name string // test name
+    val Coordinates // coordinates
+    exp map[string][]string // expected GEOIDs by layer
+  } {{
+    name: "block 1000",
+    val: Coordinates { X: -76.925, Y: 38.846 },
+    exp: map[string][]string {
+      "States": { "24" },
+      "Counties": { "24033" },
+      "Census Tracts": { "24033802404" },
+      "2020 Census Blocks": { "240338024041000" },
+    },
+  }, {
+    name: "block 1001",
+    val: Coordinates { X: -76.92, Y: 38.855 },
+    exp: map[string][]string {
+      "States": { "24" },
+      "Counties": { "24033" },
+      "Census Tracts": { "24033802404" },
+      "2020 Census Blocks": { "240338024041001" },
+    },
+  }, {
+    name: "hole",
+    val: Coordinates { X: -76.933, Y: 38.842 },
+    exp: map[string][]string {
+      "States": { "24" },
+      "Counties": { "24033" },
+      "Census Tracts": { "24033802404" },
+      "2020 Census Blocks": { "240338024042000" },
+    },
+  }, {
+    name: "second tract polygon",
+    val: Coordinates { X: -76.795, Y: 38.835 },
+    exp: map[string][]string {
+      "States": { "24" },
+      "Counties": { "24033" },
+      "Census Tracts": { "24033802404" },
+      "2020 Census Blocks": {},
+    },
+  }, {
+    name: "virginia",
+    val: Coordinates { X: -79, Y: 38 },
+    exp: map[string][]string {
+      "States": { "51" },
+      "Counties": {},
+      "Census Tracts": {},
+      "2020 Census Blocks": {},
+    },
+  }, {
+    name: "nowhere",
+    val: Coordinates { X: 0, Y: 0 },
+    exp: map[string][]string {
+      "States": {},
+      "Counties": {},
+      "Census Tracts": {},
+      "2020 Census Blocks": {},
+    },
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      got, err := g.GeographiesFromCoordinatesContext(context.Background(), test.val, "", "")
+      if err != nil {
+        t.Fatal(err)
+      }
+      if ids := layerGeoIds(got); !reflect.DeepEqual(ids, test.exp) {
+        t.Fatalf("got %v, exp %v", ids, test.exp)
+      }
+    })
+  }
+}
+
+func TestBoundaryGeocoderLookupLayers(t *testing.T) {
+  g := newTestBoundaryGeocoder(t)
+  p := Coordinates { X: -76.925, Y: 38.846 }
+
+  tests := []struct {
+    name string // test name
+    layers []string // selected layers
+    exp []string // expected layer names
+  } {
+    { "none", nil, []string { "2020 Census Blocks", "Census Tracts", "Counties", "States" } },
+    { "all", []string { AllLayers }, []string { "2020 Census Blocks", "Census Tracts", "Counties", "States" } },
+    { "some", []string { "Counties", "States" }, []string { "Counties", "States" } },
+    { "unknown", []string { "Places" }, []string {} },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      got := []string{}
+      for name := range(g.Lookup(p, test.layers...)) {
+        got = append(got, name)
+      }
+      sort.Strings(got)
+
+      if !reflect.DeepEqual(got, test.exp) {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestBoundaryGeocoderAccessors(t *testing.T) {
+  layers := newTestBoundaryGeocoder(t).Lookup(Coordinates { X: -76.925, Y: 38.846 })
+
+  blocks, err := layers.Blocks()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(blocks) != 1 {
+    t.Fatalf("got %d blocks, exp 1", len(blocks))
+  }
+  b := blocks[0]
+  gotBlock := []any { b.GeoId, b.Name, b.State, b.County, b.Tract, b.BlockGroup, b.Block, b.Pop100, b.Hu100 }
+  expBlock := []any { "240338024041000", "Block 1000", "24", "033", "802404", "1", "1000", int64(34), int64(12) }
+  if !reflect.DeepEqual(gotBlock, expBlock) {
+    t.Fatalf("got %v, exp %v", gotBlock, expBlock)
+  }
+
+  counties, err := layers.Counties()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(counties) != 1 {
+    t.Fatalf("got %d counties, exp 1", len(counties))
+  }
+  c := counties[0]
+  gotCounty := []any { c.GeoId, c.Name, c.BaseName, c.State, c.County, c.AreaLand, c.AreaWater, c.IntPtLat, c.IntPtLon }
+  expCounty := []any { "24033", "Prince George's County", "Prince George's", "24", "033", int64(1250000000), int64(50000000), 38.8, -76.85 }
+  if !reflect.DeepEqual(gotCounty, expCounty) {
+    t.Fatalf("got %v, exp %v", gotCounty, expCounty)
+  }
+
+  states, err := layers.States()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(states) != 1 || states[0].Stusab != "MD" || states[0].Name != "Maryland" {
+    t.Fatalf("got %v, exp Maryland", states)
+  }
+
+  // check that results can be modified
+  layers["Counties"][0]["NAME"] = "changed"
+  again := newTestBoundaryGeocoder(t).Lookup(Coordinates { X: -76.925, Y: 38.846 })
+  if got := again["Counties"][0]["NAME"]; got != "Prince George's County" {
+    t.Fatalf("got %v, exp %v", got, "Prince George's County")
+  }
+}
+
+func TestBoundaryGeocoderIndex(t *testing.T) {
+  // build layer with 20x20 grid of 0.01 degree squares
+  var bs []Boundary
+  for x := 0; x < 20; x++ {
+    for y := 0; y < 20; y++ {
+      x0, y0 := -77 + 0.01 * float64(x), 38 + 0.01 * float64(y)
+      bs = append(bs, Boundary {
+        Rings: [][]Coordinates {{
+          { X: x0, Y: y0 },
+          { X: x0 + 0.01, Y: y0 },
+          { X: x0 + 0.01, Y: y0 + 0.01 },
+          { X: x0, Y: y0 + 0.01 },
+          { X: x0, Y: y0 },
+        }},
+        Attributes: map[string]string { "GEOID": fmt.Sprintf("%02d%02d", x, y) },
+      })
+    }
+  }
+
+  g := NewBoundaryGeocoder()
+  g.AddLayer("Squares", bs)
+
+  // check random points against expected squares
+  rng := rand.New(rand.NewSource(1))
+  for i := 0; i < 1000; i++ {
+    x, y := rng.Intn(20), rng.Intn(20)
+    p := Coordinates {
+      X: -77 + 0.01 * (float64(x) + 0.05 + 0.9 * rng.Float64()),
+      Y: 38 + 0.01 * (float64(y) + 0.05 + 0.9 * rng.Float64()),
+    }
+
+    got := layerGeoIds(g.Lookup(p))["Squares"]
+    if exp := []string { fmt.Sprintf("%02d%02d", x, y) }; !reflect.DeepEqual(got, exp) {
+      t.Fatalf("%v: got %v, exp %v", p, got, exp)
+    }
+  }
+
+  // replace layer
+  g.AddLayer("Squares", nil)
+  if got := g.Lookup(Coordinates { X: -76.995, Y: 38.005 })["Squares"]; len(got) != 0 {
+    t.Fatalf("got %v, exp none", got)
+  }
+}
+
+// Get closed rectangular ring with given corners.
+func testRect(x0, y0, x1, y1 float64) []Coordinates {
+  return []Coordinates { { X: x0, Y: y0 }, { X: x1, Y: y0 }, { X: x1, Y: y1 }, { X: x0, Y: y1 }, { X: x0, Y: y0 } }
+}
+
+func TestBoundaryLayerAntimeridian(t *testing.T) {
+  // build layer with 1000 small squares, a multipart boundary with
+  // parts on both sides of the antimeridian, a single ring which
+  // crosses the antimeridian, and a large boundary
+  var bs []Boundary
+  for i := 0; i < 1000; i++ {
+    x0, y0 := -77 + 0.01 * float64(i % 40), 38 + 0.01 * float64(i / 40)
+    bs = append(bs, Boundary {
+      Rings: [][]Coordinates { testRect(x0, y0, x0 + 0.01, y0 + 0.01) },
+      Attributes: map[string]string { "GEOID": fmt.Sprintf("%04d", i) },
+    })
+  }
+  bs = append(bs, Boundary {
+    Rings: [][]Coordinates { testRect(172, 51, 180, 53), testRect(-180, 51, -170, 53) },
+    Attributes: map[string]string { "GEOID": "aleutians" },
+  }, Boundary {
+    Rings: [][]Coordinates {{ { X: 178, Y: 60 }, { X: -178, Y: 60 }, { X: -178, Y: 62 }, { X: 178, Y: 62 }, { X: 178, Y: 60 } }},
+    Attributes: map[string]string { "GEOID": "wrapped" },
+  }, Boundary {
+    Rings: [][]Coordinates { testRect(-125, 25, -66, 49) },
+    Attributes: map[string]string { "GEOID": "conus" },
+  })
+  l := newBoundaryLayer(bs)
+
+  tests := []struct {
+    p Coordinates // point
+    exp []string // expected GEOIDs
+  } {
+    { Coordinates { X: -76.995, Y: 38.005 }, []string { "0000", "conus" } },
+    { Coordinates { X: 179, Y: 52 }, []string { "aleutians" } },
+    { Coordinates { X: -175, Y: 52 }, []string { "aleutians" } },
+    { Coordinates { X: 179, Y: 61 }, []string { "wrapped" } },
+    { Coordinates { X: -179, Y: 61 }, []string { "wrapped" } },
+    { Coordinates { X: -177, Y: 61 }, []string {} },
+    { Coordinates { X: 0, Y: 52 }, []string {} },
+    { Coordinates { X: 0, Y: 61 }, []string {} },
+  }
+
+  for _, test := range(tests) {
+    t.Run(fmt.Sprint(test.p), func(t *testing.T) {
+      got := []string{}
+      for _, attrs := range(l.lookup(test.p)) {
+        got = append(got, fmt.Sprint(attrs["GEOID"]))
+      }
+      if !reflect.DeepEqual(got, test.exp) {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+
+  // check that antimeridian boundaries are not candidates for points
+  // in small squares
+  set := make(map[int]bool)
+  l.search(Coordinates { X: -76.995, Y: 38.005 }, set)
+  if len(set) != 2 {
+    t.Fatalf("got %d candidates, exp 2", len(set))
+  }
+}
+
+func TestReadBoundaries(t *testing.T) {
+  shp, dbf := writeTestShapefile(shapePolygon, []testFeature {{
+    parts: [][]Coordinates {{ { X: 0, Y: 0 }, { X: 1, Y: 0 }, { X: 1, Y: 1 }, { X: 0, Y: 0 } }},
+    props: map[string]string { "GEOID": "1" },
+  }, {
+    props: map[string]string { "GEOID": "2" },
+  }})
+
+  got, err := ReadBoundaries(bytes.NewReader(shp), bytes.NewReader(dbf))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  exp := []Boundary {{
+    Rings: [][]Coordinates {{ { X: 0, Y: 0 }, { X: 1, Y: 0 }, { X: 1, Y: 1 }, { X: 0, Y: 0 } }},
+    Attributes: map[string]string { "GEOID": "1" },
+  }}
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+
+  g := NewBoundaryGeocoder()
+  g.AddLayer("Test", got)
+  if ids := layerGeoIds(g.Lookup(Coordinates { X: 0.9, Y: 0.1 })); !reflect.DeepEqual(ids["Test"], []string { "1" }) {
+    t.Fatalf("got %v, exp [1]", ids["Test"])
+  }
+}
+
+func TestBoundaryGeocoderFail(t *testing.T) {
+  g := newTestBoundaryGeocoder(t)
+  ctx := context.Background()
+  canceled, cancel := context.WithCancel(ctx)
+  cancel()
+
+  tests := []struct {
+    name string // test name
+    fn func() error // function to call
+    exp error // expected error
+  } {
+    { "locations", func() error {
+      _, err := g.LocationsFromBenchmarkContext(ctx, "4600 Silver Hill Rd", "")
+      return err
+    }, ErrNotSupported },
+    { "geographies", func() error {
+      _, err := g.GeographiesContext(ctx, "4600 Silver Hill Rd", "", "")
+      return err
+    }, ErrNotSupported },
+    { "address locations", func() error {
+      _, err := g.AddressLocationsFromBenchmarkContext(ctx, Address { Street: "4600 Silver Hill Rd" }, "")
+      return err
+    }, ErrNotSupported },
+    { "address geographies", func() error {
+      _, err := g.AddressGeographiesContext(ctx, Address { Street: "4600 Silver Hill Rd" }, "", "")
+      return err
+    }, ErrNotSupported },
+    { "batch locations", func() error {
+      _, err := g.BatchLocationsFromBenchmarkContext(ctx, nil, "")
+      return err
+    }, ErrNotSupported },
+    { "batch geographies", func() error {
+      _, err := g.BatchGeographiesContext(ctx, nil, "", "")
+      return err
+    }, ErrNotSupported },
+    { "canceled", func() error {
+      _, err := g.GeographiesFromCoordinatesContext(canceled, Coordinates{}, "", "")
+      return err
+    }, context.Canceled },
+    { "missing file", func() error {
+      _, err := LoadBoundaryGeocoder(map[string]string { "States": "testdata/boundaries/missing.geojson" })
+      return err
+    }, nil },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      err := test.fn()
+      if err == nil || (test.exp != nil && !errors.Is(err, test.exp)) {
+        t.Fatalf("got %v, exp %v", err, test.exp)
+      }
+    })
+  }
+}
+
+func TestAddressRangeGeocoderGeographies(t *testing.T) {
+  g := newTestAddressRangeGeocoder(t)
+  g.Boundaries = newTestBoundaryGeocoder(t)
+  ctx := context.Background()
+
+  // single address
+  matches, err := g.GeographiesContext(ctx, "4650 Silver Hill Rd, Washington, DC 20233", "", "")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(matches) != 1 {
+    t.Fatalf("got %d matches, exp 1", len(matches))
+  }
+  if got := layerGeoIds(matches[0].Geographies)["2020 Census Blocks"]; !reflect.DeepEqual(got, []string { "240338024041000" }) {
+    t.Fatalf("got %v, exp [240338024041000]", got)
+  }
+
+  // structured address
+  matches, err = g.AddressGeographiesContext(ctx, Address { Street: "4650 Silver Hill Rd", Zip: "20233" }, "", "")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(matches) != 1 {
+    t.Fatalf("got %d matches, exp 1", len(matches))
+  }
+  if got := layerGeoIds(matches[0].Geographies)["Counties"]; !reflect.DeepEqual(got, []string { "24033" }) {
+    t.Fatalf("got %v, exp [24033]", got)
+  }
+
+  // coordinates
+  layers, err := g.GeographiesFromCoordinatesContext(ctx, Coordinates { X: -79, Y: 38 }, "", "")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if got := layerGeoIds(layers)["States"]; !reflect.DeepEqual(got, []string { "51" }) {
+    t.Fatalf("got %v, exp [51]", got)
+  }
+
+  // batch
+  rows, err := g.BatchGeographiesContext(ctx, []BatchInputRow {
+    { Id: "1", Address: "4650 Silver Hill Rd", Zip: "20233" },
+    { Id: "2", Address: "150 N Main St", City: "Springfield", State: "IL", Zip: "62701" },
+    { Id: "3", Address: "nowhere" },
+  }, "", "")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  exp := [][]string {
+    { "1", "true", "24", "033", "802404", "1000" },
+    { "2", "true", "", "", "", "" },
+    { "3", "false", "", "", "", "" },
+  }
+  for i, row := range(rows) {
+    got := []string { row.Id, fmt.Sprint(row.Match), row.State, row.County, row.Tract, row.Block }
+    if !reflect.DeepEqual(got, exp[i]) {
+      t.Fatalf("%d: got %v, exp %v", i, got, exp[i])
+    }
+  }
+}
@@ -0,0 +1,317 @@
+package geocoder
+
+import (
+  "container/list"
+  "crypto/sha256"
+  "encoding/hex"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+)
+
+// Default maximum number of entries in the in-memory cache.
+const DefaultCacheEntries = 1000
+
+// Response cache.
+//
+// Entries are kept in an in-memory least-recently-used (LRU) cache and,
+// if the cache has a directory, stored as files in the directory so
+// they persist between runs.  Entries older than the TTL are ignored
+// and removed when they are read.
+//
+// A cache is safe for concurrent use and may be shared by several
+// clients.  See [Client.Cache].
+type Cache struct {
+  // directory for persistent entries; if empty, then entries are only
+  // kept in memory
+  Dir string
+
+  // maximum age of entries; if zero, then entries never expire
+  TTL time.Duration
+
+  // maximum number of entries in memory; if zero, then
+  // [DefaultCacheEntries] is used
+  MaxEntries int
+
+  mu sync.Mutex // mutex for lru and items (not held during file I/O)
+  lru *list.List // entries, most recently used first
+  items map[string]*list.Element // map of key to entry
+
+  now func() time.Time // get current time (used for testing)
+}
+
+// In-memory cache entry.
+type cacheEntry struct {
+  key string // cache key
+  val []byte // cached value
+  created time.Time // creation time
+}
+
+// Create cache which stores entries in the given directory and expires
+// them after the given TTL.
+//
+// The directory is created if it does not exist.  If the directory is
+// empty, then entries are only kept in memory.  If the TTL is zero,
+// then entries never expire.
+func NewCache(dir string, ttl time.Duration) (*Cache, error) {
+  if dir != "" {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+      return nil, err
+    }
+  }
+
+  return &Cache { Dir: dir, TTL: ttl }, nil
+}
+
+// Get current time.
+func (c *Cache) currentTime() time.Time {
+  if c.now != nil {
+    return c.now()
+  }
+
+  return time.Now()
+}
+
+// Has the given creation time expired?
+func (c *Cache) expired(created time.Time) bool {
+  return c.TTL > 0 && c.currentTime().Sub(created) > c.TTL
+}
+
+// Get path to file for key.
+func (c *Cache) path(key string) string {
+  sum := sha256.Sum256([]byte(key))
+  return filepath.Join(c.Dir, hex.EncodeToString(sum[:]))
+}
+
+// Is the given file name a cache entry file name (e.g., a hex-encoded
+// SHA-256 hash)?
+func isCacheFile(name string) bool {
+  if len(name) != 2 * sha256.Size {
+    return false
+  }
+
+  for _, c := range(name) {
+    if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+      return false
+    }
+  }
+
+  return true
+}
+
+// Add entry to in-memory cache and evict least-recently used entries.
+//
+// Must be called with the mutex held.
+func (c *Cache) add(key string, val []byte, created time.Time) {
+  if c.items == nil {
+    c.lru = list.New()
+    c.items = make(map[string]*list.Element)
+  }
+
+  // add or replace entry
+  if e, ok := c.items[key]; ok {
+    e.Value = cacheEntry { key, val, created }
+    c.lru.MoveToFront(e)
+  } else {
+    c.items[key] = c.lru.PushFront(cacheEntry { key, val, created })
+  }
+
+  // evict least-recently used entries
+  max := c.MaxEntries
+  if max <= 0 {
+    max = DefaultCacheEntries
+  }
+  for c.lru.Len() > max {
+    e := c.lru.Back()
+    c.lru.Remove(e)
+    delete(c.items, e.Value.(cacheEntry).key)
+  }
+}
+
+// Get cached value for key.
+//
+// Returns false if there is no entry for the key or if the entry has
+// expired.
+func (c *Cache) Get(key string) ([]byte, bool) {
+  // check in-memory cache
+  if val, ok := c.getMemory(key); ok {
+    return val, true
+  }
+
+  if c.Dir == "" {
+    return nil, false
+  }
+
+  // check directory (without holding the mutex)
+  path := c.path(key)
+  st, err := os.Stat(path)
+  if err != nil {
+    return nil, false
+  }
+
+  // remove expired file
+  if c.expired(st.ModTime()) {
+    os.Remove(path)
+    return nil, false
+  }
+
+  // read file
+  val, err := os.ReadFile(path)
+  if err != nil {
+    return nil, false
+  }
+
+  // add to in-memory cache
+  c.mu.Lock()
+  c.add(key, val, st.ModTime())
+  c.mu.Unlock()
+
+  return val, true
+}
+
+// Get value for key from in-memory cache.
+//
+// Expired entries are removed.
+func (c *Cache) getMemory(key string) ([]byte, bool) {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+
+  e, ok := c.items[key]
+  if !ok {
+    return nil, false
+  }
+
+  entry := e.Value.(cacheEntry)
+  if !c.expired(entry.created) {
+    c.lru.MoveToFront(e)
+    return entry.val, true
+  }
+
+  // remove expired entry
+  c.lru.Remove(e)
+  delete(c.items, key)
+  return nil, false
+}
+
+// Set cached value for key.
+//
+// Returns an error if the entry could not be written to the cache
+// directory.  The entry is still added to the in-memory cache.
+func (c *Cache) Set(key string, val []byte) error {
+  // add to in-memory cache
+  c.mu.Lock()
+  c.add(key, val, c.currentTime())
+  c.mu.Unlock()
+
+  if c.Dir == "" {
+    return nil
+  }
+
+  // write to temporary file, then rename so readers never see a
+  // partial entry
+  f, err := os.CreateTemp(c.Dir, ".tmp-*")
+  if err != nil {
+    return err
+  }
+  if _, err := f.Write(val); err != nil {
+    f.Close()
+    os.Remove(f.Name())
+    return err
+  }
+  if err := f.Close(); err != nil {
+    os.Remove(f.Name())
+    return err
+  }
+
+  return os.Rename(f.Name(), c.path(key))
+}
+
+// Remove all entries from the in-memory cache and the cache directory.
+//
+// Only entry files are removed from the cache directory; other files
+// are left alone.
+func (c *Cache) Clear() error {
+  c.mu.Lock()
+  c.lru = nil
+  c.items = nil
+  c.mu.Unlock()
+
+  if c.Dir == "" {
+    return nil
+  }
+
+  // remove entry files
+  entries, err := os.ReadDir(c.Dir)
+  if err != nil {
+    return err
+  }
+  for _, e := range(entries) {
+    if !e.IsDir() && isCacheFile(e.Name()) {
+      if err := os.Remove(filepath.Join(c.Dir, e.Name())); err != nil {
+        return err
+      }
+    }
+  }
+
+  return nil
+}
+
+// Normalize address value for cache key: lowercase, trim, and collapse
+// whitespace.
+func normalizeAddress(s string) string {
+  return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// query parameters which contain address components
+var cacheAddressParams = map[string]bool {
+  "address": true,
+  "street": true,
+  "city": true,
+  "state": true,
+  "zip": true,
+}
+
+// Build cache key from endpoint and query parameters.
+//
+// Address parameters are normalized so addresses which differ only in
+// case or whitespace share an entry.  Coordinates, benchmark, vintage,
+// and layers are used as-is.
+func cacheKey(endpoint string, args map[string]string) string {
+  // sort parameter names
+  names := make([]string, 0, len(args))
+  for k := range(args) {
+    names = append(names, k)
+  }
+  sort.Strings(names)
+
+  // build key
+  var b strings.Builder
+  b.WriteString(endpoint)
+  for _, k := range(names) {
+    v := args[k]
+    if cacheAddressParams[k] {
+      v = normalizeAddress(v)
+    }
+
+    b.WriteString("\n" + k + "=" + v)
+  }
+
+  return b.String()
+}
+
+// Build cache key for batch input row.
+func batchCacheKey(endpoint string, fields map[string]string, row BatchInputRow) string {
+  args := make(map[string]string, len(fields) + 4)
+  for k, v := range(fields) {
+    args[k] = v
+  }
+  args["address"] = row.Address
+  args["city"] = row.City
+  args["state"] = row.State
+  args["zip"] = row.Zip
+
+  return cacheKey(endpoint, args)
+}
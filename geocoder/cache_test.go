@@ -0,0 +1,287 @@
+package geocoder
+
+import (
+  "net/http"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strconv"
+  "sync"
+  "sync/atomic"
+  "testing"
+  "time"
+)
+
+// Transport which counts requests.
+type countingTransport struct {
+  n int32 // number of requests
+}
+
+// Count request, then send it with the default transport.
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+  atomic.AddInt32(&t.n, 1)
+  return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCacheLRU(t *testing.T) {
+  c := &Cache { MaxEntries: 2 }
+  c.Set("a", []byte("1"))
+  c.Set("b", []byte("2"))
+
+  // use "a" so "b" is least recently used
+  if got, ok := c.Get("a"); !ok || string(got) != "1" {
+    t.Fatalf("got %q, exp \"1\"", got)
+  }
+
+  // add "c", check that "b" was evicted
+  c.Set("c", []byte("3"))
+  for _, test := range([]struct {
+    key string // cache key
+    ok bool // expected result
+  } {
+    { "a", true },
+    { "b", false },
+    { "c", true },
+  }) {
+    if _, ok := c.Get(test.key); ok != test.ok {
+      t.Fatalf("%s: got %v, exp %v", test.key, ok, test.ok)
+    }
+  }
+}
+
+func TestCacheTTL(t *testing.T) {
+  c, err := NewCache(t.TempDir(), time.Hour)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // set clock
+  now := time.Now()
+  c.now = func() time.Time { return now }
+
+  c.Set("a", []byte("1"))
+  if _, ok := c.Get("a"); !ok {
+    t.Fatal("got miss, exp hit")
+  }
+
+  // advance clock past TTL, check memory and directory
+  now = now.Add(2 * time.Hour)
+  if _, ok := c.Get("a"); ok {
+    t.Fatal("got hit, exp miss")
+  }
+  if _, ok := (&Cache { Dir: c.Dir, TTL: time.Hour, now: c.now }).Get("a"); ok {
+    t.Fatal("got hit, exp miss")
+  }
+}
+
+func TestCacheDir(t *testing.T) {
+  dir := t.TempDir()
+
+  // write entry
+  c, err := NewCache(dir, 0)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if err := c.Set("a", []byte("1")); err != nil {
+    t.Fatal(err)
+  }
+
+  // read entry with new cache
+  c, err = NewCache(dir, 0)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if got, ok := c.Get("a"); !ok || string(got) != "1" {
+    t.Fatalf("got %q, exp \"1\"", got)
+  }
+
+  // clear cache, check for entry with new cache
+  if err := c.Clear(); err != nil {
+    t.Fatal(err)
+  }
+  if _, ok := (&Cache { Dir: dir }).Get("a"); ok {
+    t.Fatal("got hit, exp miss")
+  }
+}
+
+func TestCacheClear(t *testing.T) {
+  dir := t.TempDir()
+
+  // write unrelated file
+  other := filepath.Join(dir, "important.txt")
+  if err := os.WriteFile(other, []byte("keep"), 0644); err != nil {
+    t.Fatal(err)
+  }
+
+  // write entry, then clear cache
+  c, err := NewCache(dir, 0)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if err := c.Set("a", []byte("1")); err != nil {
+    t.Fatal(err)
+  }
+  if err := c.Clear(); err != nil {
+    t.Fatal(err)
+  }
+
+  // check that entry file was removed and unrelated file was not
+  if _, err := os.Stat(c.path("a")); !os.IsNotExist(err) {
+    t.Fatalf("got %v, exp not exist", err)
+  }
+  if got, err := os.ReadFile(other); err != nil || string(got) != "keep" {
+    t.Fatalf("got %q (%v), exp \"keep\"", got, err)
+  }
+}
+
+func TestCacheConcurrent(t *testing.T) {
+  c, err := NewCache(t.TempDir(), 0)
+  if err != nil {
+    t.Fatal(err)
+  }
+  c.MaxEntries = 4
+
+  // read and write entries concurrently (run with -race)
+  var wg sync.WaitGroup
+  for i := 0; i < 8; i++ {
+    wg.Add(1)
+    go func(i int) {
+      defer wg.Done()
+      for j := 0; j < 20; j++ {
+        key := strconv.Itoa((i + j) % 10)
+        if err := c.Set(key, []byte(key)); err != nil {
+          t.Error(err)
+          return
+        }
+        if got, ok := c.Get(key); !ok || string(got) != key {
+          t.Errorf("got %q, exp %q", got, key)
+          return
+        }
+      }
+    }(i)
+  }
+  wg.Wait()
+}
+
+func TestCacheKey(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    a, b map[string]string // query parameters
+    exp bool // expect equal keys?
+  } {{
+    name: "normalized address",
+    a: map[string]string { "address": "4600 Silver Hill Rd", "benchmark": "2020" },
+    b: map[string]string { "address": " 4600  SILVER hill rd ", "benchmark": "2020" },
+    exp: true,
+  }, {
+    name: "benchmark",
+    a: map[string]string { "address": "4600 silver hill rd", "benchmark": "2020" },
+    b: map[string]string { "address": "4600 silver hill rd", "benchmark": "4" },
+    exp: false,
+  }, {
+    name: "layers",
+    a: map[string]string { "x": "-77", "y": "38", "layers": "all" },
+    b: map[string]string { "x": "-77", "y": "38", "layers": "Counties" },
+    exp: false,
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      got := cacheKey("locations", test.a) == cacheKey("locations", test.b)
+      if got != test.exp {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestClientCache(t *testing.T) {
+  // create mock server
+  ms, url, err := newMockServer()
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ms.Close()
+
+  // create client with cache and counting transport
+  c := NewClient(WithBaseURL(url))
+  c.Cache, err = NewCache(t.TempDir(), time.Hour)
+  if err != nil {
+    t.Fatal(err)
+  }
+  var tr countingTransport
+  c.Client.Transport = &tr
+
+  // geocode address twice, check results
+  exp, err := c.Locations(testAddress)
+  if err != nil {
+    t.Fatal(err)
+  }
+  got, err := c.Locations(" 4600 SILVER HILL RD, Washington, DC 20233")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+
+  // get coordinates twice
+  for i := 0; i < 2; i++ {
+    if _, err := c.GeographiesFromCoordinates(testCoordinates, "2020", "2020"); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  // check number of requests
+  if tr.n != 2 {
+    t.Fatalf("got %d requests, exp 2", tr.n)
+  }
+}
+
+func TestClientBatchCache(t *testing.T) {
+  s, c := newEchoBatchServer(t, "")
+  defer s.Close()
+
+  var err error
+  c.Cache, err = NewCache(t.TempDir(), 0)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // upload first rows
+  rows := genBatchInputRows(6)
+  if _, err := c.BatchLocations(rows[:4]); err != nil {
+    t.Fatal(err)
+  }
+
+  // upload all rows, with the first cached row under a new ID
+  rows[0].Id = "new"
+  got, err := c.BatchLocations(rows)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // check that only the uncached rows were uploaded
+  exp := [][]string {{ "0", "1", "2", "3" }, { "4", "5" }}
+  if !reflect.DeepEqual(s.chunks, exp) {
+    t.Fatalf("got chunks %v, exp %v", s.chunks, exp)
+  }
+
+  // check merged results
+  if len(got) != len(rows) {
+    t.Fatalf("got %d rows, exp %d", len(got), len(rows))
+  }
+  for i, row := range(got) {
+    if row.Id != rows[i].Id || row.InputAddress != rows[i].Address {
+      t.Fatalf("%d: got %v, exp id %s", i, row, rows[i].Id)
+    }
+  }
+
+  // upload cached rows, check that nothing was uploaded
+  if _, err := c.BatchLocations(rows); err != nil {
+    t.Fatal(err)
+  }
+  if len(s.chunks) != 2 {
+    t.Fatalf("got %d chunks, exp 2", len(s.chunks))
+  }
+}
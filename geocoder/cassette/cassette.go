@@ -0,0 +1,344 @@
+// Record and replay HTTP interactions for deterministic tests.
+//
+// A [Recorder] is an [net/http.RoundTripper] which can be used as the
+// transport of a geocoder client:
+//
+//   r, err := cassette.New("testdata/cassettes/example.json", cassette.ModeReplay)
+//   if err != nil {
+//     log.Fatal(err)
+//   }
+//
+//   c := geocoder.NewClient()
+//   c.Client.Transport = r
+//
+// In record mode, requests are sent with [Recorder.Transport] and the
+// request/response pairs are saved to the cassette file by
+// [Recorder.Save].  In replay mode, responses are served from the
+// cassette file and requests which do not match a recorded interaction
+// fail with [ErrNoInteraction].
+package cassette
+
+import (
+  "bytes"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "mime"
+  "mime/multipart"
+  "net/http"
+  net_url "net/url"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+)
+
+// Request does not match a recorded interaction.
+var ErrNoInteraction = errors.New("no recorded interaction")
+
+// Recorder mode.
+type Mode int
+
+const (
+  // Serve responses from cassette file.
+  ModeReplay Mode = iota
+
+  // Send requests and record interactions.
+  ModeRecord
+)
+
+// Recorded request.
+type Request struct {
+  // HTTP method
+  Method string `json:"method"`
+
+  // request URL, with sorted query parameters
+  URL string `json:"url"`
+
+  // request body; multipart form bodies are stored as sorted,
+  // URL-encoded form values (including file contents) because their
+  // boundaries are random
+  Body string `json:"body,omitempty"`
+}
+
+// Recorded response.
+type Response struct {
+  // HTTP status code
+  Status int `json:"status"`
+
+  // response headers
+  Header http.Header `json:"header,omitempty"`
+
+  // response body
+  Body string `json:"body"`
+}
+
+// Recorded request/response pair.
+type Interaction struct {
+  Request Request `json:"request"`
+  Response Response `json:"response"`
+}
+
+// Cassette file contents.
+type cassette struct {
+  Interactions []Interaction `json:"interactions"`
+}
+
+// response headers which are not recorded
+var skipHeaders = map[string]bool {
+  "Date": true,
+  "Set-Cookie": true,
+}
+
+// Recording and replaying HTTP transport.
+//
+// Safe for concurrent use.
+type Recorder struct {
+  // cassette file path
+  Path string
+
+  // recorder mode
+  Mode Mode
+
+  // transport used to send requests in record mode and passthrough
+  // requests.  If nil, then [net/http.DefaultTransport] is used.
+  Transport http.RoundTripper
+
+  // If non-nil, then requests for which Passthrough returns true are
+  // sent with Transport without being recorded or replayed (e.g.
+  // requests to a local test server).
+  Passthrough func(*http.Request) bool
+
+  mu sync.Mutex // mutex for fields below
+  interactions []Interaction // recorded interactions
+  used []bool // which interactions have been replayed?
+}
+
+// Create recorder for cassette file with given mode.
+//
+// In replay mode, the cassette file is loaded and an error is
+// returned if it can not be read.
+func New(path string, mode Mode) (*Recorder, error) {
+  r := &Recorder { Path: path, Mode: mode }
+  if mode != ModeReplay {
+    return r, nil
+  }
+
+  // read cassette
+  data, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+
+  // decode cassette
+  var c cassette
+  if err := json.Unmarshal(data, &c); err != nil {
+    return nil, fmt.Errorf("%s: %w", path, err)
+  }
+
+  r.interactions = c.Interactions
+  r.used = make([]bool, len(c.Interactions))
+  return r, nil
+}
+
+// Get copy of recorded or loaded interactions.
+func (r *Recorder) Interactions() []Interaction {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  return append([]Interaction{}, r.interactions...)
+}
+
+// Save recorded interactions to cassette file.
+//
+// The parent directory is created if it does not exist.
+func (r *Recorder) Save() error {
+  r.mu.Lock()
+  data, err := json.MarshalIndent(cassette { r.interactions }, "", "  ")
+  r.mu.Unlock()
+  if err != nil {
+    return err
+  }
+
+  if err := os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
+    return err
+  }
+
+  return os.WriteFile(r.Path, append(data, '\n'), 0644)
+}
+
+// Get transport.
+func (r *Recorder) transport() http.RoundTripper {
+  if r.Transport != nil {
+    return r.Transport
+  }
+
+  return http.DefaultTransport
+}
+
+// Record or replay request.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+  if r.Passthrough != nil && r.Passthrough(req) {
+    return r.transport().RoundTrip(req)
+  }
+
+  // read request body so it can be stored and re-sent
+  var body []byte
+  if req.Body != nil {
+    var err error
+    body, err = io.ReadAll(req.Body)
+    req.Body.Close()
+    if err != nil {
+      return nil, err
+    }
+  }
+
+  // build recorded request
+  key, err := newRequest(req, body)
+  if err != nil {
+    return nil, err
+  }
+
+  if r.Mode == ModeReplay {
+    return r.replay(req, key)
+  }
+
+  return r.record(req, key, body)
+}
+
+// Send request with transport, then record interaction.
+func (r *Recorder) record(req *http.Request, key Request, body []byte) (*http.Response, error) {
+  // restore request body
+  out := req.Clone(req.Context())
+  if body != nil {
+    out.Body = io.NopCloser(bytes.NewReader(body))
+    out.ContentLength = int64(len(body))
+  }
+
+  // send request
+  resp, err := r.transport().RoundTrip(out)
+  if err != nil {
+    return nil, err
+  }
+  defer resp.Body.Close()
+
+  // read response body
+  respBody, err := io.ReadAll(resp.Body)
+  if err != nil {
+    return nil, err
+  }
+
+  // copy recorded headers
+  header := http.Header{}
+  for k, vals := range(resp.Header) {
+    if !skipHeaders[k] {
+      header[k] = vals
+    }
+  }
+
+  // record interaction
+  ia := Interaction {
+    Request: key,
+    Response: Response {
+      Status: resp.StatusCode,
+      Header: header,
+      Body: string(respBody),
+    },
+  }
+
+  r.mu.Lock()
+  r.interactions = append(r.interactions, ia)
+  r.used = append(r.used, true)
+  r.mu.Unlock()
+
+  return newResponse(req, ia.Response), nil
+}
+
+// Find recorded interaction for request, then return recorded
+// response.
+//
+// Interactions are replayed in recorded order, so repeated requests
+// (e.g. retries) get the same sequence of responses that was recorded.
+// Once every matching interaction has been replayed, the last one is
+// replayed again.
+func (r *Recorder) replay(req *http.Request, key Request) (*http.Response, error) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+
+  last := -1
+  for i, ia := range(r.interactions) {
+    if ia.Request != key {
+      continue
+    }
+
+    if !r.used[i] {
+      r.used[i] = true
+      return newResponse(req, ia.Response), nil
+    }
+    last = i
+  }
+
+  if last >= 0 {
+    return newResponse(req, r.interactions[last].Response), nil
+  }
+
+  return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, key.Method, key.URL)
+}
+
+// Build recorded request from request and request body.
+func newRequest(req *http.Request, body []byte) (Request, error) {
+  // sort query parameters
+  u := *req.URL
+  u.RawQuery = u.Query().Encode()
+
+  r := Request { Method: req.Method, URL: u.String() }
+
+  // check for multipart body
+  mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+  if strings.HasPrefix(mediaType, "multipart/") {
+    vals, err := multipartValues(body, params["boundary"])
+    if err != nil {
+      return Request{}, err
+    }
+    r.Body = vals.Encode()
+  } else {
+    r.Body = string(body)
+  }
+
+  return r, nil
+}
+
+// Read multipart form values and file contents from body.
+func multipartValues(body []byte, boundary string) (net_url.Values, error) {
+  r := net_url.Values{}
+  mr := multipart.NewReader(bytes.NewReader(body), boundary)
+  for {
+    p, err := mr.NextPart()
+    if err == io.EOF {
+      return r, nil
+    } else if err != nil {
+      return nil, err
+    }
+
+    data, err := io.ReadAll(p)
+    if err != nil {
+      return nil, err
+    }
+    r.Add(p.FormName(), string(data))
+  }
+}
+
+// Build HTTP response from recorded response.
+func newResponse(req *http.Request, r Response) *http.Response {
+  return &http.Response {
+    Status: fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+    StatusCode: r.Status,
+    Proto: "HTTP/1.1",
+    ProtoMajor: 1,
+    ProtoMinor: 1,
+    Header: r.Header.Clone(),
+    Body: io.NopCloser(strings.NewReader(r.Body)),
+    ContentLength: int64(len(r.Body)),
+    Request: req,
+  }
+}
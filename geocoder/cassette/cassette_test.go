@@ -0,0 +1,139 @@
+package cassette
+
+import (
+  "errors"
+  "net/http"
+  "path/filepath"
+  "reflect"
+  "testing"
+
+  "pablotron.org/census-geocoder/geocoder"
+  "pablotron.org/census-geocoder/geocoder/geocodertest"
+)
+
+// test address
+const testAddress = "4600 Silver Hill Rd, Washington, DC 20233"
+
+// Send single and batch requests with client, then return results.
+func sendRequests(t *testing.T, c geocoder.Client) ([]geocoder.Match, []geocoder.BatchOutputRow) {
+  matches, err := c.LocationsFromBenchmark(testAddress, "2020")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  rows, err := c.BatchLocationsFromBenchmark([]geocoder.BatchInputRow {
+    { Id: "1", Address: "4600 silver hill rd", City: "washington", State: "dc" },
+    { Id: "2", Address: "nowhere" },
+  }, "2020")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  return matches, rows
+}
+
+func TestRecordReplay(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "cassettes", "test.json")
+
+  // start fake server
+  s := geocodertest.NewServer()
+  s.SetMatches("4600 silver hill rd, washington, dc", geocoder.Match { MatchedAddress: "4600 SILVER HILL RD, WASHINGTON, DC, 20233" })
+
+  // record requests
+  rec, err := New(path, ModeRecord)
+  if err != nil {
+    t.Fatal(err)
+  }
+  c := s.Client()
+  c.Client.Transport = rec
+  expMatches, expRows := sendRequests(t, c)
+  if err := rec.Save(); err != nil {
+    t.Fatal(err)
+  }
+  if got := len(rec.Interactions()); got != 2 {
+    t.Fatalf("got %d interactions, exp 2", got)
+  }
+
+  // stop server, replay requests
+  s.Close()
+  rep, err := New(path, ModeReplay)
+  if err != nil {
+    t.Fatal(err)
+  }
+  c.Client.Transport = rep
+  gotMatches, gotRows := sendRequests(t, c)
+
+  // check results
+  if !reflect.DeepEqual(gotMatches, expMatches) {
+    t.Fatalf("got %v, exp %v", gotMatches, expMatches)
+  }
+  if !reflect.DeepEqual(gotRows, expRows) {
+    t.Fatalf("got %v, exp %v", gotRows, expRows)
+  }
+
+  // check unmatched request
+  if _, err := c.LocationsFromBenchmark("nowhere", "2020"); !errors.Is(err, ErrNoInteraction) {
+    t.Fatalf("got %v, exp ErrNoInteraction", err)
+  }
+}
+
+func TestReplayOrder(t *testing.T) {
+  // record failure followed by success
+  s := geocodertest.NewServer()
+  defer s.Close()
+  s.Fail(geocodertest.Failure { Status: 503, Times: 1 })
+
+  path := filepath.Join(t.TempDir(), "test.json")
+  rec, err := New(path, ModeRecord)
+  if err != nil {
+    t.Fatal(err)
+  }
+  c := s.Client(geocoder.WithRetryPolicy(geocoder.RetryPolicy { MaxAttempts: 2, BaseDelay: 1 }))
+  c.Client.Transport = rec
+  if _, err := c.Locations(testAddress); err != nil {
+    t.Fatal(err)
+  }
+  if err := rec.Save(); err != nil {
+    t.Fatal(err)
+  }
+
+  // replay without retries: failure first, then success, then success
+  rep, err := New(path, ModeReplay)
+  if err != nil {
+    t.Fatal(err)
+  }
+  c.Retry = geocoder.RetryPolicy{}
+  c.Client.Transport = rep
+  for i, exp := range([]bool { false, true, true }) {
+    _, err := c.Locations(testAddress)
+    if (err == nil) != exp {
+      t.Fatalf("%d: got %v, exp success = %v", i, err, exp)
+    }
+  }
+}
+
+func TestPassthrough(t *testing.T) {
+  s := geocodertest.NewServer()
+  defer s.Close()
+
+  // replay empty cassette, pass requests to fake server through
+  rec := &Recorder { Mode: ModeReplay }
+  rec.Passthrough = func(req *http.Request) bool {
+    return req.URL.Host == s.BaseURL.Host
+  }
+
+  c := s.Client()
+  c.Client.Transport = rec
+  if _, err := c.Locations(testAddress); err != nil {
+    t.Fatal(err)
+  }
+  if len(rec.Interactions()) != 0 {
+    t.Fatal("got interactions, exp none")
+  }
+}
+
+func TestNewMissing(t *testing.T) {
+  if _, err := New(filepath.Join(t.TempDir(), "missing.json"), ModeReplay); err == nil {
+    t.Fatal("got success, exp error")
+  }
+}
@@ -2,12 +2,15 @@ package geocoder
 
 import (
   "bytes"
+  "context"
   "encoding/json"
-  "errors"
   "io"
+  "log"
   "mime/multipart"
   "net/http"
   net_url "net/url"
+  "strconv"
+  "strings"
 )
 
 // Census geocoder client.
@@ -17,22 +20,135 @@ type Client struct {
 
   // shared HTTP client
   Client http.Client
+
+  // HTTP client used instead of [Client.Client], if non-nil.  Set by
+  // [WithHTTPClient], so later changes to the given client (e.g. a new
+  // transport or timeout) apply to this client too.
+  HTTPClient *http.Client
+
+  // Application User-Agent, prepended to [DefaultUserAgent] in the
+  // User-Agent header of every request.
+  UserAgent string
+
+  // Benchmark used by methods without a benchmark parameter and when an
+  // empty benchmark is given.  If empty, then [DefaultBenchmark] is
+  // used.
+  Benchmark string
+
+  // Vintage used when an empty vintage is given.  If empty, then
+  // [DefaultVintage] is used.
+  Vintage string
+
+  // Geography layer IDs or names to request from geographies
+  // endpoints (e.g. "Census Tracts", "Counties", "54").  Use
+  // [AllLayers] to request every layer.
+  //
+  // Used when no layers are passed to a geographies method.  If empty,
+  // the API returns the default layers for the vintage.
+  Layers []string
+
+  // Maximum number of rows per batch upload.  Batch inputs with more
+  // rows are split into multiple uploads.
+  //
+  // If zero or greater than [MaxBatchSize], then [MaxBatchSize] is
+  // used.
+  BatchSize int
+
+  // Maximum number of concurrent batch uploads per batch call.
+  //
+  // If zero, then batch chunks are uploaded sequentially.
+  BatchConcurrency int
+
+  // Response cache.  If nil, then responses are not cached.
+  //
+  // Successful responses are cached by endpoint, normalized address or
+  // coordinates, benchmark, vintage, and layers.  Batch results are
+  // cached per row, and only uncached rows are uploaded.
+  Cache *Cache
+
+  // Retry policy for transient failures (e.g. timeouts and 502
+  // responses).  The zero value does not retry; see
+  // [DefaultRetryPolicy].
+  //
+  // Batch chunks are retried individually, and the full chunk is
+  // re-sent on each attempt.
+  Retry RetryPolicy
+
+  // Rate limiter for requests and batch uploads.  If nil, then
+  // requests are not limited.
+  //
+  // Share a limiter between clients to give them a combined limit
+  // (e.g. set it to `DefaultClient.Limiter` to share the limit of the
+  // top-level functions).
+  Limiter *Limiter
+
+  // Logger for retry and cache events (e.g. retried requests, cache
+  // hits, and failed cache writes).  If nil, then nothing is logged.
+  //
+  // Use [LoggingMiddleware] to log every request.
+  Logger *log.Logger
+}
+
+// Get HTTP client used to send requests.
+func (c Client) httpClient() *http.Client {
+  if c.HTTPClient != nil {
+    return c.HTTPClient
+  }
+
+  return &c.Client
 }
 
-// Create new geocoder client from URL.
+// Write message to [Client.Logger], if any.
+func (c Client) logf(format string, args ...any) {
+  if c.Logger != nil {
+    c.Logger.Printf(format, args...)
+  }
+}
+
+// Special layer name which requests every available geography layer.
+const AllLayers = "all"
+
+// Reader which fails with the context error once the context is done.
 //
-// Note: This method is primarily for testing.  You should be able to
-// use the top-level functions from the [geocoder] package (e.g.
-// [geocoder.Locations], [geocoder.Geographies], etc).
-func NewClient(url *net_url.URL) Client {
-  return Client { Url: url }
+// Used to stop decoding response bodies when a request is cancelled.
+type contextReader struct {
+  ctx context.Context // context
+  r io.Reader // wrapped reader
+}
+
+// Read from wrapped reader if context is not done.
+func (cr contextReader) Read(p []byte) (int, error) {
+  if err := cr.ctx.Err(); err != nil {
+    return 0, err
+  }
+
+  return cr.r.Read(p)
 }
 
 // Build request, send to API endpoint, and parse response.
-func (c Client) get(path string, args map[string]string, cb func(*json.Decoder) error) error {
+//
+// If the client has a [Client.Cache] with a response for the request,
+// then the cached response is parsed instead.
+//
+// Returns an [APIError] if the response has a non-success status, the
+// response contains an `errors` array, or the response body could not
+// be decoded.
+func (c Client) get(ctx context.Context, path string, args map[string]string, cb func(*json.Decoder) error) error {
   // build url
   url := c.Url.JoinPath(path)
 
+  // check cache
+  var key string
+  if c.Cache != nil {
+    key = cacheKey(url.String(), args)
+    if body, ok := c.Cache.Get(key); ok {
+      if err := cb(json.NewDecoder(bytes.NewReader(body))); err == nil {
+        c.logf("geocoder: cache hit: %s", path)
+        return nil
+      }
+    }
+  }
+
   // build query parameters
   q := net_url.Values{}
   for k, v := range(args) {
@@ -41,26 +157,133 @@ func (c Client) get(path string, args map[string]string, cb func(*json.Decoder)
   url.RawQuery = q.Encode()
 
   // fetch response
-  resp, err := c.Client.Get(url.String())
+  resp, err := c.do(ctx, func() (*http.Request, error) {
+    return http.NewRequestWithContext(ctx, "GET", url.String(), nil)
+  })
   if err != nil {
     return err
   }
   defer resp.Body.Close()
 
+  // read response body
+  body, err := io.ReadAll(contextReader { ctx, resp.Body })
+  if err != nil {
+    return err
+  }
+
+  // decode API error messages
+  var r struct {
+    Errors []string `json:"errors"`
+  }
+  jsonErr := json.Unmarshal(body, &r)
+
+  // check for errors
+  if resp.StatusCode != http.StatusOK || jsonErr != nil || len(r.Errors) > 0 {
+    // ignore decoding errors for non-success responses
+    if resp.StatusCode != http.StatusOK {
+      jsonErr = nil
+    }
+
+    return &APIError {
+      StatusCode: resp.StatusCode,
+      Endpoint: path,
+      Params: args,
+      Errors: r.Errors,
+      Body: apiErrorBody(body),
+      Header: resp.Header,
+      Err: jsonErr,
+    }
+  }
+
   // create decoder from response body, call handler
-  return cb(json.NewDecoder(resp.Body))
+  if err := cb(json.NewDecoder(bytes.NewReader(body))); err != nil {
+    return &APIError {
+      StatusCode: resp.StatusCode,
+      Endpoint: path,
+      Params: args,
+      Body: apiErrorBody(body),
+      Header: resp.Header,
+      Err: err,
+    }
+  }
+
+  // cache response (errors are logged but not returned because the
+  // cache is only an optimization)
+  if c.Cache != nil {
+    if err := c.Cache.Set(key, body); err != nil {
+      c.logf("geocoder: cache write failed: %s: %v", path, err)
+    }
+  }
+
+  // return success
+  return nil
+}
+
+// Get given benchmark, or the default benchmark if the given benchmark
+// is empty.
+func (c Client) benchmark(benchmark string) string {
+  switch {
+  case benchmark != "":
+    return benchmark
+  case c.Benchmark != "":
+    return c.Benchmark
+  default:
+    return DefaultBenchmark
+  }
+}
+
+// Get given vintage, or the default vintage if the given vintage is
+// empty.
+func (c Client) vintage(vintage string) string {
+  switch {
+  case vintage != "":
+    return vintage
+  case c.Vintage != "":
+    return c.Vintage
+  default:
+    return DefaultVintage
+  }
+}
+
+// Get User-Agent header value.
+func (c Client) userAgent() string {
+  if c.UserAgent != "" {
+    return c.UserAgent + " " + DefaultUserAgent
+  }
+
+  return DefaultUserAgent
+}
+
+// Add layers parameter to geographies query parameters.
+//
+// The given layers are used if there are any; otherwise the client
+// layers are used.  Does nothing if neither has any layers.
+func (c Client) addLayers(args map[string]string, layers []string) map[string]string {
+  if len(layers) == 0 {
+    layers = c.Layers
+  }
+
+  if len(layers) > 0 {
+    args["layers"] = strings.Join(layers, ",")
+  }
+
+  return args
 }
 
 // Get available benchmarks.
 func (c Client) Benchmarks() ([]Benchmark, error) {
+  return c.BenchmarksContext(context.Background())
+}
+
+// Get available benchmarks using given context.
+func (c Client) BenchmarksContext(ctx context.Context) ([]Benchmark, error) {
   var r struct {
     Benchmarks []Benchmark `json:"benchmarks"`
-		Errors []string `json:"errors"`
   }
 
   // send request, parse response
   args := map[string]string{}
-  err := c.get("benchmarks", args, func(d *json.Decoder) error {
+  err := c.get(ctx, "benchmarks", args, func(d *json.Decoder) error {
     return d.Decode(&r)
   })
 
@@ -69,25 +292,24 @@ func (c Client) Benchmarks() ([]Benchmark, error) {
     return []Benchmark{}, err
   }
 
-  // check for errors in decoded response
-  if len(r.Errors) > 0 {
-    return []Benchmark{}, errors.New(r.Errors[0])
-  }
-
   // return result
   return r.Benchmarks, nil
 }
 
 // Get vintages matching benchmark ID.
 func (c Client) Vintages(benchmarkId string) ([]Vintage, error) {
+  return c.VintagesContext(context.Background(), benchmarkId)
+}
+
+// Get vintages matching benchmark ID using given context.
+func (c Client) VintagesContext(ctx context.Context, benchmarkId string) ([]Vintage, error) {
   var r struct {
     Vintages []Vintage `json:"vintages"`
-		Errors []string `json:"errors"`
   }
 
   // send request, parse response
-  err := c.get("vintages", map[string]string {
-    "benchmark": benchmarkId,
+  err := c.get(ctx, "vintages", map[string]string {
+    "benchmark": c.benchmark(benchmarkId),
   }, func(d *json.Decoder) error {
     return d.Decode(&r)
   })
@@ -97,32 +319,22 @@ func (c Client) Vintages(benchmarkId string) ([]Vintage, error) {
     return []Vintage{}, err
   }
 
-  // check for errors in decoded response
-  if len(r.Errors) > 0 {
-    return []Vintage{}, errors.New(r.Errors[0])
-  }
-
   // return result
   return r.Vintages, nil
 }
 
-// Geocode street address with given benchmark ID return address
-// matches.
-func (c Client) LocationsFromBenchmark(address, benchmarkId string) ([]Match, error) {
+// Send request to address match API endpoint, then decode and return
+// address matches.
+func (c Client) getMatches(ctx context.Context, path string, args map[string]string) ([]Match, error) {
   var r struct {
     Result struct {
       Matches []Match `json:"addressMatches"`
     } `json:"result"`
 
-		Errors []string `json:"errors"`
   }
 
   // send request, decode response
-  err := c.get("locations/onelineaddress", map[string]string {
-    "address": address,
-    "benchmark": benchmarkId,
-    "format": "json",
-  }, func(d *json.Decoder) error {
+  err := c.get(ctx, path, args, func(d *json.Decoder) error {
     return d.Decode(&r)
   })
 
@@ -131,130 +343,300 @@ func (c Client) LocationsFromBenchmark(address, benchmarkId string) ([]Match, er
     return []Match{}, err
   }
 
-  // check for errors in decoded response
-  if len(r.Errors) > 0 {
-    return []Match{}, errors.New(r.Errors[0])
-  }
-
   // return result
   return r.Result.Matches, nil
 }
 
+// Geocode street address with given benchmark ID return address
+// matches.
+func (c Client) LocationsFromBenchmark(address, benchmarkId string) ([]Match, error) {
+  return c.LocationsFromBenchmarkContext(context.Background(), address, benchmarkId)
+}
+
+// Geocode street address with given context and benchmark ID, then
+// return address matches.
+func (c Client) LocationsFromBenchmarkContext(ctx context.Context, address, benchmarkId string) ([]Match, error) {
+  return c.getMatches(ctx, "locations/onelineaddress", map[string]string {
+    "address": address,
+    "benchmark": c.benchmark(benchmarkId),
+    "format": "json",
+  })
+}
+
 // Geocode street address and return address matches.
 func (c Client) Locations(address string) ([]Match, error) {
-  return c.LocationsFromBenchmark(address, DefaultBenchmark)
+  return c.LocationsContext(context.Background(), address)
+}
+
+// Geocode street address with given context and return address
+// matches.
+func (c Client) LocationsContext(ctx context.Context, address string) ([]Match, error) {
+  return c.LocationsFromBenchmarkContext(ctx, address, c.benchmark(""))
 }
 
 // Geocode street address using  given benchmark and given vintage, then
 // return address matches with geography layers.
-func (c Client) Geographies(address, benchmark, vintage string) ([]Match, error) {
+//
+// If layers are given, then only the given geography layers are
+// returned.  Otherwise [Client.Layers] is used.
+func (c Client) Geographies(address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  return c.GeographiesContext(context.Background(), address, benchmark, vintage, layers...)
+}
+
+// Geocode street address using given context, given benchmark, and
+// given vintage, then return address matches with geography layers.
+func (c Client) GeographiesContext(ctx context.Context, address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  return c.getMatches(ctx, "geographies/onelineaddress", c.addLayers(map[string]string {
+    "address": address,
+    "benchmark": c.benchmark(benchmark),
+    "vintage": c.vintage(vintage),
+    "format": "json",
+  }, layers))
+}
+
+// Geocode structured address with given benchmark ID and return
+// address matches.
+func (c Client) AddressLocationsFromBenchmark(address Address, benchmarkId string) ([]Match, error) {
+  return c.AddressLocationsFromBenchmarkContext(context.Background(), address, benchmarkId)
+}
+
+// Geocode structured address with given context and benchmark ID, then
+// return address matches.
+func (c Client) AddressLocationsFromBenchmarkContext(ctx context.Context, address Address, benchmarkId string) ([]Match, error) {
+  // build query parameters
+  args := address.args()
+  args["benchmark"] = c.benchmark(benchmarkId)
+  args["format"] = "json"
+
+  // send request, return matches
+  return c.getMatches(ctx, "locations/address", args)
+}
+
+// Geocode structured address and return address matches.
+func (c Client) AddressLocations(address Address) ([]Match, error) {
+  return c.AddressLocationsContext(context.Background(), address)
+}
+
+// Geocode structured address with given context and return address
+// matches.
+func (c Client) AddressLocationsContext(ctx context.Context, address Address) ([]Match, error) {
+  return c.AddressLocationsFromBenchmarkContext(ctx, address, c.benchmark(""))
+}
+
+// Geocode structured address using given benchmark and given vintage,
+// then return address matches with geography layers.
+//
+// If layers are given, then only the given geography layers are
+// returned.  Otherwise [Client.Layers] is used.
+func (c Client) AddressGeographies(address Address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  return c.AddressGeographiesContext(context.Background(), address, benchmark, vintage, layers...)
+}
+
+// Geocode structured address using given context, given benchmark, and
+// given vintage, then return address matches with geography layers.
+func (c Client) AddressGeographiesContext(ctx context.Context, address Address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  // build query parameters
+  args := address.args()
+  args["benchmark"] = c.benchmark(benchmark)
+  args["vintage"] = c.vintage(vintage)
+  args["format"] = "json"
+
+  // send request, return matches
+  return c.getMatches(ctx, "geographies/address", c.addLayers(args, layers))
+}
+
+// Get geography layers for given coordinates using given benchmark
+// and given vintage.
+//
+// If layers are given, then only the given geography layers are
+// returned.  Otherwise [Client.Layers] is used.
+func (c Client) GeographiesFromCoordinates(coords Coordinates, benchmark, vintage string, layers ...string) (GeographyLayers, error) {
+  return c.GeographiesFromCoordinatesContext(context.Background(), coords, benchmark, vintage, layers...)
+}
+
+// Get geography layers for given coordinates using given context, given
+// benchmark, and given vintage.
+func (c Client) GeographiesFromCoordinatesContext(ctx context.Context, coords Coordinates, benchmark, vintage string, layers ...string) (GeographyLayers, error) {
   var r struct {
     Result struct {
-      Matches []Match `json:"addressMatches"`
+      Geographies GeographyLayers `json:"geographies"`
     } `json:"result"`
 
-		Errors []string `json:"errors"`
   }
 
   // send request, decode response
-  err := c.get("geographies/onelineaddress", map[string]string {
-    "address": address,
-    "benchmark": benchmark,
-    "vintage": vintage,
+  err := c.get(ctx, "geographies/coordinates", c.addLayers(map[string]string {
+    "x": strconv.FormatFloat(coords.X, 'f', -1, 64),
+    "y": strconv.FormatFloat(coords.Y, 'f', -1, 64),
+    "benchmark": c.benchmark(benchmark),
+    "vintage": c.vintage(vintage),
     "format": "json",
-  }, func(d *json.Decoder) error {
+  }, layers), func(d *json.Decoder) error {
     return d.Decode(&r)
   })
 
   // check for request errors
   if err != nil {
-    return []Match{}, err
-  }
-
-  // check for errors in decoded response
-  if len(r.Errors) > 0 {
-    return []Match{}, errors.New(r.Errors[0])
+    return GeographyLayers{}, err
   }
 
   // return result
-  return r.Result.Matches, nil
+  return r.Result.Geographies, nil
 }
 
-// Encode batch input rows and field values as multipart body and write
-// it to the given writer.
-func createBatchBody(w io.Writer, rows []BatchInputRow, fields map[string]string) (string, error) {
-  // create multipart writer
-  mw := multipart.NewWriter(w)
-
+// Encode batch input rows and field values as multipart body using
+// the given multipart writer.
+func writeBatchBody(mw *multipart.Writer, rows []BatchInputRow, fields map[string]string) error {
   // populate form fields
   for k, v := range(fields) {
     if err := mw.WriteField(k, v); err != nil {
-      return "", err
+      return err
     }
   }
 
   // attach address file
   f, err := mw.CreateFormFile("addressFile", "input.csv")
   if err != nil {
-    return "", err
+    return err
   }
 
   // write input rows to multipart writer as CSV
   biw := NewBatchInputWriter(f)
-  if err := biw.WriteAll(rows); err != nil {
-    return "", err
+  for _, row := range(rows) {
+    if err := biw.Write(row); err != nil {
+      return err
+    }
   }
 
-  // get content type
-  contentType := mw.FormDataContentType()
+  // flush rows
+  if err := biw.Flush(); err != nil {
+    return err
+  }
 
   // close multipart writer
-  return contentType, mw.Close()
+  return mw.Close()
 }
 
-// Upload input addresses to batch geocoder.
-func (c Client) batchUpload(rows []BatchInputRow, returnType string, fields map[string]string) ([]BatchOutputRow, error) {
-  // populate buffer with multipart-encoded request body
-  var buf bytes.Buffer
-  contentType, err := createBatchBody(&buf, rows, fields)
-  if err != nil {
+// Create streaming multipart request body from batch input rows and
+// field values, then return body reader and content type.
+//
+// The body is encoded in a separate goroutine and written to a pipe,
+// so the whole body is never buffered in memory.  Closing the returned
+// reader stops the encoding goroutine.
+func newBatchBody(rows []BatchInputRow, fields map[string]string) (io.ReadCloser, string) {
+  pr, pw := io.Pipe()
+  mw := multipart.NewWriter(pw)
+
+  // encode body in background
+  go func() {
+    pw.CloseWithError(writeBatchBody(mw, rows, fields))
+  }()
+
+  // return reader and content type
+  return pr, mw.FormDataContentType()
+}
+
+// Upload single chunk of input addresses to batch geocoder.
+//
+// Returns an [APIError] if the response has a non-success status or is
+// an HTML error page.
+func (c Client) batchUploadChunk(ctx context.Context, rows []BatchInputRow, returnType string, fields map[string]string) ([]BatchOutputRow, error) {
+  // wait for upload slot
+  if err := c.Limiter.acquireUpload(ctx); err != nil {
     return []BatchOutputRow{}, err
   }
+  defer c.Limiter.releaseUpload()
 
   // build url
   url := c.Url.JoinPath(returnType, "addressbatch")
 
-  // create request
-  req, err := http.NewRequest("POST", url.String(), &buf)
-  if err != nil {
-    return []BatchOutputRow{}, err
-  }
+  // send request; a new streaming body is created for each attempt
+  resp, err := c.do(ctx, func() (*http.Request, error) {
+    // create streaming multipart-encoded request body
+    body, contentType := newBatchBody(rows, fields)
 
-  // set request headers
-  req.Header.Add("Content-Type", contentType)
+    // create request
+    req, err := http.NewRequestWithContext(ctx, "POST", url.String(), body)
+    if err != nil {
+      body.Close()
+      return nil, err
+    }
 
-  // send request
-  resp, err := c.Client.Do(req)
+    // set request headers
+    req.Header.Add("Content-Type", contentType)
+    return req, nil
+  })
   if err != nil {
     return []BatchOutputRow{}, err
   }
   defer resp.Body.Close()
 
+  // check for non-success status or HTML error page
+  contentType := resp.Header.Get("Content-Type")
+  if resp.StatusCode != http.StatusOK || strings.HasPrefix(contentType, "text/html") {
+    // read start of response body (one extra byte so apiErrorBody()
+    // can tell whether the last character was cut off)
+    body, err := io.ReadAll(io.LimitReader(contextReader { ctx, resp.Body }, apiErrorBodySize + 1))
+    if err != nil {
+      return []BatchOutputRow{}, err
+    }
+
+    // decode API error messages, if any (decoding errors are ignored
+    // because the body may be truncated or may not be JSON)
+    var r struct {
+      Errors []string `json:"errors"`
+    }
+    _ = json.Unmarshal(body, &r)
+
+    return []BatchOutputRow{}, &APIError {
+      StatusCode: resp.StatusCode,
+      Endpoint: returnType + "/addressbatch",
+      Params: fields,
+      Errors: r.Errors,
+      Body: apiErrorBody(body),
+      Header: resp.Header,
+    }
+  }
+
   // read rows from response
-  return NewBatchOutputReader(resp.Body).ReadAll()
+  return NewBatchOutputReader(contextReader { ctx, resp.Body }).ReadAll()
 }
 
 // Batch geocode street addresses with given benchmark then return
 // matches.
+//
+// Inputs with more than [Client.BatchSize] rows are split into
+// multiple uploads.
+//
+// Results are returned in the same order as the input rows.  If any
+// input rows are missing from the results, or if the results contain
+// duplicate or unexpected rows, then the reconciled results are
+// returned along with a [BatchReportError].
+//
+// If an upload fails, then the results of the uploads which succeeded
+// are returned along with a [BatchChunkError].  Use
+// [ReconcileBatchOutput] to find the rows which need to be resent.
 func (c Client) BatchLocationsFromBenchmark(rows []BatchInputRow, benchmark string) ([]BatchOutputRow, error) {
-  return c.batchUpload(rows, "locations", map[string]string {
-    "benchmark": benchmark,
+  return c.BatchLocationsFromBenchmarkContext(context.Background(), rows, benchmark)
+}
+
+// Batch geocode street addresses with given context and benchmark then
+// return matches.
+func (c Client) BatchLocationsFromBenchmarkContext(ctx context.Context, rows []BatchInputRow, benchmark string) ([]BatchOutputRow, error) {
+  return c.batchUpload(ctx, rows, "locations", map[string]string {
+    "benchmark": c.benchmark(benchmark),
   })
 }
 
 // Batch geocode street addresses then return matches.
 func (c Client) BatchLocations(rows []BatchInputRow) ([]BatchOutputRow, error) {
-  return c.BatchLocationsFromBenchmark(rows, DefaultBenchmark)
+  return c.BatchLocationsContext(context.Background(), rows)
+}
+
+// Batch geocode street addresses with given context then return
+// matches.
+func (c Client) BatchLocationsContext(ctx context.Context, rows []BatchInputRow) ([]BatchOutputRow, error) {
+  return c.BatchLocationsFromBenchmarkContext(ctx, rows, c.benchmark(""))
 }
 
 // Batch geocode street addresses with given benchmark and vintage then
@@ -267,9 +649,30 @@ func (c Client) BatchLocations(rows []BatchInputRow) ([]BatchOutputRow, error) {
 // - County
 // - Tract
 // - Block
+//
+// Inputs with more than [Client.BatchSize] rows are split into
+// multiple uploads.
+//
+// Results are returned in the same order as the input rows.  If any
+// input rows are missing from the results, or if the results contain
+// duplicate or unexpected rows, then the reconciled results are
+// returned along with a [BatchReportError].
+//
+// If an upload fails, then the results of the uploads which succeeded
+// are returned along with a [BatchChunkError].  Use
+// [ReconcileBatchOutput] to find the rows which need to be resent.
 func (c Client) BatchGeographies(rows []BatchInputRow, benchmark, vintage string) ([]BatchOutputRow, error) {
-  return c.batchUpload(rows, "geographies", map[string]string {
-    "benchmark": benchmark,
-    "vintage": vintage,
+  return c.BatchGeographiesContext(context.Background(), rows, benchmark, vintage)
+}
+
+// Batch geocode street addresses with given context, benchmark, and
+// vintage then return matches with additional geography fields.
+//
+// See [Client.BatchGeographies] for a list of the additional
+// BatchOutputRow fields populated by this method.
+func (c Client) BatchGeographiesContext(ctx context.Context, rows []BatchInputRow, benchmark, vintage string) ([]BatchOutputRow, error) {
+  return c.batchUpload(ctx, rows, "geographies", map[string]string {
+    "benchmark": c.benchmark(benchmark),
+    "vintage": c.vintage(vintage),
   })
 }
@@ -1,10 +1,17 @@
 package geocoder
 
 import (
+  "context"
   _ "embed"
   "encoding/json"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  net_url "net/url"
+  "os"
   "reflect"
   "testing"
+  "time"
 )
 
 func TestClientBenchmarks(t *testing.T) {
@@ -22,7 +29,7 @@ func TestClientBenchmarks(t *testing.T) {
   }
 
   // create client
-  c := NewClient(url)
+  c := NewClient(WithBaseURL(url))
 
   // create benchmarks
   got, err := c.Benchmarks()
@@ -51,7 +58,7 @@ func TestClientVintages(t *testing.T) {
   }
 
   // create client
-  c := NewClient(url)
+  c := NewClient(WithBaseURL(url))
 
   // get vintages, check for error
   got, err := c.Vintages(testBenchmarkId)
@@ -80,7 +87,7 @@ func TestClientLocationsFromBenchmark(t *testing.T) {
   }
 
   // create client
-  c := NewClient(url)
+  c := NewClient(WithBaseURL(url))
 
   // get locations, check for error
   got, err := c.LocationsFromBenchmark(testAddress, testBenchmarkId)
@@ -109,7 +116,7 @@ func TestClientLocations(t *testing.T) {
   }
 
   // create client
-  c := NewClient(url)
+  c := NewClient(WithBaseURL(url))
 
   // get locations, check for error
   got, err := c.Locations(testAddress)
@@ -142,7 +149,7 @@ func TestClientGeographies(t *testing.T) {
   }
 
   // create client
-  c := NewClient(url)
+  c := NewClient(WithBaseURL(url))
 
   // get geographies, check for error
   got, err := c.Geographies(testAddress, testBenchmark, testVintage)
@@ -159,7 +166,7 @@ func TestClientGeographies(t *testing.T) {
 func TestClientBatchLocationsFromBenchmark(t *testing.T) {
   // get input and expected output
   rows := getBatchInputRows(t)
-  exp := getBatchOutputRows(t, "testdata/data/batch-output-locations-2020.csv")
+  exp := orderBatchOutputRows(t, rows, getBatchOutputRows(t, "testdata/data/batch-output-locations-2020.csv"))
 
   // create mock server
   ms, url, err := newMockServer()
@@ -169,7 +176,7 @@ func TestClientBatchLocationsFromBenchmark(t *testing.T) {
   defer ms.Close()
 
   // create client
-  c := NewClient(url)
+  c := NewClient(WithBaseURL(url))
 
   // send rows, check for error
   got, err := c.BatchLocationsFromBenchmark(rows, testBenchmarkId)
@@ -202,7 +209,7 @@ func TestClientBatchLocations(t *testing.T) {
   defer ms.Close()
 
   // create client
-  c := NewClient(url)
+  c := NewClient(WithBaseURL(url))
 
   // send rows, check for error
   gotRows, err := c.BatchLocations(rows)
@@ -221,7 +228,7 @@ func TestClientBatchLocations(t *testing.T) {
 func TestClientBatchGeographies(t *testing.T) {
   // get input and expected output
   rows := getBatchInputRows(t)
-  exp := getBatchOutputRows(t, "testdata/data/batch-output-geographies-2020-2020.csv")
+  exp := orderBatchOutputRows(t, rows, getBatchOutputRows(t, "testdata/data/batch-output-geographies-2020-2020.csv"))
 
   // create mock server
   ms, url, err := newMockServer()
@@ -231,7 +238,7 @@ func TestClientBatchGeographies(t *testing.T) {
   defer ms.Close()
 
   // create client
-  c := NewClient(url)
+  c := NewClient(WithBaseURL(url))
 
   // send rows, check for error
   got, err := c.BatchGeographies(rows, "2020", "2020")
@@ -244,3 +251,539 @@ func TestClientBatchGeographies(t *testing.T) {
     t.Fatalf("got %v, exp %v", got, exp)
   }
 }
+
+func TestClientAddressLocationsFromBenchmark(t *testing.T) {
+  // create mock server
+  ms, url, err := newMockServer()
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ms.Close()
+
+  // decode expected results
+  var exp []Match
+  if err = json.Unmarshal(mockLocationsJson, &exp); err != nil {
+    t.Fatal(err)
+  }
+
+  // create client
+  c := NewClient(WithBaseURL(url))
+
+  // get locations, check for error
+  got, err := c.AddressLocationsFromBenchmark(testStructuredAddress, testBenchmarkId)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // compare against expected value
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestClientAddressLocations(t *testing.T) {
+  // create mock server
+  ms, url, err := newMockServer()
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ms.Close()
+
+  // decode expected results
+  var exp []Match
+  if err = json.Unmarshal(mockLocationsJson, &exp); err != nil {
+    t.Fatal(err)
+  }
+
+  // create client
+  c := NewClient(WithBaseURL(url))
+
+  // get locations, check for error
+  got, err := c.AddressLocations(testStructuredAddress)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // compare against expected value
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestClientAddressGeographies(t *testing.T) {
+  testBenchmark := "Public_AR_Census2020"
+  testVintage := "Census2010_Census2020"
+
+  // create mock server
+  ms, url, err := newMockServer()
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ms.Close()
+
+  // decode expected results
+  var exp []Match
+  if err = json.Unmarshal(mockGeographiesJson, &exp); err != nil {
+    t.Fatal(err)
+  }
+
+  // create client
+  c := NewClient(WithBaseURL(url))
+
+  // get geographies, check for error
+  got, err := c.AddressGeographies(testStructuredAddress, testBenchmark, testVintage)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // compare against expected value
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestClientAddressQuery(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    address Address // address
+    exp map[string]string // expected address parameters
+  } {{
+    name: "full",
+    address: testStructuredAddress,
+    exp: map[string]string {
+      "street": "4600 Silver Hill Rd",
+      "city": "Washington",
+      "state": "DC",
+      "zip": "20233",
+    },
+  }, {
+    name: "street only",
+    address: Address { Street: "4600 Silver Hill Rd" },
+    exp: map[string]string { "street": "4600 Silver Hill Rd" },
+  }, {
+    name: "street and zip",
+    address: Address { Street: "4600 Silver Hill Rd", Zip: "20233" },
+    exp: map[string]string { "street": "4600 Silver Hill Rd", "zip": "20233" },
+  }, {
+    name: "street, city, and state",
+    address: Address { Street: "4600 Silver Hill Rd", City: "Washington", State: "DC" },
+    exp: map[string]string { "street": "4600 Silver Hill Rd", "city": "Washington", "state": "DC" },
+  }}
+
+  // methods, expected paths, and mock responses
+  methods := []struct {
+    name string // method name
+    path string // expected URL path
+    dataPath string // mock response
+    fn func(Client, Address) error // send request
+  } {{
+    name: "AddressLocationsFromBenchmark",
+    path: "/locations/address",
+    dataPath: "geocodertest/fixtures/address-locations.json",
+    fn: func(c Client, a Address) error {
+      _, err := c.AddressLocationsFromBenchmark(a, testBenchmarkId)
+      return err
+    },
+  }, {
+    name: "AddressLocations",
+    path: "/locations/address",
+    dataPath: "geocodertest/fixtures/address-locations.json",
+    fn: func(c Client, a Address) error {
+      _, err := c.AddressLocations(a)
+      return err
+    },
+  }, {
+    name: "AddressGeographies",
+    path: "/geographies/address",
+    dataPath: "geocodertest/fixtures/address-geographies.json",
+    fn: func(c Client, a Address) error {
+      _, err := c.AddressGeographies(a, "2020", "2020")
+      return err
+    },
+  }}
+
+  for _, method := range(methods) {
+    // read mock response
+    data, err := os.ReadFile(method.dataPath)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    for _, test := range(tests) {
+      t.Run(method.name + "/" + test.name, func(t *testing.T) {
+        // create server which records path and query
+        var gotPath string
+        var gotQuery net_url.Values
+        ms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+          gotPath, gotQuery = r.URL.Path, r.URL.Query()
+          w.Write(data)
+        }))
+        defer ms.Close()
+
+        // parse server URL
+        url, err := net_url.Parse(ms.URL)
+        if err != nil {
+          t.Fatal(err)
+        }
+
+        // send request
+        if err := method.fn(NewClient(WithBaseURL(url)), test.address); err != nil {
+          t.Fatal(err)
+        }
+
+        // check path
+        if gotPath != method.path {
+          t.Fatalf("got path %q, exp %q", gotPath, method.path)
+        }
+
+        // check address parameters; empty components must be omitted
+        got := map[string]string {}
+        for _, k := range([]string { "street", "city", "state", "zip" }) {
+          if vals, ok := gotQuery[k]; ok {
+            got[k] = vals[0]
+          }
+        }
+        if !reflect.DeepEqual(got, test.exp) {
+          t.Fatalf("got %v, exp %v", got, test.exp)
+        }
+      })
+    }
+  }
+}
+
+func TestClientGeographiesFromCoordinates(t *testing.T) {
+  // create mock server
+  ms, url, err := newMockServer()
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ms.Close()
+
+  // decode expected results
+  var exp GeographyLayers
+  if err = json.Unmarshal(mockCoordinatesJson, &exp); err != nil {
+    t.Fatal(err)
+  }
+
+  // create client
+  c := NewClient(WithBaseURL(url))
+
+  // get geographies, check for error
+  got, err := c.GeographiesFromCoordinates(testCoordinates, "2020", "2020")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // compare against expected value
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestClientLayers(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    layers []string // client layers
+    exp string // expected layers parameter
+    dataPath string // mock response path
+    fn func(Client) error // test function
+  } {{
+    name: "Geographies",
+    layers: []string { "Census Tracts", "Counties", "54" },
+    exp: "Census Tracts,Counties,54",
+    dataPath: "geocodertest/fixtures/geographies.json",
+    fn: func(c Client) error {
+      _, err := c.Geographies(testAddress, testBenchmarkId, "4")
+      return err
+    },
+  }, {
+    name: "AddressGeographies",
+    layers: []string { AllLayers },
+    exp: "all",
+    dataPath: "geocodertest/fixtures/address-geographies.json",
+    fn: func(c Client) error {
+      _, err := c.AddressGeographies(testStructuredAddress, testBenchmarkId, "4")
+      return err
+    },
+  }, {
+    name: "GeographiesFromCoordinates",
+    layers: []string { "Counties" },
+    exp: "Counties",
+    dataPath: "geocodertest/fixtures/coordinates.json",
+    fn: func(c Client) error {
+      _, err := c.GeographiesFromCoordinates(testCoordinates, "2020", "2020")
+      return err
+    },
+  }, {
+    name: "per-call Geographies",
+    exp: "Census Tracts,Counties",
+    dataPath: "geocodertest/fixtures/geographies.json",
+    fn: func(c Client) error {
+      _, err := c.Geographies(testAddress, testBenchmarkId, "4", "Census Tracts", "Counties")
+      return err
+    },
+  }, {
+    name: "per-call AddressGeographies overrides client",
+    layers: []string { "Counties" },
+    exp: "54",
+    dataPath: "geocodertest/fixtures/address-geographies.json",
+    fn: func(c Client) error {
+      _, err := c.AddressGeographies(testStructuredAddress, testBenchmarkId, "4", "54")
+      return err
+    },
+  }, {
+    name: "per-call GeographiesFromCoordinatesContext",
+    layers: []string { "Counties" },
+    exp: "all",
+    dataPath: "geocodertest/fixtures/coordinates.json",
+    fn: func(c Client) error {
+      _, err := c.GeographiesFromCoordinatesContext(context.Background(), testCoordinates, "2020", "2020", AllLayers)
+      return err
+    },
+  }, {
+    name: "per-call through Wrap",
+    exp: "Counties",
+    dataPath: "geocodertest/fixtures/geographies.json",
+    fn: func(c Client) error {
+      _, err := Wrap(c, CacheMiddleware(&Cache{})).GeographiesContext(context.Background(), testAddress, testBenchmarkId, "4", "Counties")
+      return err
+    },
+  }, {
+    name: "no layers",
+    exp: "",
+    dataPath: "geocodertest/fixtures/geographies.json",
+    fn: func(c Client) error {
+      _, err := c.Geographies(testAddress, testBenchmarkId, "4")
+      return err
+    },
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // read mock response
+      data, err := os.ReadFile(test.dataPath)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      // create server which records layers parameter
+      var got string
+      var gotOk bool
+      ms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        vals, ok := r.URL.Query()["layers"]
+        gotOk = ok
+        if ok {
+          got = vals[0]
+        }
+        w.Write(data)
+      }))
+      defer ms.Close()
+
+      // parse server URL
+      url, err := net_url.Parse(ms.URL)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      // create client
+      c := NewClient(WithBaseURL(url))
+      c.Layers = test.layers
+
+      // send request
+      if err := test.fn(c); err != nil {
+        t.Fatal(err)
+      }
+
+      // check layers parameter
+      if got != test.exp || gotOk != (test.exp != "") {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestClientBenchmarksContext(t *testing.T) {
+  // create mock server
+  ms, url, err := newMockServer()
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ms.Close()
+
+  // decode expected results
+  var exp []Benchmark
+  if err := json.Unmarshal(mockBenchmarksJson, &exp); err != nil {
+    t.Fatal(err)
+  }
+
+  // create client
+  c := NewClient(WithBaseURL(url))
+
+  // create context
+  ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Second)
+  defer cancel()
+
+  // get benchmarks
+  got, err := c.BenchmarksContext(ctx)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // compare against expected value
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestClientContextDeadline(t *testing.T) {
+  rows := getBatchInputRows(t)
+
+  tests := []struct {
+    name string // test name
+    fn func(context.Context, Client) error // test function
+  } {{
+    name: "BenchmarksContext",
+    fn: func(ctx context.Context, c Client) error {
+      _, err := c.BenchmarksContext(ctx)
+      return err
+    },
+  }, {
+    name: "VintagesContext",
+    fn: func(ctx context.Context, c Client) error {
+      _, err := c.VintagesContext(ctx, testBenchmarkId)
+      return err
+    },
+  }, {
+    name: "LocationsContext",
+    fn: func(ctx context.Context, c Client) error {
+      _, err := c.LocationsContext(ctx, testAddress)
+      return err
+    },
+  }, {
+    name: "GeographiesContext",
+    fn: func(ctx context.Context, c Client) error {
+      _, err := c.GeographiesContext(ctx, testAddress, "2020", "2020")
+      return err
+    },
+  }, {
+    name: "AddressLocationsContext",
+    fn: func(ctx context.Context, c Client) error {
+      _, err := c.AddressLocationsContext(ctx, testStructuredAddress)
+      return err
+    },
+  }, {
+    name: "AddressGeographiesContext",
+    fn: func(ctx context.Context, c Client) error {
+      _, err := c.AddressGeographiesContext(ctx, testStructuredAddress, "2020", "2020")
+      return err
+    },
+  }, {
+    name: "GeographiesFromCoordinatesContext",
+    fn: func(ctx context.Context, c Client) error {
+      _, err := c.GeographiesFromCoordinatesContext(ctx, testCoordinates, "2020", "2020")
+      return err
+    },
+  }, {
+    name: "BatchLocationsContext",
+    fn: func(ctx context.Context, c Client) error {
+      _, err := c.BatchLocationsContext(ctx, rows)
+      return err
+    },
+  }, {
+    name: "BatchGeographiesContext",
+    fn: func(ctx context.Context, c Client) error {
+      _, err := c.BatchGeographiesContext(ctx, rows, "2020", "2020")
+      return err
+    },
+  }}
+
+  // create slow mock server
+  ms, url, err := newSlowMockServer(5 * time.Second)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ms.Close()
+
+  // create client
+  c := NewClient(WithBaseURL(url))
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // create context with short deadline
+      ctx, cancel := context.WithTimeout(context.Background(), 50 * time.Millisecond)
+      defer cancel()
+
+      // send request, check for deadline error
+      start := time.Now()
+      err := test.fn(ctx, c)
+      if !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("got %v, exp %v", err, context.DeadlineExceeded)
+      }
+
+      // check elapsed time
+      if elapsed := time.Since(start); elapsed > 2 * time.Second {
+        t.Fatalf("request not cancelled: %v", elapsed)
+      }
+    })
+  }
+}
+
+func TestClientContextCancel(t *testing.T) {
+  // create slow mock server
+  ms, url, err := newSlowMockServer(5 * time.Second)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ms.Close()
+
+  // create client
+  c := NewClient(WithBaseURL(url))
+
+  // create context, cancel it shortly after request is sent
+  ctx, cancel := context.WithCancel(context.Background())
+  time.AfterFunc(50 * time.Millisecond, cancel)
+
+  // send batch request, check for cancel error
+  _, err = c.BatchLocationsContext(ctx, getBatchInputRows(t))
+  if !errors.Is(err, context.Canceled) {
+    t.Fatalf("got %v, exp %v", err, context.Canceled)
+  }
+}
+
+func TestClientBatchReportError(t *testing.T) {
+  // get input rows without header row
+  rows := getBatchInputRows(t)[1:]
+
+  // create mock server
+  ms, url, err := newMockServer()
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ms.Close()
+
+  // create client
+  c := NewClient(WithBaseURL(url))
+
+  // send rows, check for report error
+  got, err := c.BatchGeographies(rows, "2020", "2020")
+  var reportErr *BatchReportError
+  if !errors.As(err, &reportErr) {
+    t.Fatalf("got %v, exp *BatchReportError", err)
+  }
+
+  // check report
+  exp := BatchReport { Unexpected: []string { "id" } }
+  if !reflect.DeepEqual(reportErr.Report, exp) {
+    t.Fatalf("got %#v, exp %#v", reportErr.Report, exp)
+  }
+
+  // check returned rows
+  if len(got) != len(rows) {
+    t.Fatalf("got %d rows, exp %d", len(got), len(rows))
+  }
+  for i, row := range(got) {
+    if row.Id != rows[i].Id {
+      t.Fatalf("%d: got %s, exp %s", i, row.Id, rows[i].Id)
+    }
+  }
+}
@@ -0,0 +1,132 @@
+package geocoder
+
+import (
+  "errors"
+  "fmt"
+  "net/http"
+  "strings"
+)
+
+var (
+  // Invalid or missing benchmark.
+  ErrInvalidBenchmark = errors.New("invalid benchmark")
+
+  // Invalid or missing vintage.
+  ErrInvalidVintage = errors.New("invalid vintage")
+
+  // Missing or empty address.
+  ErrAddressRequired = errors.New("address required")
+
+  // Too many requests sent to the API (HTTP 429).
+  ErrRateLimited = errors.New("rate limited")
+
+  // Request is not supported by the geocoder (e.g. address lookups
+  // with a [BoundaryGeocoder]).
+  ErrNotSupported = errors.New("not supported")
+)
+
+// Maximum number of bytes of the response body to include in an
+// [APIError].
+const apiErrorBodySize = 512
+
+// Error returned by the Census geocoder API.
+//
+// Returned when the API responds with a non-success HTTP status, a
+// non-empty `errors` array, or a body which can not be decoded (e.g.
+// an HTML error page).
+//
+// Use [errors.Is] with the sentinel errors in this package (e.g.
+// [ErrInvalidBenchmark], [ErrRateLimited]) to check for specific
+// failures.
+type APIError struct {
+  // HTTP status code
+  StatusCode int
+
+  // API endpoint (e.g. "locations/onelineaddress")
+  Endpoint string
+
+  // request parameters
+  Params map[string]string
+
+  // all entries from the `errors` array of the response
+  Errors []string
+
+  // first bytes of the response body
+  Body string
+
+  // response headers (e.g. `Retry-After`)
+  Header http.Header
+
+  // underlying decoding error, if any
+  Err error
+}
+
+// Get error message.
+func (e *APIError) Error() string {
+  var msg string
+  switch {
+  case len(e.Errors) > 0:
+    msg = strings.Join(e.Errors, "; ")
+  case e.Err != nil:
+    msg = e.Err.Error()
+  default:
+    msg = strings.TrimSpace(e.Body)
+  }
+
+  return fmt.Sprintf("%s: %d %s: %s", e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode), msg)
+}
+
+// Get underlying decoding error.
+func (e *APIError) Unwrap() error {
+  return e.Err
+}
+
+// Returns true if the target is a sentinel error which matches this
+// error.
+func (e *APIError) Is(target error) bool {
+  switch target {
+  case ErrRateLimited:
+    return e.StatusCode == http.StatusTooManyRequests
+  case ErrInvalidBenchmark:
+    // vintage errors often mention the benchmark as well (e.g.
+    // "vintage is not valid for benchmark"), so exclude them
+    return e.hasErrorFunc(func(msg string) bool {
+      return strings.Contains(msg, "benchmark") && !strings.Contains(msg, "vintage")
+    })
+  case ErrInvalidVintage:
+    return e.hasError("vintage")
+  case ErrAddressRequired:
+    return e.hasError("address cannot be empty") ||
+           e.hasError("street cannot be empty") ||
+           e.hasError("address is required") ||
+           e.hasError("street is required")
+  default:
+    return false
+  }
+}
+
+// Returns true if any of the API error messages contain the given
+// string, ignoring case.
+func (e *APIError) hasError(s string) bool {
+  return e.hasErrorFunc(func(msg string) bool {
+    return strings.Contains(msg, s)
+  })
+}
+
+// Returns true if the given function returns true for any of the
+// lowercased API error messages.
+func (e *APIError) hasErrorFunc(fn func(string) bool) bool {
+  for _, msg := range(e.Errors) {
+    if fn(strings.ToLower(msg)) {
+      return true
+    }
+  }
+
+  return false
+}
+
+// Truncate response body to the maximum API error body size without
+// splitting a UTF-8 character.
+func apiErrorBody(body []byte) string {
+  return truncateString(string(body), apiErrorBodySize)
+}
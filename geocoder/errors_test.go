@@ -0,0 +1,207 @@
+package geocoder
+
+import (
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  net_url "net/url"
+  "reflect"
+  "strings"
+  "testing"
+  "unicode/utf8"
+)
+
+// Start server which responds to every request with the given status,
+// content type, and body, then return server and client.
+func newErrorServer(t *testing.T, status int, contentType, body string) (*httptest.Server, Client) {
+  ms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", contentType)
+    w.WriteHeader(status)
+    w.Write([]byte(body))
+  }))
+
+  // parse server URL
+  url, err := net_url.Parse(ms.URL)
+  if err != nil {
+    ms.Close()
+    t.Fatal(err)
+  }
+
+  // return server and client
+  return ms, NewClient(WithBaseURL(url))
+}
+
+func TestAPIError(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    status int // response status
+    contentType string // response content type
+    body string // response body
+    exp APIError // expected error fields
+    is []error // expected sentinel errors
+    isNot []error // unexpected sentinel errors
+  } {{
+    name: "errors array",
+    status: 400,
+    contentType: "application/json",
+    body: `{"errors":["Address cannot be empty and cannot exceed 100 characters","Benchmark must be provided"],"status":"400"}`,
+    exp: APIError {
+      StatusCode: 400,
+      Endpoint: "locations/onelineaddress",
+      Errors: []string {
+        "Address cannot be empty and cannot exceed 100 characters",
+        "Benchmark must be provided",
+      },
+    },
+    is: []error { ErrAddressRequired, ErrInvalidBenchmark },
+    isNot: []error { ErrInvalidVintage, ErrRateLimited },
+  }, {
+    name: "errors array with success status",
+    status: 200,
+    contentType: "application/json",
+    body: `{"errors":["Invalid vintage for benchmark"]}`,
+    exp: APIError {
+      StatusCode: 200,
+      Endpoint: "locations/onelineaddress",
+      Errors: []string { "Invalid vintage for benchmark" },
+    },
+    is: []error { ErrInvalidVintage },
+    isNot: []error { ErrAddressRequired, ErrInvalidBenchmark, ErrRateLimited },
+  }, {
+    name: "rate limited",
+    status: 429,
+    contentType: "text/plain",
+    body: "slow down",
+    exp: APIError {
+      StatusCode: 429,
+      Endpoint: "locations/onelineaddress",
+    },
+    is: []error { ErrRateLimited },
+    isNot: []error { ErrAddressRequired, ErrInvalidBenchmark, ErrInvalidVintage },
+  }, {
+    name: "html error page",
+    status: 502,
+    contentType: "text/html",
+    body: "<html><body>Bad Gateway</body></html>",
+    exp: APIError {
+      StatusCode: 502,
+      Endpoint: "locations/onelineaddress",
+    },
+    isNot: []error { ErrRateLimited, ErrAddressRequired },
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      ms, c := newErrorServer(t, test.status, test.contentType, test.body)
+      defer ms.Close()
+
+      // send request, check for error
+      _, err := c.LocationsFromBenchmark(testAddress, testBenchmarkId)
+      var got *APIError
+      if !errors.As(err, &got) {
+        t.Fatalf("got %v, exp *APIError", err)
+      }
+
+      // check fields
+      if got.StatusCode != test.exp.StatusCode ||
+         got.Endpoint != test.exp.Endpoint ||
+         !reflect.DeepEqual(got.Errors, test.exp.Errors) ||
+         got.Body != test.body ||
+         got.Params["address"] != testAddress {
+        t.Fatalf("got %#v, exp %#v", got, test.exp)
+      }
+
+      // check sentinel errors
+      for _, target := range(test.is) {
+        if !errors.Is(err, target) {
+          t.Errorf("errors.Is(%v, %v): got false, exp true", err, target)
+        }
+      }
+
+      for _, target := range(test.isNot) {
+        if errors.Is(err, target) {
+          t.Errorf("errors.Is(%v, %v): got true, exp false", err, target)
+        }
+      }
+    })
+  }
+}
+
+func TestAPIErrorBody(t *testing.T) {
+  // create long non-JSON body
+  body := strings.Repeat("x", 2 * apiErrorBodySize)
+  ms, c := newErrorServer(t, 200, "text/plain", body)
+  defer ms.Close()
+
+  // send request, check for error
+  _, err := c.Benchmarks()
+  var got *APIError
+  if !errors.As(err, &got) {
+    t.Fatalf("got %v, exp *APIError", err)
+  }
+
+  // check truncated body and decoding error
+  if len(got.Body) != apiErrorBodySize || got.Err == nil {
+    t.Fatalf("got %#v", got)
+  }
+}
+
+func TestAPIErrorBodyUTF8(t *testing.T) {
+  // create long body of 2-byte characters, offset by one byte so the
+  // size limit falls in the middle of a character
+  body := "x" + strings.Repeat("é", apiErrorBodySize)
+  ms, c := newErrorServer(t, 500, "text/plain", body)
+  defer ms.Close()
+
+  // send request, check for error
+  _, err := c.Benchmarks()
+  var got *APIError
+  if !errors.As(err, &got) {
+    t.Fatalf("got %v, exp *APIError", err)
+  }
+
+  // check that body is truncated on a character boundary
+  if exp := body[:apiErrorBodySize - 1]; got.Body != exp {
+    t.Fatalf("got %q, exp %q", got.Body, exp)
+  }
+  if !utf8.ValidString(got.Body) {
+    t.Fatalf("got invalid UTF-8: %q", got.Body)
+  }
+}
+
+func TestAPIErrorBatch(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    status int // response status
+    contentType string // response content type
+    body string // response body
+  } {
+    { "server error", 500, "text/plain", "internal error" },
+    { "html error page", 200, "text/html; charset=utf-8", "<html><body>Error</body></html>" },
+    { "rate limited", 429, "text/plain", "slow down" },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      ms, c := newErrorServer(t, test.status, test.contentType, test.body)
+      defer ms.Close()
+
+      // send request, check for error
+      _, err := c.BatchGeographies(getBatchInputRows(t), "2020", "2020")
+      var got *APIError
+      if !errors.As(err, &got) {
+        t.Fatalf("got %v, exp *APIError", err)
+      }
+
+      // check fields
+      if got.StatusCode != test.status || got.Endpoint != "geographies/addressbatch" || got.Body != test.body || got.Params["vintage"] != "2020" {
+        t.Fatalf("got %#v", got)
+      }
+
+      // check rate limit sentinel
+      if errors.Is(err, ErrRateLimited) != (test.status == 429) {
+        t.Fatalf("errors.Is(%v, ErrRateLimited): got %v", err, !(test.status == 429))
+      }
+    })
+  }
+}
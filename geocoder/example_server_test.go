@@ -0,0 +1,72 @@
+package geocoder_test
+
+import (
+  "fmt"
+  "log"
+
+  "pablotron.org/census-geocoder/geocoder"
+  "pablotron.org/census-geocoder/geocoder/geocodertest"
+)
+
+func ExampleClient_GeographiesFromCoordinates() {
+  // start fake API server with county for coordinates
+  s := geocodertest.NewServer()
+  defer s.Close()
+
+  coords := geocoder.Coordinates { -77.19902696904677, 38.88701576684785 }
+  s.SetGeographies(coords, geocoder.GeographyLayers {
+    "Counties": { { "NAME": "Fairfax County", "GEOID": "51059" } },
+  })
+
+  // create client which sends requests to fake server
+  c := s.Client()
+
+  // get geography layers for coordinates
+  layers, err := c.GeographiesFromCoordinates(coords, "2020", "2020")
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  // print county name to standard output
+  fmt.Println(layers["Counties"][0]["NAME"])
+
+  // Output:
+  // Fairfax County
+}
+
+func ExampleMatch_Tracts() {
+  // start fake API server with census tract for address
+  s := geocodertest.NewServer()
+  defer s.Close()
+
+  s.SetMatches("3444 gallows rd annandale va 22003", geocoder.Match {
+    MatchedAddress: "3444 GALLOWS RD, ANNANDALE, VA, 22003",
+    Geographies: geocoder.GeographyLayers {
+      "Census Tracts": { { "NAME": "Census Tract 4507.01", "GEOID": "51059450701" } },
+    },
+  })
+
+  // create client which sends requests to fake server
+  c := s.Client()
+
+  // get address matches with additional geographical information
+  locs, err := c.Geographies("3444 gallows rd annandale va 22003", "2020", "2020")
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  // print matched addresses and census tracts to standard output
+  for _, v := range(locs) {
+    tracts, err := v.Tracts()
+    if err != nil {
+      log.Fatal(err)
+    }
+
+    for _, tract := range(tracts) {
+      fmt.Printf("%s - %s\n", v.MatchedAddress, tract.GeoId)
+    }
+  }
+
+  // Output:
+  // 3444 GALLOWS RD, ANNANDALE, VA, 22003 - 51059450701
+}
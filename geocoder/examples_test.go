@@ -3,11 +3,40 @@ package geocoder
 import (
   "fmt"
   "log"
+  "time"
 )
 
+func ExampleNewClient() {
+  // create client with user agent, timeout, retries, and layers
+  c := NewClient(
+    WithUserAgent("example-app/1.0"),
+    WithTimeout(30 * time.Second),
+    WithRetryPolicy(DefaultRetryPolicy),
+    WithLayers("Counties", "Census Tracts"),
+  )
+
+  // geocode address with default benchmark and vintage
+  matches, err := c.Geographies("4600 silver hill rd, washington, dc 20233", "", "")
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  // print county names to standard output
+  for _, m := range(matches) {
+    counties, err := m.Counties()
+    if err != nil {
+      log.Fatal(err)
+    }
+
+    for _, county := range(counties) {
+      fmt.Println(county.Name)
+    }
+  }
+}
+
 func ExampleClient_Benchmarks() {
   // create client using default URL
-  c := NewClient(DefaultUrl)
+  c := NewClient()
 
   // get benchmarks
   benchmarks, err := c.Benchmarks()
@@ -28,7 +57,7 @@ func ExampleClient_Benchmarks() {
 
 func ExampleClient_Vintages() {
   // create client using default URL
-  c := NewClient(DefaultUrl)
+  c := NewClient()
 
   // get vintages
   vintages, err := c.Vintages("2020")
@@ -48,7 +77,7 @@ func ExampleClient_Vintages() {
 
 func ExampleClient_Locations() {
   // create client using default URL
-  c := NewClient(DefaultUrl)
+  c := NewClient()
 
   // get address matches
   locs, err := c.Locations("3444 gallows rd annandale va 22003")
@@ -67,7 +96,7 @@ func ExampleClient_Locations() {
 
 func ExampleClient_Geographies() {
   // create client using default URL
-  c := NewClient(DefaultUrl)
+  c := NewClient()
 
   // get address matches with additional geographical information
   locs, err := c.Geographies("3444 gallows rd annandale va 22003", "2020", "2020")
@@ -0,0 +1,382 @@
+package geocoder
+
+import (
+  "bufio"
+  "encoding/binary"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "math"
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+// Unsupported feature file format or geometry.
+var ErrUnsupportedFeature = errors.New("unsupported feature")
+
+// Feature read from a shapefile or GeoJSON file.
+//
+// Lines and polygons are both stored as a list of parts.  Polygon
+// parts are rings, which are tested with the even-odd rule, so holes
+// do not need to be distinguished from outer rings.
+type feature struct {
+  parts [][]Coordinates // line parts or polygon rings
+  props map[string]string // attributes
+}
+
+// Read shapefile main file shapes.
+//
+// Null shapes are returned with no parts.  Point, polyline, and polygon
+// shapes (including the Z and M variants) are supported; Z and M
+// values are ignored.
+func readShapes(r io.Reader) ([][][]Coordinates, error) {
+  br := bufio.NewReader(r)
+
+  // read header, check file code
+  var hdr [shpHeaderLen]byte
+  if _, err := io.ReadFull(br, hdr[:]); err != nil {
+    return nil, fmt.Errorf("shp header: %w", err)
+  }
+  if code := binary.BigEndian.Uint32(hdr[0:4]); code != 9994 {
+    return nil, fmt.Errorf("shp header: invalid file code %d", code)
+  }
+
+  // get file length (in 16-bit words), check for invalid length
+  fileLen := 2 * int64(int32(binary.BigEndian.Uint32(hdr[24:28])))
+  if fileLen < shpHeaderLen {
+    return nil, fmt.Errorf("shp header: invalid file length %d", fileLen)
+  }
+
+  var shapes [][][]Coordinates
+  for pos := int64(shpHeaderLen); ; {
+    // read record header (big-endian record number and content length
+    // in 16-bit words)
+    var rh [8]byte
+    if _, err := io.ReadFull(br, rh[:]); err == io.EOF {
+      return shapes, nil
+    } else if err != nil {
+      return nil, fmt.Errorf("shp record %d: %w", len(shapes) + 1, err)
+    }
+
+    // check content length against the rest of the file before
+    // allocating the content buffer
+    pos += 8
+    contentLen := 2 * int64(int32(binary.BigEndian.Uint32(rh[4:8])))
+    if contentLen < 0 || contentLen > fileLen - pos {
+      return nil, fmt.Errorf("shp record %d: invalid content length %d", len(shapes) + 1, contentLen)
+    }
+    pos += contentLen
+
+    // read record content
+    content := make([]byte, contentLen)
+    if _, err := io.ReadFull(br, content); err != nil {
+      return nil, fmt.Errorf("shp record %d: %w", len(shapes) + 1, err)
+    }
+
+    parts, err := decodeShape(content)
+    if err != nil {
+      return nil, fmt.Errorf("shp record %d: %w", len(shapes) + 1, err)
+    }
+    shapes = append(shapes, parts)
+  }
+}
+
+// Decode shapefile record content as list of parts.
+func decodeShape(b []byte) ([][]Coordinates, error) {
+  if len(b) < 4 {
+    return nil, io.ErrUnexpectedEOF
+  }
+
+  // read little-endian float64 at offset
+  f64 := func(ofs int) float64 {
+    return math.Float64frombits(binary.LittleEndian.Uint64(b[ofs:]))
+  }
+
+  switch t := binary.LittleEndian.Uint32(b); t {
+  case shapeNull:
+    return nil, nil
+  case shapePoint, shapePointZ, shapePointM:
+    if len(b) < 20 {
+      return nil, io.ErrUnexpectedEOF
+    }
+    return [][]Coordinates {{{ X: f64(4), Y: f64(12) }}}, nil
+  case shapePolyline, shapePolygon, shapePolylineZ, shapePolygonZ, shapePolylineM, shapePolygonM:
+    // skip bounding box, read part and point counts
+    if len(b) < 44 {
+      return nil, io.ErrUnexpectedEOF
+    }
+    numParts := int(binary.LittleEndian.Uint32(b[36:]))
+    numPoints := int(binary.LittleEndian.Uint32(b[40:]))
+    pointsOfs := 44 + 4 * numParts
+    if numParts < 0 || numPoints < 0 || len(b) < pointsOfs + 16 * numPoints {
+      return nil, io.ErrUnexpectedEOF
+    }
+
+    // read parts
+    parts := make([][]Coordinates, numParts)
+    for i := range(parts) {
+      start := int(binary.LittleEndian.Uint32(b[44 + 4 * i:]))
+      end := numPoints
+      if i + 1 < numParts {
+        end = int(binary.LittleEndian.Uint32(b[44 + 4 * (i + 1):]))
+      }
+      if start < 0 || start > end || end > numPoints {
+        return nil, fmt.Errorf("invalid part %d", i)
+      }
+
+      part := make([]Coordinates, end - start)
+      for j := range(part) {
+        ofs := pointsOfs + 16 * (start + j)
+        part[j] = Coordinates { X: f64(ofs), Y: f64(ofs + 8) }
+      }
+      parts[i] = part
+    }
+
+    return parts, nil
+  default:
+    return nil, fmt.Errorf("%w: shape type %d", ErrUnsupportedFeature, t)
+  }
+}
+
+// Read DBF records as maps of field name to trimmed field value.
+//
+// Deleted records are returned as nil so records stay aligned with
+// shapes.
+func readDbfRecords(r io.Reader) ([]map[string]string, error) {
+  br := bufio.NewReader(r)
+
+  // read header
+  var hdr [32]byte
+  if _, err := io.ReadFull(br, hdr[:]); err != nil {
+    return nil, fmt.Errorf("dbf header: %w", err)
+  }
+  numRecords := int(binary.LittleEndian.Uint32(hdr[4:8]))
+  headerLen := int(binary.LittleEndian.Uint16(hdr[8:10]))
+  recordLen := int(binary.LittleEndian.Uint16(hdr[10:12]))
+  if headerLen < 33 || recordLen < 1 {
+    return nil, fmt.Errorf("dbf header: invalid lengths")
+  }
+
+  // read field descriptors
+  desc := make([]byte, headerLen - 32)
+  if _, err := io.ReadFull(br, desc); err != nil {
+    return nil, fmt.Errorf("dbf header: %w", err)
+  }
+
+  type field struct {
+    name string // field name
+    ofs, size int // offset and size in record
+  }
+
+  var fields []field
+  ofs := 1 // skip deletion flag
+  for i := 0; i + 32 <= len(desc) && desc[i] != 0x0d; i += 32 {
+    name := strings.TrimRight(string(desc[i:i + 11]), "\x00 ")
+    size := int(desc[i + 16])
+    fields = append(fields, field { name, ofs, size })
+    ofs += size
+  }
+  if ofs > recordLen {
+    return nil, fmt.Errorf("dbf header: fields exceed record length")
+  }
+
+  // read records
+  //
+  // note: records are appended as they are read rather than allocated
+  // up front, because the record count in the header is not trusted
+  var records []map[string]string
+  buf := make([]byte, recordLen)
+  for i := 0; i < numRecords; i++ {
+    if _, err := io.ReadFull(br, buf); err != nil {
+      return nil, fmt.Errorf("dbf record %d: %w", i + 1, err)
+    }
+
+    // skip deleted records
+    if buf[0] == '*' {
+      records = append(records, nil)
+      continue
+    }
+
+    rec := make(map[string]string, len(fields))
+    for _, f := range(fields) {
+      rec[f.name] = strings.TrimSpace(string(buf[f.ofs:f.ofs + f.size]))
+    }
+    records = append(records, rec)
+  }
+
+  return records, nil
+}
+
+// Read features from shapefile main file and DBF file.
+//
+// Null shapes and deleted records are skipped.
+func readShapefileFeatures(shp, dbf io.Reader) ([]feature, error) {
+  shapes, err := readShapes(shp)
+  if err != nil {
+    return nil, err
+  }
+
+  records, err := readDbfRecords(dbf)
+  if err != nil {
+    return nil, err
+  }
+
+  if len(shapes) != len(records) {
+    return nil, fmt.Errorf("shapefile has %d shapes and %d records", len(shapes), len(records))
+  }
+
+  var r []feature
+  for i, parts := range(shapes) {
+    if len(parts) > 0 && records[i] != nil {
+      r = append(r, feature { parts, records[i] })
+    }
+  }
+
+  return r, nil
+}
+
+// GeoJSON geometry.
+type geoJSONGeometry struct {
+  Type string `json:"type"`
+  Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Decode GeoJSON position list.
+func decodePositions(ps [][]float64) ([]Coordinates, error) {
+  r := make([]Coordinates, len(ps))
+  for i, p := range(ps) {
+    if len(p) < 2 {
+      return nil, fmt.Errorf("invalid position")
+    }
+    r[i] = Coordinates { X: p[0], Y: p[1] }
+  }
+
+  return r, nil
+}
+
+// Decode GeoJSON geometry as list of parts.
+func (g geoJSONGeometry) parts() ([][]Coordinates, error) {
+  var r [][]Coordinates
+  switch g.Type {
+  case "Point":
+    var p []float64
+    if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+      return nil, err
+    }
+    ps, err := decodePositions([][]float64 { p })
+    return [][]Coordinates { ps }, err
+  case "LineString":
+    var line [][]float64
+    if err := json.Unmarshal(g.Coordinates, &line); err != nil {
+      return nil, err
+    }
+    ps, err := decodePositions(line)
+    return [][]Coordinates { ps }, err
+  case "MultiLineString", "Polygon":
+    var lines [][][]float64
+    if err := json.Unmarshal(g.Coordinates, &lines); err != nil {
+      return nil, err
+    }
+    for _, line := range(lines) {
+      ps, err := decodePositions(line)
+      if err != nil {
+        return nil, err
+      }
+      r = append(r, ps)
+    }
+  case "MultiPolygon":
+    var polys [][][][]float64
+    if err := json.Unmarshal(g.Coordinates, &polys); err != nil {
+      return nil, err
+    }
+    for _, poly := range(polys) {
+      for _, ring := range(poly) {
+        ps, err := decodePositions(ring)
+        if err != nil {
+          return nil, err
+        }
+        r = append(r, ps)
+      }
+    }
+  default:
+    return nil, fmt.Errorf("%w: geometry type %q", ErrUnsupportedFeature, g.Type)
+  }
+
+  return r, nil
+}
+
+// Read features from GeoJSON feature collection.
+//
+// Property values are converted to strings; numbers keep their JSON
+// formatting.  Features with null geometry are skipped.
+func readGeoJSONFeatures(r io.Reader) ([]feature, error) {
+  var fc struct {
+    Features []struct {
+      Geometry *geoJSONGeometry `json:"geometry"`
+      Properties map[string]any `json:"properties"`
+    } `json:"features"`
+  }
+
+  d := json.NewDecoder(r)
+  d.UseNumber()
+  if err := d.Decode(&fc); err != nil {
+    return nil, err
+  }
+
+  var fs []feature
+  for i, f := range(fc.Features) {
+    if f.Geometry == nil {
+      continue
+    }
+
+    parts, err := f.Geometry.parts()
+    if err != nil {
+      return nil, fmt.Errorf("feature %d: %w", i, err)
+    }
+
+    props := make(map[string]string, len(f.Properties))
+    for k, v := range(f.Properties) {
+      if v != nil {
+        props[k] = fmt.Sprint(v)
+      }
+    }
+
+    fs = append(fs, feature { parts, props })
+  }
+
+  return fs, nil
+}
+
+// Load features from a shapefile (".shp", with a ".dbf" file next to
+// it) or a GeoJSON file (".geojson" or ".json").
+func loadFeatures(path string) ([]feature, error) {
+  ext := strings.ToLower(filepath.Ext(path))
+  switch ext {
+  case ".shp":
+    shp, err := os.Open(path)
+    if err != nil {
+      return nil, err
+    }
+    defer shp.Close()
+
+    dbf, err := os.Open(strings.TrimSuffix(path, filepath.Ext(path)) + ".dbf")
+    if err != nil {
+      return nil, err
+    }
+    defer dbf.Close()
+
+    return readShapefileFeatures(shp, dbf)
+  case ".geojson", ".json":
+    f, err := os.Open(path)
+    if err != nil {
+      return nil, err
+    }
+    defer f.Close()
+
+    return readGeoJSONFeatures(f)
+  default:
+    return nil, fmt.Errorf("%w: file extension %q", ErrUnsupportedFeature, ext)
+  }
+}
@@ -0,0 +1,307 @@
+package geocoder
+
+import (
+  "bytes"
+  "encoding/binary"
+  "errors"
+  "os"
+  "path/filepath"
+  "reflect"
+  "sort"
+  "strings"
+  "testing"
+)
+
+// Feature written by test shapefile writer.
+type testFeature struct {
+  parts [][]Coordinates // line parts or polygon rings; nil for null shape
+  props map[string]string // attributes
+}
+
+// Write shapefile main file and DBF file with given shape type and
+// features.  The index file is not written.
+func writeTestShapefile(kind uint32, fs []testFeature) ([]byte, []byte) {
+  // write records
+  var recs bytes.Buffer
+  for i, f := range(fs) {
+    var content bytes.Buffer
+    if f.parts == nil {
+      binary.Write(&content, binary.LittleEndian, uint32(shapeNull))
+    } else {
+      // count points
+      numPoints := 0
+      for _, part := range(f.parts) {
+        numPoints += len(part)
+      }
+
+      // note: bounding box is not read, so it is left empty
+      binary.Write(&content, binary.LittleEndian, kind)
+      binary.Write(&content, binary.LittleEndian, [4]float64{})
+      binary.Write(&content, binary.LittleEndian, uint32(len(f.parts)))
+      binary.Write(&content, binary.LittleEndian, uint32(numPoints))
+      start := 0
+      for _, part := range(f.parts) {
+        binary.Write(&content, binary.LittleEndian, uint32(start))
+        start += len(part)
+      }
+      for _, part := range(f.parts) {
+        for _, p := range(part) {
+          binary.Write(&content, binary.LittleEndian, [2]float64 { p.X, p.Y })
+        }
+      }
+    }
+
+    binary.Write(&recs, binary.BigEndian, [2]uint32 { uint32(i + 1), uint32(content.Len() / 2) })
+    recs.Write(content.Bytes())
+  }
+
+  // write main file
+  var shp bytes.Buffer
+  writeShpHeader(&shp, (shpHeaderLen + recs.Len()) / 2, [4]float64{})
+  binary.LittleEndian.PutUint32(shp.Bytes()[32:], kind)
+  shp.Write(recs.Bytes())
+
+  // get sorted field names
+  var names []string
+  if len(fs) > 0 {
+    for k := range(fs[0].props) {
+      names = append(names, k)
+    }
+  }
+  sort.Strings(names)
+
+  // write DBF header and field descriptors (20-byte character fields)
+  const size = 20
+  var dbf bytes.Buffer
+  hdr := make([]byte, 32)
+  hdr[0] = 0x03
+  binary.LittleEndian.PutUint32(hdr[4:], uint32(len(fs)))
+  binary.LittleEndian.PutUint16(hdr[8:], uint16(32 + 32 * len(names) + 1))
+  binary.LittleEndian.PutUint16(hdr[10:], uint16(1 + size * len(names)))
+  dbf.Write(hdr)
+  for _, name := range(names) {
+    desc := make([]byte, 32)
+    copy(desc, name)
+    desc[11] = 'C'
+    desc[16] = size
+    dbf.Write(desc)
+  }
+  dbf.WriteByte(0x0d)
+
+  // write DBF records
+  for _, f := range(fs) {
+    dbf.WriteByte(' ')
+    for _, name := range(names) {
+      dbf.WriteString(f.props[name] + strings.Repeat(" ", size - len(f.props[name])))
+    }
+  }
+  dbf.WriteByte(0x1a)
+
+  return shp.Bytes(), dbf.Bytes()
+}
+
+// test polyline features
+var testLineFeatures = []testFeature {{
+  parts: [][]Coordinates {{ { X: -77, Y: 38 }, { X: -76.9, Y: 38.1 } }},
+  props: map[string]string { "NAME": "A", "TLID": "1" },
+}, {
+  props: map[string]string { "NAME": "null", "TLID": "2" },
+}, {
+  parts: [][]Coordinates {
+    { { X: 1, Y: 2 }, { X: 3, Y: 4 } },
+    { { X: 5, Y: 6 }, { X: 7, Y: 8 }, { X: 9, Y: 10 } },
+  },
+  props: map[string]string { "NAME": "B", "TLID": "3" },
+}}
+
+func TestReadShapefileFeatures(t *testing.T) {
+  shp, dbf := writeTestShapefile(shapePolyline, testLineFeatures)
+  got, err := readShapefileFeatures(bytes.NewReader(shp), bytes.NewReader(dbf))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // null shapes are skipped
+  exp := []feature {
+    { testLineFeatures[0].parts, testLineFeatures[0].props },
+    { testLineFeatures[2].parts, testLineFeatures[2].props },
+  }
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestReadShapefileFeaturesWritten(t *testing.T) {
+  // read point shapefile written by WriteShapefile
+  var shp, shx, dbf, prj bytes.Buffer
+  if err := WriteShapefile(&shp, &shx, &dbf, &prj, testOutputRows); err != nil {
+    t.Fatal(err)
+  }
+  got, err := readShapefileFeatures(&shp, &dbf)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // unmatched rows are null shapes, so they are skipped
+  var exp []BatchOutputRow
+  for _, row := range(testOutputRows) {
+    if row.Match {
+      exp = append(exp, row)
+    }
+  }
+  if len(got) != len(exp) {
+    t.Fatalf("got %d features, exp %d", len(got), len(exp))
+  }
+  for i, row := range(exp) {
+    expParts := [][]Coordinates {{ row.Coordinates }}
+    if !reflect.DeepEqual(got[i].parts, expParts) {
+      t.Fatalf("%d: got %v, exp %v", i, got[i].parts, expParts)
+    }
+    if got[i].props["ID"] != row.Id {
+      t.Fatalf("%d: got %q, exp %q", i, got[i].props["ID"], row.Id)
+    }
+  }
+}
+
+func TestReadShapefileFeaturesFail(t *testing.T) {
+  shp, dbf := writeTestShapefile(shapePolyline, testLineFeatures)
+  _, fewerDbf := writeTestShapefile(shapePolyline, testLineFeatures[:1])
+
+  // unsupported shape type
+  multipoint := append([]byte{}, shp...)
+  binary.LittleEndian.PutUint32(multipoint[shpHeaderLen + 8:], 8)
+
+  // DBF with hostile record count
+  hugeDbf := append([]byte{}, dbf...)
+  binary.LittleEndian.PutUint32(hugeDbf[4:], 0xffffffff)
+
+  // copy main file with given big-endian value at offset
+  withValue := func(ofs int, val uint32) []byte {
+    r := append([]byte{}, shp...)
+    binary.BigEndian.PutUint32(r[ofs:], val)
+    return r
+  }
+
+  tests := []struct {
+    name string // test name
+    shp, dbf []byte // file contents
+  } {
+    { "empty", nil, dbf },
+    { "bad code", append([]byte { 0, 0, 0, 0 }, shp[4:]...), dbf },
+    { "truncated", shp[:len(shp) - 4], dbf },
+    { "file length", withValue(24, 0), dbf },
+    { "huge content length", withValue(shpHeaderLen + 4, 0x7fffffff), dbf },
+    { "negative content length", withValue(shpHeaderLen + 4, 0xffffffff), dbf },
+    { "content past file length", withValue(24, uint32(shpHeaderLen / 2 + 4)), dbf },
+    { "multipoint", multipoint, dbf },
+    { "record count", shp, fewerDbf },
+    { "empty dbf", shp, nil },
+    { "huge dbf record count", shp, hugeDbf },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      if _, err := readShapefileFeatures(bytes.NewReader(test.shp), bytes.NewReader(test.dbf)); err == nil {
+        t.Fatal("got success, exp error")
+      }
+    })
+  }
+}
+
+func TestReadGeoJSONFeatures(t *testing.T) {
+  src := `{"type": "FeatureCollection", "features": [
+    {"type": "Feature", "geometry": {"type": "Point", "coordinates": [1, 2]}, "properties": {"a": "x", "b": 12345678901, "c": null}},
+    {"type": "Feature", "geometry": null, "properties": {}},
+    {"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[1, 2], [3, 4]]}, "properties": {}},
+    {"type": "Feature", "geometry": {"type": "MultiLineString", "coordinates": [[[1, 2], [3, 4]], [[5, 6], [7, 8]]]}, "properties": {}},
+    {"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [0, 1], [0, 0]]]}, "properties": {}},
+    {"type": "Feature", "geometry": {"type": "MultiPolygon", "coordinates": [[[[0, 0], [1, 0], [0, 1], [0, 0]]], [[[2, 2], [3, 2], [2, 3], [2, 2]]]]}, "properties": {}}
+  ]}`
+
+  got, err := readGeoJSONFeatures(strings.NewReader(src))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  tri := func(o float64) []Coordinates {
+    return []Coordinates { { X: o, Y: o }, { X: o + 1, Y: o }, { X: o, Y: o + 1 }, { X: o, Y: o } }
+  }
+  exp := []feature {
+    { [][]Coordinates {{ { X: 1, Y: 2 } }}, map[string]string { "a": "x", "b": "12345678901" } },
+    { [][]Coordinates {{ { X: 1, Y: 2 }, { X: 3, Y: 4 } }}, map[string]string{} },
+    { [][]Coordinates { { { X: 1, Y: 2 }, { X: 3, Y: 4 } }, { { X: 5, Y: 6 }, { X: 7, Y: 8 } } }, map[string]string{} },
+    { [][]Coordinates { tri(0) }, map[string]string{} },
+    { [][]Coordinates { tri(0), tri(2) }, map[string]string{} },
+  }
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestReadGeoJSONFeaturesFail(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    src string // GeoJSON
+    err error // expected error, or nil for any error
+  } {
+    { "invalid json", `{`, nil },
+    { "unsupported", `{"features": [{"geometry": {"type": "MultiPoint", "coordinates": [[1, 2]]}}]}`, ErrUnsupportedFeature },
+    { "bad position", `{"features": [{"geometry": {"type": "LineString", "coordinates": [[1]]}}]}`, nil },
+    { "bad coordinates", `{"features": [{"geometry": {"type": "Polygon", "coordinates": [1, 2]}}]}`, nil },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      _, err := readGeoJSONFeatures(strings.NewReader(test.src))
+      if err == nil || (test.err != nil && !errors.Is(err, test.err)) {
+        t.Fatalf("got %v, exp %v", err, test.err)
+      }
+    })
+  }
+}
+
+func TestLoadFeatures(t *testing.T) {
+  dir := t.TempDir()
+
+  // write shapefile and GeoJSON file
+  shp, dbf := writeTestShapefile(shapePolygon, testLineFeatures)
+  files := map[string][]byte {
+    "test.shp": shp,
+    "test.dbf": dbf,
+    "test.geojson": []byte(`{"features": [{"geometry": {"type": "Point", "coordinates": [1, 2]}}]}`),
+    "test.txt": nil,
+  }
+  for name, data := range(files) {
+    if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  tests := []struct {
+    name string // file name
+    exp int // expected number of features, or -1 for error
+  } {
+    { "test.shp", 2 },
+    { "test.geojson", 1 },
+    { "test.txt", -1 },
+    { "missing.shp", -1 },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      fs, err := loadFeatures(filepath.Join(dir, test.name))
+      if test.exp < 0 {
+        if err == nil {
+          t.Fatal("got success, exp error")
+        }
+        return
+      }
+      if err != nil {
+        t.Fatal(err)
+      }
+      if len(fs) != test.exp {
+        t.Fatalf("got %d features, exp %d", len(fs), test.exp)
+      }
+    })
+  }
+}
@@ -2,6 +2,7 @@
 package geocoder
 
 import (
+  "context"
   net_url "net/url"
 )
 
@@ -36,7 +37,8 @@ type TigerLine struct {
   Side string `json:"side"`
 }
 
-// Address match result from [Locations()] or [Geographies()].
+// Address match result from [Locations()], [Geographies()],
+// [AddressLocations()], or [AddressGeographies()].
 type Match struct {
   // tiger data
   TigerLine TigerLine `json:"tigerLine"`
@@ -88,10 +90,20 @@ type Match struct {
 
   // map of ID to geography components.
   //
-  // Note: only populated for calls to `Geographies()`.
-  Geographies map[string][]map[string]any `json:"geographies"`
+  // Use the typed accessors (e.g. [Match.Counties], [Match.Tracts])
+  // to get parsed geographies for common layers.
+  //
+  // Note: only populated for calls to `Geographies()` and
+  // `AddressGeographies()`.
+  Geographies GeographyLayers `json:"geographies"`
 }
 
+// Map of geography layer name to geography components.
+//
+// Populated by [Geographies()], [AddressGeographies()], and
+// [GeographiesFromCoordinates()].
+type GeographyLayers map[string][]map[string]any
+
 // Default Census geocoder URL.
 var DefaultUrl = &net_url.URL{
   Scheme: "https",
@@ -100,37 +112,136 @@ var DefaultUrl = &net_url.URL{
 }
 
 // Default client.
-var DefaultClient = Client { Url: DefaultUrl }
+//
+// Pass layers to the top-level geographies functions to select the
+// geography layers returned by a single call.
+//
+// Requests are limited by [DefaultLimiter].  Set
+// `DefaultClient.Limiter` to change the limit of the top-level
+// functions.
+var DefaultClient = Client { Url: DefaultUrl, Limiter: DefaultLimiter }
 
 // Default benchmark ID.
 var DefaultBenchmark = "Public_AR_Current"
 
+// Default vintage ID, used when an empty vintage is given.
+var DefaultVintage = "Current_Current"
+
 // Get benchmarks from default client.
 func Benchmarks() ([]Benchmark, error) {
   return DefaultClient.Benchmarks()
 }
 
+// Get benchmarks from default client using given context.
+func BenchmarksContext(ctx context.Context) ([]Benchmark, error) {
+  return DefaultClient.BenchmarksContext(ctx)
+}
+
 // Get vintages matching benchmark ID from default client.
 func Vintages(benchmarkId string) ([]Vintage, error) {
   return DefaultClient.Vintages(benchmarkId)
 }
 
+// Get vintages matching benchmark ID from default client using given
+// context.
+func VintagesContext(ctx context.Context, benchmarkId string) ([]Vintage, error) {
+  return DefaultClient.VintagesContext(ctx, benchmarkId)
+}
+
 // Geocode street address with given benchmark ID using default client
 // and return address matches.
 func LocationsFromBenchmark(address, benchmarkId string) ([]Match, error) {
   return DefaultClient.LocationsFromBenchmark(address, benchmarkId)
 }
 
+// Geocode street address with given context and benchmark ID using
+// default client and return address matches.
+func LocationsFromBenchmarkContext(ctx context.Context, address, benchmarkId string) ([]Match, error) {
+  return DefaultClient.LocationsFromBenchmarkContext(ctx, address, benchmarkId)
+}
+
 // Geocode street address using default client and return address
 // matches.
 func Locations(address string) ([]Match, error) {
   return DefaultClient.Locations(address)
 }
 
+// Geocode street address using default client and given context, then
+// return address matches.
+func LocationsContext(ctx context.Context, address string) ([]Match, error) {
+  return DefaultClient.LocationsContext(ctx, address)
+}
+
 // Geocode street address using default client, given benchmark, and
 // given vintage, then return address matches with geography layers.
-func Geographies(address, benchmark, vintage string) ([]Match, error) {
-  return DefaultClient.Geographies(address, benchmark, vintage)
+//
+// If layers are given, then only the given geography layers are
+// returned.
+func Geographies(address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  return DefaultClient.Geographies(address, benchmark, vintage, layers...)
+}
+
+// Geocode street address using default client, given context, given
+// benchmark, and given vintage, then return address matches with
+// geography layers.
+func GeographiesContext(ctx context.Context, address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  return DefaultClient.GeographiesContext(ctx, address, benchmark, vintage, layers...)
+}
+
+// Geocode structured address with given benchmark ID using default
+// client and return address matches.
+func AddressLocationsFromBenchmark(address Address, benchmarkId string) ([]Match, error) {
+  return DefaultClient.AddressLocationsFromBenchmark(address, benchmarkId)
+}
+
+// Geocode structured address with given context and benchmark ID using
+// default client and return address matches.
+func AddressLocationsFromBenchmarkContext(ctx context.Context, address Address, benchmarkId string) ([]Match, error) {
+  return DefaultClient.AddressLocationsFromBenchmarkContext(ctx, address, benchmarkId)
+}
+
+// Geocode structured address using default client and return address
+// matches.
+func AddressLocations(address Address) ([]Match, error) {
+  return DefaultClient.AddressLocations(address)
+}
+
+// Geocode structured address using default client and given context,
+// then return address matches.
+func AddressLocationsContext(ctx context.Context, address Address) ([]Match, error) {
+  return DefaultClient.AddressLocationsContext(ctx, address)
+}
+
+// Geocode structured address using default client, given benchmark,
+// and given vintage, then return address matches with geography
+// layers.
+//
+// If layers are given, then only the given geography layers are
+// returned.
+func AddressGeographies(address Address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  return DefaultClient.AddressGeographies(address, benchmark, vintage, layers...)
+}
+
+// Geocode structured address using default client, given context,
+// given benchmark, and given vintage, then return address matches with
+// geography layers.
+func AddressGeographiesContext(ctx context.Context, address Address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  return DefaultClient.AddressGeographiesContext(ctx, address, benchmark, vintage, layers...)
+}
+
+// Get geography layers for given coordinates using default client,
+// given benchmark, and given vintage.
+//
+// If layers are given, then only the given geography layers are
+// returned.
+func GeographiesFromCoordinates(coords Coordinates, benchmark, vintage string, layers ...string) (GeographyLayers, error) {
+  return DefaultClient.GeographiesFromCoordinates(coords, benchmark, vintage, layers...)
+}
+
+// Get geography layers for given coordinates using default client,
+// given context, given benchmark, and given vintage.
+func GeographiesFromCoordinatesContext(ctx context.Context, coords Coordinates, benchmark, vintage string, layers ...string) (GeographyLayers, error) {
+  return DefaultClient.GeographiesFromCoordinatesContext(ctx, coords, benchmark, vintage, layers...)
 }
 
 // Batch geocode street addresses with given benchmark using default
@@ -139,12 +250,24 @@ func BatchLocationsFromBenchmark(rows []BatchInputRow, benchmark string) ([]Batc
   return DefaultClient.BatchLocationsFromBenchmark(rows, benchmark)
 }
 
+// Batch geocode street addresses with given context and benchmark using
+// default client then return matches.
+func BatchLocationsFromBenchmarkContext(ctx context.Context, rows []BatchInputRow, benchmark string) ([]BatchOutputRow, error) {
+  return DefaultClient.BatchLocationsFromBenchmarkContext(ctx, rows, benchmark)
+}
+
 // Batch geocode street addresses using default client then return
 // matches.
 func BatchLocations(rows []BatchInputRow) ([]BatchOutputRow, error) {
   return DefaultClient.BatchLocations(rows)
 }
 
+// Batch geocode street addresses using default client and given
+// context then return matches.
+func BatchLocationsContext(ctx context.Context, rows []BatchInputRow) ([]BatchOutputRow, error) {
+  return DefaultClient.BatchLocationsContext(ctx, rows)
+}
+
 // Batch geocode street addresses with given benchmark and vintage using
 // default client, then return matches with additional geography fields.
 //
@@ -158,3 +281,13 @@ func BatchLocations(rows []BatchInputRow) ([]BatchOutputRow, error) {
 func BatchGeographies(rows []BatchInputRow, benchmark, vintage string) ([]BatchOutputRow, error) {
   return DefaultClient.BatchGeographies(rows, benchmark, vintage)
 }
+
+// Batch geocode street addresses with given context, benchmark, and
+// vintage using default client, then return matches with additional
+// geography fields.
+//
+// See [BatchGeographies] for a list of the additional BatchOutputRow
+// fields populated by this function.
+func BatchGeographiesContext(ctx context.Context, rows []BatchInputRow, benchmark, vintage string) ([]BatchOutputRow, error) {
+  return DefaultClient.BatchGeographiesContext(ctx, rows, benchmark, vintage)
+}
@@ -1,12 +1,82 @@
 package geocoder
 
 import (
+  "bytes"
   _ "embed"
   "encoding/json"
+  "flag"
+  "log"
+  "net/http"
   "os"
   "testing"
+
+  "pablotron.org/census-geocoder/geocoder/cassette"
 )
 
+// regenerate golden files instead of comparing against them
+var updateGolden = flag.Bool("update", false, "update golden files in testdata/golden")
+
+// record live API responses instead of replaying them
+var recordCassette = flag.Bool("record", false, "record live API responses to " + liveCassette)
+
+// cassette with live API responses recorded with -record
+const liveCassette = "testdata/cassettes/live.json"
+
+// synthetic cassette which is replayed if the live cassette does not
+// exist.
+//
+// Not a recording: it was assembled from the captured responses in
+// geocodertest/fixtures and testdata/data, with the echoed request
+// input adjusted to match each request and with only a Content-Type
+// response header.  The Gallows Rd and 2020 benchmark vintages
+// responses were reconstructed from captured data (the Gallows Rd
+// batch match and the Fairfax County layers of the 2525 Buckelew Dr
+// response).
+const syntheticCassette = "testdata/cassettes/synthetic.json"
+
+// Run tests and examples with live API requests replayed from (or,
+// with -record, recorded to) a cassette.
+//
+// The live cassette is replayed if it exists; otherwise the synthetic
+// cassette is replayed.  Tests never quietly send requests to the live
+// API: requests which are not in the cassette fail.  Requests to other
+// hosts (e.g. mock servers) are not recorded or replayed.
+func TestMain(m *testing.M) {
+  flag.Parse()
+
+  // get mode and cassette path
+  mode, path := cassette.ModeReplay, liveCassette
+  if *recordCassette {
+    mode = cassette.ModeRecord
+  } else if _, err := os.Stat(liveCassette); err != nil {
+    path = syntheticCassette
+  }
+
+  // create recorder
+  r, err := cassette.New(path, mode)
+  if err != nil {
+    log.Fatal(err)
+  }
+  r.Transport = http.DefaultTransport
+  r.Passthrough = func(req *http.Request) bool {
+    return req.URL.Host != DefaultUrl.Host
+  }
+
+  // send requests from default HTTP clients through recorder
+  http.DefaultTransport = r
+  code := m.Run()
+  http.DefaultTransport = r.Transport
+
+  // save recorded interactions
+  if *recordCassette {
+    if err := r.Save(); err != nil {
+      log.Fatal(err)
+    }
+  }
+
+  os.Exit(code)
+}
+
 //go:embed testdata/data/benchmarks.json
 var mockBenchmarksJson []byte
 
@@ -30,6 +100,14 @@ var testBenchmarkId = "Public_AR_Current"
 // test address
 var testAddress = "4600 Silver Hill Rd, Washington, DC 20233"
 
+// test structured address
+var testStructuredAddress = Address {
+  Street: "4600 Silver Hill Rd",
+  City: "Washington",
+  State: "DC",
+  Zip: "20233",
+}
+
 func TestVintages(t *testing.T) {
   if testing.Short() {
     t.Skip("skipping in short mode")
@@ -93,6 +171,63 @@ func TestGeographies(t *testing.T) {
   }
 }
 
+func TestAddressLocationsFromBenchmark(t *testing.T) {
+  if testing.Short() {
+    t.Skip("skipping in short mode")
+  }
+
+  // get locations, check for error
+  _, err := AddressLocationsFromBenchmark(testStructuredAddress, testBenchmarkId)
+  if err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestAddressLocations(t *testing.T) {
+  if testing.Short() {
+    t.Skip("skipping in short mode")
+  }
+
+  // get locations, check for error
+  _, err := AddressLocations(testStructuredAddress)
+  if err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestAddressGeographies(t *testing.T) {
+  testBenchmark := "Public_AR_Census2020"
+  testVintage := "Census2010_Census2020"
+
+  if testing.Short() {
+    t.Skip("skipping in short mode")
+  }
+
+  // get geographies, check for error
+  _, err := AddressGeographies(testStructuredAddress, testBenchmark, testVintage)
+  if err != nil {
+    t.Fatal(err)
+  }
+}
+
+//go:embed testdata/data/coordinates.json
+var mockCoordinatesJson []byte
+
+// test coordinates
+var testCoordinates = Coordinates { -77.19902696904677, 38.88701576684785 }
+
+func TestGeographiesFromCoordinates(t *testing.T) {
+  if testing.Short() {
+    t.Skip("skipping in short mode")
+  }
+
+  // get geographies, check for error
+  _, err := GeographiesFromCoordinates(testCoordinates, "2020", "2020")
+  if err != nil {
+    t.Fatal(err)
+  }
+}
+
 func getBatchInputRows(t *testing.T) []BatchInputRow {
   // open input file
   f, err := os.Open("testdata/data/batch-input.csv")
@@ -129,6 +264,29 @@ func getBatchOutputRows(t *testing.T, path string) []BatchOutputRow {
   return rows
 }
 
+// Order expected batch output rows to match the order of the given
+// batch input rows.
+func orderBatchOutputRows(t *testing.T, in []BatchInputRow, exp []BatchOutputRow) []BatchOutputRow {
+  // map ID to expected row
+  rows := make(map[string]BatchOutputRow)
+  for _, row := range(exp) {
+    rows[row.Id] = row
+  }
+
+  // build result in input order
+  r := make([]BatchOutputRow, len(in))
+  for i, row := range(in) {
+    outRow, ok := rows[row.Id]
+    if !ok {
+      t.Fatalf("missing expected row: %s", row.Id)
+    }
+    r[i] = outRow
+  }
+
+  // return result
+  return r
+}
+
 func TestBatchLocationsFromBenchmark(t *testing.T) {
   if testing.Short() {
     t.Skip("skipping in short mode")
@@ -215,3 +373,36 @@ func TestBatchGeographies(t *testing.T) {
     }
   }
 }
+
+// Get matches from testdata/data/geographies.json.
+func getMatches(t *testing.T) []Match {
+  var r []Match
+  if err := json.Unmarshal(mockGeographiesJson, &r); err != nil {
+    t.Fatal(err)
+  }
+
+  return r
+}
+
+// Compare output against golden file in testdata/golden.
+//
+// If the -update flag is set, then the golden file is written instead.
+func checkGolden(t *testing.T, name string, got []byte) {
+  path := "testdata/golden/" + name
+  if *updateGolden {
+    if err := os.WriteFile(path, got, 0644); err != nil {
+      t.Fatal(err)
+    }
+    return
+  }
+
+  // read golden file
+  exp, err := os.ReadFile(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if !bytes.Equal(got, exp) {
+    t.Fatalf("%s: got:\n%s\nexp:\n%s", name, got, exp)
+  }
+}
@@ -0,0 +1,152 @@
+package geocodertest
+
+import (
+  "encoding/csv"
+  "math/rand"
+  "net/http"
+  "strconv"
+  "strings"
+
+  "pablotron.org/census-geocoder/geocoder"
+)
+
+// Batch response options set by [Server.SetBatchOptions].
+type BatchOptions struct {
+  // shuffle rows in each response
+  Shuffle bool
+
+  // IDs of rows to omit from responses
+  Drop []string
+
+  // add an echoed header row (`"id","address, city, state, zip","No_Match"`)
+  // to each response, like the live API does for uploads which start
+  // with a header row
+  EchoHeader bool
+}
+
+// echoed header row written by the live API
+var echoHeader = []string { "id", "address, city, state, zip", "No_Match" }
+
+// Set batch response options.
+//
+// Each uploaded row is answered from the matches registered with
+// [Server.SetMatches] or [Server.SetMatchesFor], using the non-empty
+// address, city, state, and zip joined with ", " as the address.  Rows
+// with one match are written as "Match" ("Exact" if the matched address
+// equals the input address, ignoring case, whitespace, and commas),
+// rows with several matches are written as "Tie", and other rows are
+// written as "No_Match".
+//
+// Use [Failure.Id] to fail uploads which contain a specific row.
+func (s *Server) SetBatchOptions(o BatchOptions) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.batch = o
+}
+
+// Parse form values and uploaded batch rows.
+func parseBatchRows(r *http.Request) ([]geocoder.BatchInputRow, error) {
+  f, _, err := r.FormFile("addressFile")
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+
+  return geocoder.NewBatchInputReader(f).ReadAll()
+}
+
+// Does the list of batch rows contain a row with the given ID?
+func hasId(rows []geocoder.BatchInputRow, id string) bool {
+  for _, row := range(rows) {
+    if row.Id == id {
+      return true
+    }
+  }
+
+  return false
+}
+
+// Normalize address for exact comparison: ignore case, whitespace, and
+// commas.
+func compactAddress(s string) string {
+  return normalizeAddress(strings.ReplaceAll(s, ",", " "))
+}
+
+// Get batch output record for uploaded row.
+func (s *Server) batchRecord(row geocoder.BatchInputRow, benchmark, vintage string, geographies bool) []string {
+  input := strings.Join([]string { row.Address, row.City, row.State, row.Zip }, ", ")
+  address := joinAddress(row.Address, row.City, row.State, row.Zip)
+
+  // find matches
+  matches, _ := s.findMatches(address, benchmark, vintage)
+  switch len(matches) {
+  case 0:
+    return []string { row.Id, input, "No_Match" }
+  case 1:
+    // single match (handled below)
+  default:
+    return []string { row.Id, input, "Tie" }
+  }
+
+  // check for exact match
+  m := matches[0]
+  exact := "Non_Exact"
+  if compactAddress(address) == compactAddress(m.MatchedAddress) {
+    exact = "Exact"
+  }
+
+  r := []string {
+    row.Id,
+    input,
+    "Match",
+    exact,
+    m.MatchedAddress,
+    strconv.FormatFloat(m.Coordinates.X, 'f', -1, 64) + "," + strconv.FormatFloat(m.Coordinates.Y, 'f', -1, 64),
+    m.TigerLine.Id,
+    m.TigerLine.Side,
+  }
+
+  // add state, county, tract, and block from Census Blocks layer
+  if geographies {
+    if blocks, err := m.Blocks(); err == nil && len(blocks) > 0 {
+      b := blocks[0]
+      r = append(r, b.State, b.County, b.Tract, b.Block)
+    } else {
+      r = append(r, "", "", "", "")
+    }
+  }
+
+  return r
+}
+
+// Write batch response for uploaded rows.
+func (s *Server) writeBatch(w http.ResponseWriter, rows []geocoder.BatchInputRow, benchmark, vintage string, geographies bool) {
+  // build set of dropped IDs
+  drop := make(map[string]bool, len(s.batch.Drop))
+  for _, id := range(s.batch.Drop) {
+    drop[id] = true
+  }
+
+  // build records
+  var records [][]string
+  if s.batch.EchoHeader {
+    records = append(records, echoHeader)
+  }
+  for _, row := range(rows) {
+    if !drop[row.Id] {
+      records = append(records, s.batchRecord(row, benchmark, vintage, geographies))
+    }
+  }
+
+  // shuffle records
+  if s.batch.Shuffle {
+    rand.Shuffle(len(records), func(i, j int) {
+      records[i], records[j] = records[j], records[i]
+    })
+  }
+
+  // write records
+  w.Header().Set("Content-Type", "text/csv")
+  cw := csv.NewWriter(w)
+  cw.WriteAll(records)
+}
@@ -0,0 +1,199 @@
+package geocodertest
+
+import (
+  "errors"
+  "fmt"
+  "reflect"
+  "sort"
+  "testing"
+
+  "pablotron.org/census-geocoder/geocoder"
+)
+
+// Generate batch input rows with sequential IDs.
+func genRows(n int) []geocoder.BatchInputRow {
+  r := make([]geocoder.BatchInputRow, n)
+  for i := range(r) {
+    r[i] = geocoder.BatchInputRow { Id: fmt.Sprint(i), Address: fmt.Sprintf("%d main st", i) }
+  }
+  return r
+}
+
+// Get sorted IDs of uploaded rows in each batch request.
+func uploadedIds(s *Server) [][]string {
+  var r [][]string
+  for _, req := range(s.Requests()) {
+    ids := make([]string, len(req.Rows))
+    for i, row := range(req.Rows) {
+      ids[i] = row.Id
+    }
+    sort.Strings(ids)
+    r = append(r, ids)
+  }
+
+  sort.Slice(r, func(i, j int) bool { return r[i][0] < r[j][0] })
+  return r
+}
+
+func TestServerBatch(t *testing.T) {
+  s := NewServer()
+  defer s.Close()
+
+  s.SetMatches("1600 Pennsylvania Ave NW, Washington, DC, 20500", geocoder.Match {
+    MatchedAddress: "1600 PENNSYLVANIA AVE NW, WASHINGTON, DC, 20500",
+    Coordinates: geocoder.Coordinates { X: -77.03654, Y: 38.89767 },
+    TigerLine: geocoder.TigerLine { Id: "76225813", Side: "L" },
+    Geographies: geocoder.GeographyLayers {
+      "Census Blocks": []map[string]any {{ "STATE": "11", "COUNTY": "001", "TRACT": "006202", "BLOCK": "1031" }},
+    },
+  })
+  s.SetMatches("1600 Penn Ave, Washington, DC", geocoder.Match {
+    MatchedAddress: "1600 PENNSYLVANIA AVE NW, WASHINGTON, DC, 20500",
+  })
+  s.SetMatches("1 main st", geocoder.Match{}, geocoder.Match{})
+
+  rows := []geocoder.BatchInputRow {
+    { Id: "exact", Address: "1600 Pennsylvania Ave NW", City: "Washington", State: "DC", Zip: "20500" },
+    { Id: "non-exact", Address: "1600 Penn Ave", City: "Washington", State: "DC" },
+    { Id: "tie", Address: "1 main st" },
+    { Id: "none", Address: "nowhere" },
+  }
+
+  got, err := s.Client(geocoder.WithDefaultBenchmark("4")).BatchGeographies(rows, "", "Current_Current")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  exp := []geocoder.BatchOutputRow {{
+    Id: "exact",
+    InputAddress: "1600 Pennsylvania Ave NW, Washington, DC, 20500",
+    Match: true,
+    Exact: true,
+    MatchAddress: "1600 PENNSYLVANIA AVE NW, WASHINGTON, DC, 20500",
+    Coordinates: geocoder.Coordinates { X: -77.03654, Y: 38.89767 },
+    TigerLine: geocoder.TigerLine { Id: "76225813", Side: "L" },
+    State: "11",
+    County: "001",
+    Tract: "006202",
+    Block: "1031",
+  }, {
+    Id: "non-exact",
+    InputAddress: "1600 Penn Ave, Washington, DC, ",
+    Match: true,
+    MatchAddress: "1600 PENNSYLVANIA AVE NW, WASHINGTON, DC, 20500",
+  }, {
+    Id: "tie",
+    InputAddress: "1 main st, , , ",
+  }, {
+    Id: "none",
+    InputAddress: "nowhere, , , ",
+  }}
+
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+
+  // check recorded fields
+  reqs := s.Requests()
+  if len(reqs) != 1 {
+    t.Fatalf("got %d requests, exp 1", len(reqs))
+  }
+  r := reqs[0]
+  if r.Path != "/geographies/addressbatch" || r.Params.Get("benchmark") != "4" || r.Params.Get("vintage") != "Current_Current" {
+    t.Fatalf("got %v, exp geographies batch request", r)
+  }
+  if !reflect.DeepEqual(r.Rows, rows) {
+    t.Fatalf("got rows %v, exp %v", r.Rows, rows)
+  }
+}
+
+func TestServerBatchChunks(t *testing.T) {
+  s := NewServer()
+  defer s.Close()
+  s.SetBatchOptions(BatchOptions { Shuffle: true })
+
+  c := s.Client(geocoder.WithBatchSize(3), geocoder.WithBatchConcurrency(2))
+  rows := genRows(7)
+  got, err := c.BatchLocations(rows)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // check that shuffled results were returned in input order
+  for i, row := range(got) {
+    if row.Id != rows[i].Id {
+      t.Fatalf("%d: got %s, exp %s", i, row.Id, rows[i].Id)
+    }
+  }
+
+  // check chunks
+  exp := [][]string {{ "0", "1", "2" }, { "3", "4", "5" }, { "6" }}
+  if got := uploadedIds(s); !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got chunks %v, exp %v", got, exp)
+  }
+}
+
+func TestServerBatchReport(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    opts BatchOptions // batch options
+    missing []string // expected missing IDs
+    unexpected []string // expected unexpected IDs
+    rows int // expected number of reconciled rows
+  } {
+    { "drop", BatchOptions { Drop: []string { "1" } }, []string { "1" }, nil, 2 },
+    { "echo header", BatchOptions { EchoHeader: true }, nil, []string { "id" }, 3 },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      s := NewServer()
+      defer s.Close()
+      s.SetBatchOptions(test.opts)
+
+      rows, err := s.Client().BatchLocations(genRows(3))
+      var reportErr *geocoder.BatchReportError
+      if !errors.As(err, &reportErr) {
+        t.Fatalf("got %v, exp BatchReportError", err)
+      }
+      if len(rows) != test.rows {
+        t.Fatalf("got %d rows, exp %d", len(rows), test.rows)
+      }
+
+      report := reportErr.Report
+      if !reflect.DeepEqual(report.Missing, test.missing) || !reflect.DeepEqual(report.Unexpected, test.unexpected) {
+        t.Fatalf("got %v, exp missing %v and unexpected %v", report, test.missing, test.unexpected)
+      }
+    })
+  }
+}
+
+func TestServerBatchFailChunk(t *testing.T) {
+  s := NewServer()
+  defer s.Close()
+  s.Fail(Failure { Id: "4", Status: 503, Times: 1 })
+
+  // fail the chunk which contains row 4
+  c := s.Client(geocoder.WithBatchSize(3), geocoder.WithBatchConcurrency(1))
+  rows, err := c.BatchLocations(genRows(6))
+  var chunkErr *geocoder.BatchChunkError
+  if !errors.As(err, &chunkErr) {
+    t.Fatalf("got %v, exp BatchChunkError", err)
+  }
+
+  // check that rows of the first chunk are returned
+  if len(rows) != 3 {
+    t.Fatalf("got %d rows, exp 3", len(rows))
+  }
+  var apiErr *geocoder.APIError
+  if !errors.As(err, &apiErr) || apiErr.StatusCode != 503 {
+    t.Fatalf("got %v, exp status 503", err)
+  }
+
+  // check that the failure was used up, so a retry succeeds
+  c.Retry = geocoder.RetryPolicy { MaxAttempts: 2, BaseDelay: 1 }
+  s.Fail(Failure { Id: "4", Status: 503, Times: 1 })
+  if _, err := c.BatchLocations(genRows(6)); err != nil {
+    t.Fatal(err)
+  }
+}
@@ -0,0 +1,43 @@
+package geocodertest
+
+import (
+  "embed"
+  "path"
+)
+
+// Census geocoder API responses.
+//
+// Most fixtures were captured from the live API.  The structured
+// address and coordinates fixtures (address-locations.json,
+// address-geographies.json, and coordinates.json) were written by hand
+// in the format of live responses.  Run "go run
+// ./internal/update-fixtures" to regenerate all fixtures from the live
+// API.
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+// Get fixture by file name (e.g. "locations.json").
+//
+// Panics if the fixture does not exist.
+func Fixture(name string) []byte {
+  data, err := fixtures.ReadFile(path.Join("fixtures", name))
+  if err != nil {
+    panic(err)
+  }
+
+  return data
+}
+
+// default fixtures by URL path
+var defaultFixtures = map[string]string {
+  "/benchmarks": "benchmarks.json",
+  "/vintages": "vintages.json",
+  "/locations/onelineaddress": "locations.json",
+  "/geographies/onelineaddress": "geographies.json",
+  "/locations/address": "address-locations.json",
+  "/geographies/address": "address-geographies.json",
+  "/geographies/coordinates": "coordinates.json",
+  "/locations/addressbatch": "batch-locations-2020.csv",
+  "/geographies/addressbatch": "batch-geographies-2020-2020.csv",
+}
@@ -0,0 +1,478 @@
+// Fake Census geocoder API server for tests.
+//
+// Create a server with [NewServer], register responses for addresses
+// and coordinates, inject failures, then check the recorded requests:
+//
+//   s := geocodertest.NewServer()
+//   defer s.Close()
+//
+//   s.SetMatches("4600 silver hill rd, washington, dc 20233", match)
+//   s.Fail(geocodertest.Failure { Path: "/geographies/coordinates", Status: 503, Times: 1 })
+//
+//   c := s.Client()
+//   matches, err := c.Locations("4600 Silver Hill Rd, Washington, DC 20233")
+//
+// Requests which do not match a registered response are answered with
+// fixture API responses (see [Fixture]), unless the server is strict
+// (see [Server.SetStrict]).
+//
+// Batch uploads are parsed and each uploaded row is answered from the
+// registered matches (see [Server.SetBatchOptions]).
+package geocodertest
+
+import (
+  "encoding/json"
+  "net/http"
+  "net/http/httptest"
+  net_url "net/url"
+  "strconv"
+  "strings"
+  "sync"
+
+  "pablotron.org/census-geocoder/geocoder"
+)
+
+// Request received by a fake server.
+type Request struct {
+  // HTTP method
+  Method string
+
+  // URL path (e.g. "/locations/onelineaddress")
+  Path string
+
+  // query parameters and form values
+  Params net_url.Values
+
+  // request headers
+  Header http.Header
+
+  // uploaded rows (batch requests)
+  Rows []geocoder.BatchInputRow
+}
+
+// Failure injected by [Server.Fail].
+type Failure struct {
+  // URL path to fail (e.g. "/locations/onelineaddress"), or empty to
+  // fail all paths
+  Path string
+
+  // HTTP status (default: 500)
+  Status int
+
+  // error messages, written as the `errors` array of a JSON response
+  Errors []string
+
+  // raw response body, written instead of Errors if non-empty
+  Body string
+
+  // extra response headers (e.g. Retry-After)
+  Header http.Header
+
+  // batch row ID; if non-empty, then only batch uploads which contain
+  // a row with this ID fail
+  Id string
+
+  // number of requests to fail, or 0 to fail every matching request
+  Times int
+}
+
+// Registered address matches.
+type matchEntry struct {
+  benchmark, vintage string // benchmark and vintage, or empty for any
+  address string // normalized address
+  matches []geocoder.Match // matches
+}
+
+// Registered coordinate geographies.
+type geographiesEntry struct {
+  benchmark, vintage string // benchmark and vintage, or empty for any
+  coords geocoder.Coordinates // coordinates
+  layers geocoder.GeographyLayers // geography layers
+}
+
+// Fake Census geocoder API server.
+//
+// Methods are safe for concurrent use.
+type Server struct {
+  *httptest.Server
+
+  // base URL of server
+  BaseURL *net_url.URL
+
+  mu sync.Mutex // mutex for fields below
+  strict bool // answer unregistered lookups with no results?
+  matches []matchEntry // registered address matches
+  geographies []geographiesEntry // registered coordinate geographies
+  failures []*Failure // injected failures
+  batch BatchOptions // batch response options
+  requests []Request // received requests
+}
+
+// Start new fake server.
+//
+// Call [Server.Close] when finished.
+func NewServer() *Server {
+  s := &Server{}
+  s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+  // parse server URL (cannot fail for httptest URLs)
+  url, err := net_url.Parse(s.Server.URL)
+  if err != nil {
+    s.Server.Close()
+    panic(err)
+  }
+  s.BaseURL = url
+
+  return s
+}
+
+// Create geocoder client which sends requests to this server.
+//
+// The client does not retry or rate limit requests.  Options are
+// applied after the base URL and HTTP client are set.
+//
+// The HTTP client of the test server is copied to [geocoder.Client]
+// rather than shared, so tests can set the transport of the returned
+// client (e.g. to a cassette recorder) without changing the server.
+func (s *Server) Client(opts ...geocoder.Option) geocoder.Client {
+  return geocoder.NewClient(append([]geocoder.Option {
+    geocoder.WithBaseURL(s.BaseURL),
+    func(c *geocoder.Client) { c.Client = *s.Server.Client() },
+  }, opts...)...)
+}
+
+// Answer unregistered lookups with no results instead of fixtures?
+func (s *Server) SetStrict(strict bool) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.strict = strict
+}
+
+// Normalize address: lowercase, trim, and collapse whitespace.
+func normalizeAddress(s string) string {
+  return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// Register matches for address with any benchmark and vintage.
+//
+// Addresses are compared without regard to case or whitespace.
+// Structured address requests are matched by their non-empty
+// components joined with ", " (e.g. "4600 silver hill rd, washington,
+// dc, 20233").
+//
+// Register an address with no matches to simulate a failed match.
+func (s *Server) SetMatches(address string, matches ...geocoder.Match) {
+  s.SetMatchesFor("", "", address, matches...)
+}
+
+// Register matches for address with given benchmark and vintage.
+//
+// An empty benchmark or vintage matches any value.  Benchmarks and
+// vintages are compared as sent by the client, so register the ID or
+// name that the client uses.  The most specific registration wins.
+func (s *Server) SetMatchesFor(benchmark, vintage, address string, matches ...geocoder.Match) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+
+  s.matches = append(s.matches, matchEntry {
+    benchmark: benchmark,
+    vintage: vintage,
+    address: normalizeAddress(address),
+    matches: matches,
+  })
+}
+
+// Register geography layers for coordinates with any benchmark and
+// vintage.
+func (s *Server) SetGeographies(coords geocoder.Coordinates, layers geocoder.GeographyLayers) {
+  s.SetGeographiesFor("", "", coords, layers)
+}
+
+// Register geography layers for coordinates with given benchmark and
+// vintage.
+//
+// An empty benchmark or vintage matches any value.  The most specific
+// registration wins.
+func (s *Server) SetGeographiesFor(benchmark, vintage string, coords geocoder.Coordinates, layers geocoder.GeographyLayers) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+
+  s.geographies = append(s.geographies, geographiesEntry {
+    benchmark: benchmark,
+    vintage: vintage,
+    coords: coords,
+    layers: layers,
+  })
+}
+
+// Inject failure.
+//
+// Failures are checked in the order they were added, before any
+// registered response.
+func (s *Server) Fail(f Failure) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.failures = append(s.failures, &f)
+}
+
+// Remove all injected failures.
+func (s *Server) ClearFailures() {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.failures = nil
+}
+
+// Get copy of received requests, in the order they were received.
+func (s *Server) Requests() []Request {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return append([]Request{}, s.requests...)
+}
+
+// Remove all received requests.
+func (s *Server) ClearRequests() {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.requests = nil
+}
+
+// Get score of registration with given benchmark and vintage for
+// request with given benchmark and vintage, or -1 if the registration
+// does not apply.
+func score(eb, ev, b, v string) int {
+  if (eb != "" && eb != b) || (ev != "" && ev != v) {
+    return -1
+  }
+
+  r := 0
+  if eb != "" {
+    r += 2
+  }
+  if ev != "" {
+    r += 1
+  }
+
+  return r
+}
+
+// Find registered matches for address.
+func (s *Server) findMatches(address, benchmark, vintage string) ([]geocoder.Match, bool) {
+  address = normalizeAddress(address)
+
+  best, r := -1, []geocoder.Match(nil)
+  for _, e := range(s.matches) {
+    if e.address == address {
+      if n := score(e.benchmark, e.vintage, benchmark, vintage); n >= best && n >= 0 {
+        best, r = n, e.matches
+      }
+    }
+  }
+
+  return r, best >= 0
+}
+
+// Find registered geography layers for coordinates.
+func (s *Server) findGeographies(coords geocoder.Coordinates, benchmark, vintage string) (geocoder.GeographyLayers, bool) {
+  best, r := -1, geocoder.GeographyLayers(nil)
+  for _, e := range(s.geographies) {
+    if e.coords == coords {
+      if n := score(e.benchmark, e.vintage, benchmark, vintage); n >= best && n >= 0 {
+        best, r = n, e.layers
+      }
+    }
+  }
+
+  return r, best >= 0
+}
+
+// Find first injected failure for path and uploaded batch rows, and
+// decrement its count.
+func (s *Server) findFailure(path string, rows []geocoder.BatchInputRow) *Failure {
+  for i, f := range(s.failures) {
+    if (f.Path != "" && f.Path != path) || (f.Id != "" && !hasId(rows, f.Id)) {
+      continue
+    }
+
+    // decrement count, remove exhausted failures
+    if f.Times > 0 {
+      f.Times--
+      if f.Times == 0 {
+        s.failures = append(s.failures[:i:i], s.failures[i + 1:]...)
+      }
+    }
+
+    return f
+  }
+
+  return nil
+}
+
+// Write failure response.
+func writeFailure(w http.ResponseWriter, f *Failure) {
+  for k, vals := range(f.Header) {
+    for _, v := range(vals) {
+      w.Header().Add(k, v)
+    }
+  }
+
+  status := f.Status
+  if status == 0 {
+    status = http.StatusInternalServerError
+  }
+
+  if f.Body != "" {
+    w.WriteHeader(status)
+    w.Write([]byte(f.Body))
+    return
+  }
+
+  errs := f.Errors
+  if errs == nil {
+    errs = []string { http.StatusText(status) }
+  }
+  writeJSON(w, status, map[string]any {
+    "errors": errs,
+    "status": strconv.Itoa(status),
+  })
+}
+
+// Write JSON response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(status)
+  json.NewEncoder(w).Encode(v)
+}
+
+// Write fixture for path.
+func writeFixture(w http.ResponseWriter, path string) {
+  if name, ok := defaultFixtures[path]; ok {
+    w.Write(Fixture(name))
+  } else {
+    http.NotFound(w, nil)
+  }
+}
+
+// Join non-empty address components with ", ".
+func joinAddress(vals ...string) string {
+  var parts []string
+  for _, v := range(vals) {
+    if v != "" {
+      parts = append(parts, v)
+    }
+  }
+
+  return strings.Join(parts, ", ")
+}
+
+// Handle request.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+  // parse query and form values, and uploaded batch rows
+  var rows []geocoder.BatchInputRow
+  var rowsErr error
+  if strings.HasSuffix(r.URL.Path, "/addressbatch") {
+    rows, rowsErr = parseBatchRows(r)
+  } else {
+    r.ParseForm()
+  }
+
+  s.mu.Lock()
+  defer s.mu.Unlock()
+
+  // record request
+  s.requests = append(s.requests, Request {
+    Method: r.Method,
+    Path: r.URL.Path,
+    Params: r.Form,
+    Header: r.Header.Clone(),
+    Rows: rows,
+  })
+
+  // check for injected failure
+  if f := s.findFailure(r.URL.Path, rows); f != nil {
+    writeFailure(w, f)
+    return
+  }
+
+  q := r.Form
+  benchmark, vintage := q.Get("benchmark"), q.Get("vintage")
+
+  switch r.URL.Path {
+  case "/locations/addressbatch", "/geographies/addressbatch":
+    // check for invalid upload
+    if rowsErr != nil {
+      http.Error(w, rowsErr.Error(), http.StatusBadRequest)
+      return
+    }
+
+    s.writeBatch(w, rows, benchmark, vintage, strings.HasPrefix(r.URL.Path, "/geographies/"))
+  case "/locations/onelineaddress", "/geographies/onelineaddress", "/locations/address", "/geographies/address":
+    // get address
+    address := q.Get("address")
+    if strings.HasSuffix(r.URL.Path, "/address") {
+      address = joinAddress(q.Get("street"), q.Get("city"), q.Get("state"), q.Get("zip"))
+    }
+
+    // check for empty address
+    if strings.TrimSpace(address) == "" {
+      writeFailure(w, &Failure {
+        Status: http.StatusBadRequest,
+        Errors: []string { "Address cannot be empty and cannot exceed 100 characters" },
+      })
+      return
+    }
+
+    // find matches
+    matches, ok := s.findMatches(address, benchmark, vintage)
+    if !ok && !s.strict {
+      writeFixture(w, r.URL.Path)
+      return
+    }
+    if matches == nil {
+      matches = []geocoder.Match{}
+    }
+
+    writeJSON(w, http.StatusOK, map[string]any {
+      "result": map[string]any {
+        "input": map[string]any {
+          "address": map[string]string { "address": address },
+          "benchmark": map[string]string { "id": benchmark },
+        },
+        "addressMatches": matches,
+      },
+    })
+  case "/geographies/coordinates":
+    // parse coordinates
+    x, xErr := strconv.ParseFloat(q.Get("x"), 64)
+    y, yErr := strconv.ParseFloat(q.Get("y"), 64)
+    if xErr != nil || yErr != nil {
+      writeFailure(w, &Failure {
+        Status: http.StatusBadRequest,
+        Errors: []string { "Invalid coordinates" },
+      })
+      return
+    }
+
+    // find geographies
+    layers, ok := s.findGeographies(geocoder.Coordinates { X: x, Y: y }, benchmark, vintage)
+    if !ok && !s.strict {
+      writeFixture(w, r.URL.Path)
+      return
+    }
+    if layers == nil {
+      layers = geocoder.GeographyLayers{}
+    }
+
+    writeJSON(w, http.StatusOK, map[string]any {
+      "result": map[string]any {
+        "input": map[string]any {
+          "location": map[string]float64 { "x": x, "y": y },
+          "benchmark": map[string]string { "id": benchmark },
+          "vintage": map[string]string { "id": vintage },
+        },
+        "geographies": layers,
+      },
+    })
+  default:
+    writeFixture(w, r.URL.Path)
+  }
+}
@@ -0,0 +1,210 @@
+package geocodertest
+
+import (
+  "errors"
+  "reflect"
+  "testing"
+
+  "pablotron.org/census-geocoder/geocoder"
+)
+
+// test address
+const testAddress = "4600 Silver Hill Rd, Washington, DC 20233"
+
+// test coordinates
+var testCoordinates = geocoder.Coordinates { X: -77.19902696904677, Y: 38.88701576684785 }
+
+func TestServerFixtures(t *testing.T) {
+  s := NewServer()
+  defer s.Close()
+  c := s.Client()
+
+  // check unregistered address
+  matches, err := c.Locations("anything")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(matches) != 1 || matches[0].MatchedAddress != "4600 SILVER HILL RD, WASHINGTON, DC, 20233" {
+    t.Fatalf("got %v, exp fixture match", matches)
+  }
+
+  // check unregistered coordinates
+  layers, err := c.GeographiesFromCoordinates(testCoordinates, "2020", "2020")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(layers["States"]) != 1 {
+    t.Fatalf("got %v, exp fixture layers", layers)
+  }
+
+  // check benchmarks
+  benchmarks, err := c.Benchmarks()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(benchmarks) == 0 {
+    t.Fatal("got no benchmarks, exp fixture benchmarks")
+  }
+}
+
+func TestServerMatches(t *testing.T) {
+  s := NewServer()
+  defer s.Close()
+  c := s.Client()
+
+  anyMatch := geocoder.Match { MatchedAddress: "ANY" }
+  exact := geocoder.Match { MatchedAddress: "EXACT" }
+  s.SetMatches(testAddress, anyMatch)
+  s.SetMatchesFor("4", "", testAddress, exact)
+  s.SetMatches("nowhere")
+  s.SetMatches("4600 silver hill rd, washington, dc, 20233", exact)
+
+  tests := []struct {
+    name string // test name
+    fn func() ([]geocoder.Match, error) // request function
+    exp []geocoder.Match // expected matches
+  } {{
+    name: "any benchmark",
+    fn: func() ([]geocoder.Match, error) {
+      return c.LocationsFromBenchmark(" 4600 SILVER HILL RD, washington, dc 20233", "2020")
+    },
+    exp: []geocoder.Match { anyMatch },
+  }, {
+    name: "specific benchmark",
+    fn: func() ([]geocoder.Match, error) {
+      return c.LocationsFromBenchmark(testAddress, "4")
+    },
+    exp: []geocoder.Match { exact },
+  }, {
+    name: "no match",
+    fn: func() ([]geocoder.Match, error) {
+      return c.Locations("nowhere")
+    },
+    exp: []geocoder.Match{},
+  }, {
+    name: "structured address",
+    fn: func() ([]geocoder.Match, error) {
+      return c.AddressLocations(geocoder.Address {
+        Street: "4600 Silver Hill Rd",
+        City: "Washington",
+        State: "DC",
+        Zip: "20233",
+      })
+    },
+    exp: []geocoder.Match { exact },
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      got, err := test.fn()
+      if err != nil {
+        t.Fatal(err)
+      }
+      if !reflect.DeepEqual(got, test.exp) {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestServerGeographies(t *testing.T) {
+  s := NewServer()
+  defer s.Close()
+  s.SetStrict(true)
+
+  exp := geocoder.GeographyLayers {
+    "States": []map[string]any {{ "GEOID": "51" }},
+  }
+  s.SetGeographiesFor("2020", "2020", testCoordinates, exp)
+
+  c := s.Client()
+  got, err := c.GeographiesFromCoordinates(testCoordinates, "2020", "2020")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+
+  // check other vintage with strict server
+  got, err = c.GeographiesFromCoordinates(testCoordinates, "2020", "4")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(got) != 0 {
+    t.Fatalf("got %v, exp no layers", got)
+  }
+}
+
+func TestServerFail(t *testing.T) {
+  s := NewServer()
+  defer s.Close()
+  c := s.Client()
+
+  s.Fail(Failure { Path: "/locations/onelineaddress", Status: 400, Errors: []string { "invalid benchmark" }, Times: 1 })
+  s.Fail(Failure { Path: "/geographies/coordinates", Status: 503 })
+
+  // check injected error
+  _, err := c.Locations(testAddress)
+  if !errors.Is(err, geocoder.ErrInvalidBenchmark) {
+    t.Fatalf("got %v, exp ErrInvalidBenchmark", err)
+  }
+
+  // check that failure was used up
+  if _, err := c.Locations(testAddress); err != nil {
+    t.Fatal(err)
+  }
+
+  // check status, repeated failures
+  for i := 0; i < 2; i++ {
+    var apiErr *geocoder.APIError
+    _, err := c.GeographiesFromCoordinates(testCoordinates, "", "")
+    if !errors.As(err, &apiErr) || apiErr.StatusCode != 503 {
+      t.Fatalf("got %v, exp status 503", err)
+    }
+  }
+
+  // check cleared failures
+  s.ClearFailures()
+  if _, err := c.GeographiesFromCoordinates(testCoordinates, "", ""); err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestServerRequests(t *testing.T) {
+  s := NewServer()
+  defer s.Close()
+
+  c := s.Client(geocoder.WithUserAgent("test/1.0"), geocoder.WithDefaultBenchmark("4"))
+  if _, err := c.Locations(testAddress); err != nil {
+    t.Fatal(err)
+  }
+  if _, err := c.Locations(""); err == nil {
+    t.Fatal("got success, exp empty address error")
+  }
+
+  reqs := s.Requests()
+  if len(reqs) != 2 {
+    t.Fatalf("got %d requests, exp 2", len(reqs))
+  }
+
+  r := reqs[0]
+  for _, test := range([]struct {
+    name, got, exp string // field name, value, and expected value
+  } {
+    { "Method", r.Method, "GET" },
+    { "Path", r.Path, "/locations/onelineaddress" },
+    { "address", r.Params.Get("address"), testAddress },
+    { "benchmark", r.Params.Get("benchmark"), "4" },
+    { "User-Agent", r.Header.Get("User-Agent"), "test/1.0 " + geocoder.DefaultUserAgent },
+  }) {
+    if test.got != test.exp {
+      t.Fatalf("%s: got %q, exp %q", test.name, test.got, test.exp)
+    }
+  }
+
+  s.ClearRequests()
+  if got := s.Requests(); len(got) != 0 {
+    t.Fatalf("got %v, exp no requests", got)
+  }
+}
@@ -0,0 +1,613 @@
+package geocoder
+
+import (
+  "fmt"
+  "reflect"
+  "regexp"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// Attributes common to all geography layers.
+//
+// The JSON field names match the attribute names returned by the
+// Census geocoder API.
+type Geography struct {
+  // geography ID
+  GeoId string `json:"GEOID"`
+
+  // full name (e.g. "Fairfax County")
+  Name string `json:"NAME"`
+
+  // base name (e.g. "Fairfax")
+  BaseName string `json:"BASENAME"`
+
+  // MAF/TIGER feature class code
+  Mtfcc string `json:"MTFCC"`
+
+  // functional status
+  FuncStat string `json:"FUNCSTAT"`
+
+  // legal/statistical area description code
+  Lsadc string `json:"LSADC"`
+
+  // object ID
+  ObjectId int64 `json:"OBJECTID"`
+
+  // feature ID
+  Oid int64 `json:"OID"`
+
+  // centroid latitude
+  CentLat float64 `json:"CENTLAT"`
+
+  // centroid longitude
+  CentLon float64 `json:"CENTLON"`
+
+  // internal point latitude
+  IntPtLat float64 `json:"INTPTLAT"`
+
+  // internal point longitude
+  IntPtLon float64 `json:"INTPTLON"`
+
+  // land area, in square meters
+  AreaLand int64 `json:"AREALAND"`
+
+  // water area, in square meters
+  AreaWater int64 `json:"AREAWATER"`
+
+  // population count (zero if not provided)
+  Pop100 int64 `json:"POP100"`
+
+  // housing unit count (zero if not provided)
+  Hu100 int64 `json:"HU100"`
+}
+
+// State from "States" layer.
+type StateGeography struct {
+  Geography
+
+  // state FIPS code
+  State string `json:"STATE"`
+
+  // state ANSI feature code
+  StateNs string `json:"STATENS"`
+
+  // USPS state abbreviation (e.g. "VA")
+  Stusab string `json:"STUSAB"`
+
+  // census region code
+  Region string `json:"REGION"`
+
+  // census division code
+  Division string `json:"DIVISION"`
+}
+
+// County from "Counties" layer.
+type CountyGeography struct {
+  Geography
+
+  // state FIPS code
+  State string `json:"STATE"`
+
+  // county FIPS code
+  County string `json:"COUNTY"`
+
+  // county class code
+  CountyCc string `json:"COUNTYCC"`
+
+  // county ANSI feature code
+  CountyNs string `json:"COUNTYNS"`
+}
+
+// County subdivision from "County Subdivisions" layer.
+type CountySubdivisionGeography struct {
+  Geography
+
+  // state FIPS code
+  State string `json:"STATE"`
+
+  // county FIPS code
+  County string `json:"COUNTY"`
+
+  // county subdivision FIPS code
+  CountySubdivision string `json:"COUSUB"`
+
+  // county subdivision class code
+  CountySubdivisionCc string `json:"COUSUBCC"`
+
+  // county subdivision ANSI feature code
+  CountySubdivisionNs string `json:"COUSUBNS"`
+}
+
+// Census tract from "Census Tracts" layer.
+type TractGeography struct {
+  Geography
+
+  // state FIPS code
+  State string `json:"STATE"`
+
+  // county FIPS code
+  County string `json:"COUNTY"`
+
+  // tract code
+  Tract string `json:"TRACT"`
+}
+
+// Census block group from "Census Block Groups" layer.
+type BlockGroupGeography struct {
+  Geography
+
+  // state FIPS code
+  State string `json:"STATE"`
+
+  // county FIPS code
+  County string `json:"COUNTY"`
+
+  // tract code
+  Tract string `json:"TRACT"`
+
+  // block group code
+  BlockGroup string `json:"BLKGRP"`
+}
+
+// Census block from "Census Blocks" or "2020 Census Blocks" layer.
+type BlockGeography struct {
+  Geography
+
+  // state FIPS code
+  State string `json:"STATE"`
+
+  // county FIPS code
+  County string `json:"COUNTY"`
+
+  // tract code
+  Tract string `json:"TRACT"`
+
+  // block group code
+  BlockGroup string `json:"BLKGRP"`
+
+  // block code
+  Block string `json:"BLOCK"`
+
+  // block suffix
+  Suffix string `json:"SUFFIX"`
+}
+
+// Place from "Incorporated Places" or "Census Designated Places"
+// layer.
+type PlaceGeography struct {
+  Geography
+
+  // state FIPS code
+  State string `json:"STATE"`
+
+  // place FIPS code
+  Place string `json:"PLACE"`
+
+  // place class code
+  PlaceCc string `json:"PLACECC"`
+
+  // place ANSI feature code
+  PlaceNs string `json:"PLACENS"`
+}
+
+// Congressional district from congressional districts layer (e.g.
+// "116th Congressional Districts").
+type CongressionalDistrictGeography struct {
+  Geography
+
+  // state FIPS code
+  State string `json:"STATE"`
+
+  // congressional session (e.g. "116")
+  Session string `json:"CDSESSN"`
+
+  // district code (e.g. "08"), from the session-specific attribute
+  // (e.g. "CD116").
+  District string `json:"-"`
+}
+
+// State legislative district from "State Legislative Districts - Upper"
+// or "State Legislative Districts - Lower" layer.
+type StateLegislativeDistrictGeography struct {
+  Geography
+
+  // state FIPS code
+  State string `json:"STATE"`
+
+  // legislative session year
+  SessionYear string `json:"LSY"`
+
+  // legislative district type
+  DistrictType string `json:"LDTYP"`
+
+  // chamber ("Upper" or "Lower")
+  Chamber string `json:"-"`
+
+  // district code, from the "SLDU" or "SLDL" attribute.
+  District string `json:"-"`
+}
+
+// Core based statistical area from "Metropolitan Statistical Areas" or
+// "Micropolitan Statistical Areas" layer.
+type CbsaGeography struct {
+  Geography
+
+  // CBSA code
+  Cbsa string `json:"CBSA"`
+}
+
+// Combined statistical area from "Combined Statistical Areas" layer.
+type CsaGeography struct {
+  Geography
+
+  // CSA code
+  Csa string `json:"CSA"`
+}
+
+// ZIP code tabulation area from ZCTA layer (e.g. "2020 Census ZIP Code
+// Tabulation Areas").
+type ZctaGeography struct {
+  Geography
+
+  // 5-digit ZCTA code
+  Zcta5 string `json:"ZCTA5"`
+}
+
+// School district from "Unified School Districts", "Secondary School
+// Districts", or "Elementary School Districts" layer.
+type SchoolDistrictGeography struct {
+  Geography
+
+  // state FIPS code
+  State string `json:"STATE"`
+
+  // district type ("Unified", "Secondary", or "Elementary")
+  DistrictType string `json:"-"`
+
+  // district code, from the "SDUNI", "SDSEC", or "SDELM" attribute.
+  District string `json:"-"`
+}
+
+// Match layer name exactly.
+func layerIs(name string) func(string) bool {
+  return func(s string) bool {
+    return s == name
+  }
+}
+
+// Match layer name suffix.
+//
+// Used for layers with names that vary by vintage (e.g. "Census
+// Blocks" and "2020 Census Blocks").
+func layerHasSuffix(suffix string) func(string) bool {
+  return func(s string) bool {
+    return strings.HasSuffix(s, suffix)
+  }
+}
+
+// Match layer name, ignoring case.
+func layerContainsFold(substr string) func(string) bool {
+  substr = strings.ToLower(substr)
+  return func(s string) bool {
+    return strings.Contains(strings.ToLower(s), substr)
+  }
+}
+
+// Decode geography layers with names matching the given predicate as
+// the given struct type.
+//
+// Matching layers are decoded in order of layer name.  If the fill
+// function is non-nil, then it is called for each decoded row to
+// populate fields which can not be decoded from struct tags.
+func decodeLayers[T any](layers GeographyLayers, match func(string) bool, fill func(*T, string, map[string]any)) ([]T, error) {
+  // get sorted list of matching layer names
+  names := make([]string, 0, len(layers))
+  for name := range(layers) {
+    if match(name) {
+      names = append(names, name)
+    }
+  }
+  sort.Strings(names)
+
+  r := []T{}
+  for _, name := range(names) {
+    for _, row := range(layers[name]) {
+      var v T
+      if err := decodeGeography(reflect.ValueOf(&v).Elem(), row); err != nil {
+        return []T{}, fmt.Errorf("%s: %w", name, err)
+      }
+
+      // populate remaining fields
+      if fill != nil {
+        fill(&v, name, row)
+      }
+
+      r = append(r, v)
+    }
+  }
+
+  // return result
+  return r, nil
+}
+
+// Decode geography attributes into fields of struct value using the
+// field JSON tags as attribute names.
+func decodeGeography(v reflect.Value, row map[string]any) error {
+  t := v.Type()
+
+  for i := 0; i < t.NumField(); i++ {
+    f := t.Field(i)
+
+    // recurse into embedded structs
+    if f.Anonymous {
+      if err := decodeGeography(v.Field(i), row); err != nil {
+        return err
+      }
+      continue
+    }
+
+    // get attribute name, skip untagged fields
+    key := f.Tag.Get("json")
+    if key == "" || key == "-" {
+      continue
+    }
+
+    // get attribute value, skip missing attributes
+    val, ok := row[key]
+    if !ok || val == nil {
+      continue
+    }
+
+    switch f.Type.Kind() {
+    case reflect.String:
+      v.Field(i).SetString(geographyString(val))
+    case reflect.Float64:
+      x, err := geographyFloat(val)
+      if err != nil {
+        return fmt.Errorf("invalid %s value: %w", key, err)
+      }
+      v.Field(i).SetFloat(x)
+    case reflect.Int64:
+      x, err := geographyInt(val)
+      if err != nil {
+        return fmt.Errorf("invalid %s value: %w", key, err)
+      }
+      v.Field(i).SetInt(x)
+    }
+  }
+
+  // return success
+  return nil
+}
+
+// Convert attribute value to string.
+func geographyString(val any) string {
+  switch x := val.(type) {
+  case string:
+    return x
+  case float64:
+    return strconv.FormatFloat(x, 'f', -1, 64)
+  default:
+    return fmt.Sprint(x)
+  }
+}
+
+// Convert attribute value (e.g. "+38.8362814") to float.
+//
+// Empty strings are treated as zero.
+func geographyFloat(val any) (float64, error) {
+  switch x := val.(type) {
+  case float64:
+    return x, nil
+  case string:
+    if x == "" {
+      return 0, nil
+    }
+    return strconv.ParseFloat(x, 64)
+  default:
+    return 0, fmt.Errorf("unknown type: %T", val)
+  }
+}
+
+// Convert attribute value (e.g. 83650068) to integer.
+//
+// Empty strings are treated as zero.
+func geographyInt(val any) (int64, error) {
+  switch x := val.(type) {
+  case float64:
+    if x != float64(int64(x)) {
+      return 0, fmt.Errorf("not an integer: %v", x)
+    }
+    return int64(x), nil
+  case string:
+    if x == "" {
+      return 0, nil
+    }
+    return strconv.ParseInt(strings.TrimPrefix(x, "+"), 10, 64)
+  default:
+    return 0, fmt.Errorf("unknown type: %T", val)
+  }
+}
+
+// Get States layer.
+func (g GeographyLayers) States() ([]StateGeography, error) {
+  return decodeLayers[StateGeography](g, layerIs("States"), nil)
+}
+
+// Get Counties layer.
+func (g GeographyLayers) Counties() ([]CountyGeography, error) {
+  return decodeLayers[CountyGeography](g, layerIs("Counties"), nil)
+}
+
+// Get County Subdivisions layer.
+func (g GeographyLayers) CountySubdivisions() ([]CountySubdivisionGeography, error) {
+  return decodeLayers[CountySubdivisionGeography](g, layerIs("County Subdivisions"), nil)
+}
+
+// Get Census Tracts layer.
+func (g GeographyLayers) Tracts() ([]TractGeography, error) {
+  return decodeLayers[TractGeography](g, layerHasSuffix("Census Tracts"), nil)
+}
+
+// Get Census Block Groups layer.
+func (g GeographyLayers) BlockGroups() ([]BlockGroupGeography, error) {
+  return decodeLayers[BlockGroupGeography](g, layerHasSuffix("Census Block Groups"), nil)
+}
+
+// Get Census Blocks layer (e.g. "Census Blocks" or "2020 Census
+// Blocks").
+func (g GeographyLayers) Blocks() ([]BlockGeography, error) {
+  return decodeLayers[BlockGeography](g, layerHasSuffix("Census Blocks"), nil)
+}
+
+// Get Incorporated Places and Census Designated Places layers.
+func (g GeographyLayers) Places() ([]PlaceGeography, error) {
+  return decodeLayers[PlaceGeography](g, layerHasSuffix("Places"), nil)
+}
+
+// congressional district attribute name (e.g. "CD116")
+var congressionalDistrictKeyRe = regexp.MustCompile(`^CD\d+$`)
+
+// Get congressional districts layer (e.g. "116th Congressional
+// Districts").
+func (g GeographyLayers) CongressionalDistricts() ([]CongressionalDistrictGeography, error) {
+  return decodeLayers(g, layerHasSuffix("Congressional Districts"), func(v *CongressionalDistrictGeography, _ string, row map[string]any) {
+    // populate district from session-specific attribute
+    for k, val := range(row) {
+      if congressionalDistrictKeyRe.MatchString(k) {
+        v.District = geographyString(val)
+      }
+    }
+  })
+}
+
+// state legislative district layer prefix
+const stateLegislativeDistrictsPrefix = "State Legislative Districts - "
+
+// Get State Legislative Districts - Upper and State Legislative
+// Districts - Lower layers.
+func (g GeographyLayers) StateLegislativeDistricts() ([]StateLegislativeDistrictGeography, error) {
+  return decodeLayers(g, func(s string) bool {
+    return s == stateLegislativeDistrictsPrefix + "Upper" ||
+           s == stateLegislativeDistrictsPrefix + "Lower"
+  }, func(v *StateLegislativeDistrictGeography, name string, row map[string]any) {
+    // populate chamber and district
+    v.Chamber = strings.TrimPrefix(name, stateLegislativeDistrictsPrefix)
+    if v.Chamber == "Upper" {
+      v.District = geographyString(row["SLDU"])
+    } else {
+      v.District = geographyString(row["SLDL"])
+    }
+  })
+}
+
+// Get Metropolitan Statistical Areas and Micropolitan Statistical Areas
+// layers.
+func (g GeographyLayers) Cbsas() ([]CbsaGeography, error) {
+  return decodeLayers[CbsaGeography](g, func(s string) bool {
+    return s == "Metropolitan Statistical Areas" || s == "Micropolitan Statistical Areas"
+  }, nil)
+}
+
+// Get Combined Statistical Areas layer.
+func (g GeographyLayers) Csas() ([]CsaGeography, error) {
+  return decodeLayers[CsaGeography](g, layerIs("Combined Statistical Areas"), nil)
+}
+
+// Get ZIP code tabulation areas layer (e.g. "2020 Census ZIP Code
+// Tabulation Areas").
+func (g GeographyLayers) Zctas() ([]ZctaGeography, error) {
+  return decodeLayers[ZctaGeography](g, layerContainsFold("ZIP Code Tabulation Areas"), nil)
+}
+
+// map of school district layer name to district type and district
+// attribute name
+var schoolDistrictLayers = map[string]struct {
+  districtType string // district type
+  key string // district attribute
+} {
+  "Unified School Districts": { "Unified", "SDUNI" },
+  "Secondary School Districts": { "Secondary", "SDSEC" },
+  "Elementary School Districts": { "Elementary", "SDELM" },
+}
+
+// Get Unified School Districts, Secondary School Districts, and
+// Elementary School Districts layers.
+func (g GeographyLayers) SchoolDistricts() ([]SchoolDistrictGeography, error) {
+  return decodeLayers(g, func(s string) bool {
+    _, ok := schoolDistrictLayers[s]
+    return ok
+  }, func(v *SchoolDistrictGeography, name string, row map[string]any) {
+    // populate district type and district
+    layer := schoolDistrictLayers[name]
+    v.DistrictType = layer.districtType
+    v.District = geographyString(row[layer.key])
+  })
+}
+
+// Get States from match geography layers.
+func (m Match) States() ([]StateGeography, error) {
+  return m.Geographies.States()
+}
+
+// Get Counties from match geography layers.
+func (m Match) Counties() ([]CountyGeography, error) {
+  return m.Geographies.Counties()
+}
+
+// Get County Subdivisions from match geography layers.
+func (m Match) CountySubdivisions() ([]CountySubdivisionGeography, error) {
+  return m.Geographies.CountySubdivisions()
+}
+
+// Get Census Tracts from match geography layers.
+func (m Match) Tracts() ([]TractGeography, error) {
+  return m.Geographies.Tracts()
+}
+
+// Get Census Block Groups from match geography layers.
+func (m Match) BlockGroups() ([]BlockGroupGeography, error) {
+  return m.Geographies.BlockGroups()
+}
+
+// Get Census Blocks from match geography layers.
+func (m Match) Blocks() ([]BlockGeography, error) {
+  return m.Geographies.Blocks()
+}
+
+// Get places from match geography layers.
+func (m Match) Places() ([]PlaceGeography, error) {
+  return m.Geographies.Places()
+}
+
+// Get congressional districts from match geography layers.
+func (m Match) CongressionalDistricts() ([]CongressionalDistrictGeography, error) {
+  return m.Geographies.CongressionalDistricts()
+}
+
+// Get state legislative districts from match geography layers.
+func (m Match) StateLegislativeDistricts() ([]StateLegislativeDistrictGeography, error) {
+  return m.Geographies.StateLegislativeDistricts()
+}
+
+// Get core based statistical areas from match geography layers.
+func (m Match) Cbsas() ([]CbsaGeography, error) {
+  return m.Geographies.Cbsas()
+}
+
+// Get combined statistical areas from match geography layers.
+func (m Match) Csas() ([]CsaGeography, error) {
+  return m.Geographies.Csas()
+}
+
+// Get ZIP code tabulation areas from match geography layers.
+func (m Match) Zctas() ([]ZctaGeography, error) {
+  return m.Geographies.Zctas()
+}
+
+// Get school districts from match geography layers.
+func (m Match) SchoolDistricts() ([]SchoolDistrictGeography, error) {
+  return m.Geographies.SchoolDistricts()
+}
@@ -0,0 +1,216 @@
+package geocoder
+
+import (
+  "encoding/json"
+  "reflect"
+  "testing"
+)
+
+// Decode geography layers from mock coordinates response.
+func getTestGeographyLayers(t *testing.T) GeographyLayers {
+  var r GeographyLayers
+  if err := json.Unmarshal(mockCoordinatesJson, &r); err != nil {
+    t.Fatal(err)
+  }
+
+  // return result
+  return r
+}
+
+func TestGeographyLayersStates(t *testing.T) {
+  exp := []StateGeography {{
+    Geography: Geography {
+      GeoId: "51",
+      Name: "Virginia",
+      BaseName: "Virginia",
+      Mtfcc: "G4000",
+      FuncStat: "A",
+      Lsadc: "00",
+      ObjectId: 19,
+      Oid: 2749099610787,
+      CentLat: 37.5182631,
+      CentLon: -78.6759174,
+      IntPtLat: 37.5222512,
+      IntPtLon: -78.6681938,
+      AreaLand: 102258180558,
+      AreaWater: 8528070310,
+    },
+    State: "51",
+    StateNs: "01779803",
+    Stusab: "VA",
+    Region: "3",
+    Division: "5",
+  }}
+
+  got, err := getTestGeographyLayers(t).States()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %#v, exp %#v", got, exp)
+  }
+}
+
+func TestGeographyLayers(t *testing.T) {
+  g := getTestGeographyLayers(t)
+
+  tests := []struct {
+    name string // test name
+    fn func() (any, error) // accessor
+    exp []string // expected GEOIDs
+  } {{
+    name: "Counties",
+    fn: func() (any, error) { return g.Counties() },
+    exp: []string { "51059" },
+  }, {
+    name: "CountySubdivisions",
+    fn: func() (any, error) { return g.CountySubdivisions() },
+    exp: []string { "5105995191" },
+  }, {
+    name: "Tracts",
+    fn: func() (any, error) { return g.Tracts() },
+    exp: []string { "51059471401" },
+  }, {
+    name: "BlockGroups",
+    fn: func() (any, error) { return g.BlockGroups() },
+    exp: []string {},
+  }, {
+    name: "Blocks",
+    fn: func() (any, error) { return g.Blocks() },
+    exp: []string { "510594714011007" },
+  }, {
+    name: "Places",
+    fn: func() (any, error) { return g.Places() },
+    exp: []string { "5139448" },
+  }, {
+    name: "CongressionalDistricts",
+    fn: func() (any, error) { return g.CongressionalDistricts() },
+    exp: []string { "5108" },
+  }, {
+    name: "StateLegislativeDistricts",
+    fn: func() (any, error) { return g.StateLegislativeDistricts() },
+    exp: []string { "51053", "51035" },
+  }, {
+    name: "Cbsas",
+    fn: func() (any, error) { return g.Cbsas() },
+    exp: []string {},
+  }, {
+    name: "Csas",
+    fn: func() (any, error) { return g.Csas() },
+    exp: []string { "548" },
+  }, {
+    name: "Zctas",
+    fn: func() (any, error) { return g.Zctas() },
+    exp: []string {},
+  }, {
+    name: "SchoolDistricts",
+    fn: func() (any, error) { return g.SchoolDistricts() },
+    exp: []string {},
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      rows, err := test.fn()
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      // get GEOIDs from embedded Geography field of each row
+      got := []string{}
+      v := reflect.ValueOf(rows)
+      for i := 0; i < v.Len(); i++ {
+        got = append(got, v.Index(i).FieldByName("GeoId").String())
+      }
+
+      if !reflect.DeepEqual(got, test.exp) {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestGeographyLayersDistricts(t *testing.T) {
+  g := getTestGeographyLayers(t)
+
+  t.Run("CongressionalDistricts", func(t *testing.T) {
+    got, err := g.CongressionalDistricts()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if len(got) != 1 || got[0].District != "08" || got[0].Session != "116" {
+      t.Fatalf("got %#v", got)
+    }
+  })
+
+  t.Run("StateLegislativeDistricts", func(t *testing.T) {
+    got, err := g.StateLegislativeDistricts()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    exp := [][2]string { { "Lower", "053" }, { "Upper", "035" } }
+    if len(got) != len(exp) {
+      t.Fatalf("got %#v", got)
+    }
+
+    for i, row := range(got) {
+      if row.Chamber != exp[i][0] || row.District != exp[i][1] {
+        t.Fatalf("%d: got %s %s, exp %s %s", i, row.Chamber, row.District, exp[i][0], exp[i][1])
+      }
+    }
+  })
+
+  t.Run("SchoolDistricts", func(t *testing.T) {
+    got, err := GeographyLayers {
+      "Unified School Districts": {
+        { "GEOID": "5101260", "STATE": "51", "SDUNI": "01260", "AREALAND": 1012739503.0 },
+      },
+    }.SchoolDistricts()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if len(got) != 1 || got[0].DistrictType != "Unified" || got[0].District != "01260" || got[0].AreaLand != 1012739503 {
+      t.Fatalf("got %#v", got)
+    }
+  })
+}
+
+func TestGeographyLayersInvalid(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    row map[string]any // layer row
+  } {
+    { "bad float", map[string]any { "CENTLAT": "north" } },
+    { "bad int", map[string]any { "AREALAND": 1.5 } },
+    { "bad type", map[string]any { "AREALAND": true } },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      g := GeographyLayers { "Counties": { test.row } }
+      if got, err := g.Counties(); err == nil {
+        t.Fatalf("got %#v, exp error", got)
+      }
+    })
+  }
+}
+
+func TestMatchTracts(t *testing.T) {
+  // decode matches
+  var matches []Match
+  if err := json.Unmarshal(mockGeographiesJson, &matches); err != nil {
+    t.Fatal(err)
+  }
+
+  got, err := matches[0].Tracts()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if len(got) != 1 || got[0].State != "24" || got[0].County == "" || got[0].Tract == "" {
+    t.Fatalf("got %#v", got)
+  }
+}
@@ -0,0 +1,166 @@
+package geocoder
+
+import (
+  "encoding/json"
+  "io"
+)
+
+// GeoJSON point geometry.
+type GeoJSONPoint struct {
+  // geometry type (always "Point")
+  Type string `json:"type"`
+
+  // position as longitude (X) and latitude (Y)
+  Coordinates [2]float64 `json:"coordinates"`
+}
+
+// Create GeoJSON point from coordinates.
+func NewGeoJSONPoint(c Coordinates) *GeoJSONPoint {
+  return &GeoJSONPoint { Type: "Point", Coordinates: [2]float64 { c.X, c.Y } }
+}
+
+// GeoJSON feature.
+type GeoJSONFeature struct {
+  // feature type (always "Feature")
+  Type string `json:"type"`
+
+  // feature ID (batch row ID, omitted for matches)
+  Id string `json:"id,omitempty"`
+
+  // point geometry, or nil for unmatched batch rows
+  Geometry *GeoJSONPoint `json:"geometry"`
+
+  // feature properties
+  Properties map[string]any `json:"properties"`
+}
+
+// GeoJSON feature collection.
+type GeoJSONFeatureCollection struct {
+  // collection type (always "FeatureCollection")
+  Type string `json:"type"`
+
+  // features
+  Features []GeoJSONFeature `json:"features"`
+}
+
+// Get map of layer name to GEOIDs for geography layers.
+func geoIds(layers GeographyLayers) map[string][]string {
+  r := make(map[string][]string, len(layers))
+  for name, rows := range(layers) {
+    for _, row := range(rows) {
+      if id, ok := row["GEOID"].(string); ok && id != "" {
+        r[name] = append(r[name], id)
+      }
+    }
+  }
+
+  return r
+}
+
+// Convert match to GeoJSON point feature.
+//
+// The feature properties contain the matched address, the TIGER line
+// ID and side, and the GEOIDs of each geography layer keyed by layer
+// name.
+func (m Match) GeoJSONFeature() GeoJSONFeature {
+  return GeoJSONFeature {
+    Type: "Feature",
+    Geometry: NewGeoJSONPoint(m.Coordinates),
+    Properties: map[string]any {
+      "matched_address": m.MatchedAddress,
+      "tiger_line_id": m.TigerLine.Id,
+      "tiger_line_side": m.TigerLine.Side,
+      "geoids": geoIds(m.Geographies),
+    },
+  }
+}
+
+// Convert batch output row to GeoJSON feature.
+//
+// Matched rows become point features and unmatched rows become
+// features with null geometry, so every input row is kept.  The
+// feature properties contain the input address, match flags, matched
+// address, TIGER line ID and side, and the GEOIDs of the state, county,
+// tract, and block (only populated if `returntype = geographies`).
+func (r BatchOutputRow) GeoJSONFeature() GeoJSONFeature {
+  // get geometry
+  var geom *GeoJSONPoint
+  if r.Match {
+    geom = NewGeoJSONPoint(r.Coordinates)
+  }
+
+  return GeoJSONFeature {
+    Type: "Feature",
+    Id: r.Id,
+    Geometry: geom,
+    Properties: map[string]any {
+      "input_address": r.InputAddress,
+      "is_match": r.Match,
+      "is_exact": r.Exact,
+      "match_address": r.MatchAddress,
+      "tiger_line_id": r.TigerLine.Id,
+      "tiger_line_side": r.TigerLine.Side,
+      "geoids": r.geoIds(),
+    },
+  }
+}
+
+// Build feature collection from items.
+//
+// If column names are given, then the feature properties contain the
+// named columns instead of the default properties.
+func newFeatureCollection[T any](cols []outputColumn[T], names []string, items []T, feature func(T) GeoJSONFeature) (GeoJSONFeatureCollection, error) {
+  // find columns
+  cols, err := findColumns(cols, names)
+  if err != nil {
+    return GeoJSONFeatureCollection{}, err
+  }
+
+  // build features
+  features := make([]GeoJSONFeature, len(items))
+  for i, item := range(items) {
+    features[i] = feature(item)
+
+    // replace properties with selected columns
+    if len(cols) > 0 {
+      features[i].Properties = make(map[string]any, len(cols))
+      for _, col := range(cols) {
+        features[i].Properties[col.name] = col.get(item)
+      }
+    }
+  }
+
+  return GeoJSONFeatureCollection {
+    Type: "FeatureCollection",
+    Features: features,
+  }, nil
+}
+
+// Convert matches to GeoJSON feature collection of point features.
+//
+// See [Match.GeoJSONFeature] for feature properties.
+func MatchesGeoJSON(matches []Match) GeoJSONFeatureCollection {
+  r, _ := newFeatureCollection(matchColumns, nil, matches, Match.GeoJSONFeature)
+  return r
+}
+
+// Convert batch output rows to GeoJSON feature collection.
+//
+// See [BatchOutputRow.GeoJSONFeature] for feature geometry and
+// properties.
+func BatchOutputRowsGeoJSON(rows []BatchOutputRow) GeoJSONFeatureCollection {
+  r, _ := newFeatureCollection(batchColumns, nil, rows, BatchOutputRow.GeoJSONFeature)
+  return r
+}
+
+// Write GeoJSON feature collection of items to writer.
+func writeGeoJSON[T any](w io.Writer, cols []outputColumn[T], names []string, items []T, feature func(T) GeoJSONFeature) error {
+  fc, err := newFeatureCollection(cols, names, items, feature)
+  if err != nil {
+    return err
+  }
+
+  e := json.NewEncoder(w)
+  e.SetIndent("", "  ")
+  return e.Encode(fc)
+}
@@ -0,0 +1,101 @@
+package geocoder
+
+import (
+  "bytes"
+  "encoding/json"
+  "reflect"
+  "testing"
+)
+
+func TestBatchOutputRowGeoJSONFeature(t *testing.T) {
+  // matched row
+  got := testOutputRows[0].GeoJSONFeature()
+  if got.Id != "1" || got.Geometry == nil || got.Geometry.Coordinates != [2]float64 { -76.92744, 38.845985 } {
+    t.Fatalf("got %v, exp point feature", got)
+  }
+
+  // check GEOIDs
+  expIds := map[string][]string {
+    "States": []string { "11" },
+    "Counties": []string { "11001" },
+    "Census Tracts": []string { "11001980000" },
+    "Census Blocks": []string { "110019800001034" },
+  }
+  if ids := got.Properties["geoids"]; !reflect.DeepEqual(ids, expIds) {
+    t.Fatalf("got %v, exp %v", ids, expIds)
+  }
+
+  // unmatched row
+  got = testOutputRows[1].GeoJSONFeature()
+  if got.Id != "2" || got.Geometry != nil || got.Properties["is_match"] != false {
+    t.Fatalf("got %v, exp feature with null geometry", got)
+  }
+}
+
+func TestMatchGeoJSONFeature(t *testing.T) {
+  // decode matches
+  var matches []Match
+  if err := json.Unmarshal(mockGeographiesJson, &matches); err != nil {
+    t.Fatal(err)
+  }
+
+  fc := MatchesGeoJSON(matches)
+  if fc.Type != "FeatureCollection" || len(fc.Features) != 1 {
+    t.Fatalf("got %v, exp feature collection with 1 feature", fc)
+  }
+
+  // check geometry and properties
+  got := fc.Features[0]
+  exp := [2]float64 { matches[0].Coordinates.X, matches[0].Coordinates.Y }
+  if got.Geometry == nil || got.Geometry.Type != "Point" || got.Geometry.Coordinates != exp {
+    t.Fatalf("got %v, exp point %v", got.Geometry, exp)
+  }
+  if got.Properties["matched_address"] != matches[0].MatchedAddress {
+    t.Fatalf("got %v, exp %s", got.Properties["matched_address"], matches[0].MatchedAddress)
+  }
+
+  ids := got.Properties["geoids"].(map[string][]string)
+  if tracts := ids["Census Tracts"]; len(tracts) != 1 || tracts[0] != "24033802405" {
+    t.Fatalf("got %v, exp tract GEOID", tracts)
+  }
+}
+
+func TestWriteBatchOutputRowsGeoJSON(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    columns []string // output columns
+    exp string // expected output
+  } {{
+    name: "columns",
+    columns: []string { "id", "tract" },
+    exp: `{"type":"FeatureCollection","features":[` +
+      `{"type":"Feature","id":"1","geometry":{"type":"Point","coordinates":[-76.92744,38.845985]},"properties":{"id":"1","tract":"980000"}},` +
+      `{"type":"Feature","id":"2","geometry":null,"properties":{"id":"2","tract":""}}]}`,
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      var buf bytes.Buffer
+      if err := WriteBatchOutputRows(&buf, FormatGeoJSON, test.columns, testOutputRows); err != nil {
+        t.Fatal(err)
+      }
+
+      // compact output
+      var got bytes.Buffer
+      if err := json.Compact(&got, buf.Bytes()); err != nil {
+        t.Fatal(err)
+      }
+
+      if got.String() != test.exp {
+        t.Fatalf("got %s, exp %s", got.String(), test.exp)
+      }
+    })
+  }
+
+  t.Run("unknown column", func(t *testing.T) {
+    var buf bytes.Buffer
+    if err := WriteBatchOutputRows(&buf, FormatGeoJSON, []string { "bogus" }, testOutputRows); err == nil {
+      t.Fatal("got success, exp error")
+    }
+  })
+}
@@ -0,0 +1,134 @@
+package geocoder
+
+import (
+  "encoding/xml"
+  "io"
+  "strings"
+)
+
+// GPX namespace.
+const gpxNamespace = "http://www.topografix.com/GPX/1/1"
+
+// GPX waypoint.
+type gpxWaypoint struct {
+  XMLName xml.Name `xml:"wpt"`
+  Lat string `xml:"lat,attr"`
+  Lon string `xml:"lon,attr"`
+  Name string `xml:"name"`
+  Desc string `xml:"desc,omitempty"`
+}
+
+// Streaming GPX writer.
+//
+// Writes matches and matched batch output rows as waypoints in a GPX
+// document.  Each waypoint is named after the matched address and has
+// the geography GEOIDs in the description (e.g. "Census Tracts:
+// 51059471401; States: 51").  GPX waypoints require coordinates, so
+// unmatched batch rows are skipped.
+//
+// Each waypoint is written as soon as it is encoded, so large batch
+// outputs do not need to be held in memory.  [GPXWriter.Close] must be
+// called to finish the document.
+type GPXWriter struct {
+  w io.Writer
+  e *xml.Encoder
+  started bool
+  closed bool
+}
+
+// Create GPX writer.
+func NewGPXWriter(w io.Writer) *GPXWriter {
+  e := xml.NewEncoder(w)
+  e.Indent("", "  ")
+  return &GPXWriter { w: w, e: e }
+}
+
+// Write document header, if it has not been written yet.
+func (me *GPXWriter) start() error {
+  if me.closed {
+    return ErrWriterClosed
+  }
+
+  if me.started {
+    return nil
+  }
+  me.started = true
+
+  // write XML declaration
+  if _, err := io.WriteString(me.w, xml.Header); err != nil {
+    return err
+  }
+
+  // write gpx element
+  for _, token := range([]xml.Token {
+    xml.StartElement {
+      Name: xml.Name { Local: "gpx" },
+      Attr: []xml.Attr {
+        { Name: xml.Name { Local: "version" }, Value: "1.1" },
+        { Name: xml.Name { Local: "creator" }, Value: "census-geocoder" },
+        { Name: xml.Name { Local: "xmlns" }, Value: gpxNamespace },
+      },
+    },
+  }) {
+    if err := me.e.EncodeToken(token); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+// Write place as waypoint.  Places without coordinates are skipped.
+func (me *GPXWriter) writePlace(p place) error {
+  if err := me.start(); err != nil {
+    return err
+  }
+
+  if p.coords == nil {
+    return nil
+  }
+
+  // build description
+  desc := make([]string, len(p.data))
+  for i, d := range(p.data) {
+    desc[i] = d.Name + ": " + d.Value
+  }
+
+  return me.e.Encode(gpxWaypoint {
+    Lat: formatCoordinate(p.coords.Y),
+    Lon: formatCoordinate(p.coords.X),
+    Name: p.name,
+    Desc: strings.Join(desc, "; "),
+  })
+}
+
+// Write match as waypoint.
+func (me *GPXWriter) WriteMatch(m Match) error {
+  return me.writePlace(matchPlace(m))
+}
+
+// Write batch output row as waypoint.  Unmatched rows are skipped.
+func (me *GPXWriter) WriteBatchOutputRow(r BatchOutputRow) error {
+  return me.writePlace(batchPlace(r))
+}
+
+// Finish document and flush writes.  Does not close the underlying
+// writer.
+func (me *GPXWriter) Close() error {
+  if err := me.start(); err != nil {
+    return err
+  }
+  me.closed = true
+
+  // close gpx element
+  if err := me.e.EncodeToken(xml.EndElement { Name: xml.Name { Local: "gpx" } }); err != nil {
+    return err
+  }
+
+  // flush writes, then write trailing newline
+  if err := me.e.Flush(); err != nil {
+    return err
+  }
+  _, err := io.WriteString(me.w, "\n")
+  return err
+}
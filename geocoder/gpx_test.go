@@ -0,0 +1,42 @@
+package geocoder
+
+import (
+  "bytes"
+  "testing"
+)
+
+func TestGPXWriterMatches(t *testing.T) {
+  var buf bytes.Buffer
+  if err := WriteMatches(&buf, FormatGPX, nil, getMatches(t)); err != nil {
+    t.Fatal(err)
+  }
+
+  checkGolden(t, "geographies.gpx", buf.Bytes())
+}
+
+func TestGPXWriterBatchOutputRows(t *testing.T) {
+  var buf bytes.Buffer
+  w := NewGPXWriter(&buf)
+  for _, row := range(getBatchOutputRows(t, "testdata/data/batch-output-geographies-2020-2020.csv")) {
+    if err := w.WriteBatchOutputRow(row); err != nil {
+      t.Fatal(err)
+    }
+  }
+  if err := w.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  checkGolden(t, "batch-output-geographies-2020-2020.gpx", buf.Bytes())
+}
+
+func TestGPXWriterClosed(t *testing.T) {
+  var buf bytes.Buffer
+  w := NewGPXWriter(&buf)
+  if err := w.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  if err := w.WriteBatchOutputRow(BatchOutputRow{}); err != ErrWriterClosed {
+    t.Fatalf("got %v, exp ErrWriterClosed", err)
+  }
+}
@@ -0,0 +1,285 @@
+package geocoder
+
+import (
+  "context"
+  "fmt"
+)
+
+// Geocoder which supports single, structured, reverse, and batch
+// lookups.
+//
+// Implemented by [Client].  Use [Wrap] to add middleware (e.g.
+// caching, retries, logging, or metrics) around any geocoder.
+type Geocoder interface {
+  // Geocode street address with given benchmark.
+  LocationsFromBenchmarkContext(ctx context.Context, address, benchmark string) ([]Match, error)
+
+  // Geocode street address with given benchmark and vintage, then
+  // return matches with geography layers.  If layers are given, then
+  // only the given geography layers are returned.
+  GeographiesContext(ctx context.Context, address, benchmark, vintage string, layers ...string) ([]Match, error)
+
+  // Geocode structured address with given benchmark.
+  AddressLocationsFromBenchmarkContext(ctx context.Context, address Address, benchmark string) ([]Match, error)
+
+  // Geocode structured address with given benchmark and vintage, then
+  // return matches with geography layers.  If layers are given, then
+  // only the given geography layers are returned.
+  AddressGeographiesContext(ctx context.Context, address Address, benchmark, vintage string, layers ...string) ([]Match, error)
+
+  // Get geography layers for coordinates with given benchmark and
+  // vintage.  If layers are given, then only the given geography layers
+  // are returned.
+  GeographiesFromCoordinatesContext(ctx context.Context, coords Coordinates, benchmark, vintage string, layers ...string) (GeographyLayers, error)
+
+  // Batch geocode street addresses with given benchmark.
+  BatchLocationsFromBenchmarkContext(ctx context.Context, rows []BatchInputRow, benchmark string) ([]BatchOutputRow, error)
+
+  // Batch geocode street addresses with given benchmark and vintage,
+  // then return matches with geography fields.
+  BatchGeographiesContext(ctx context.Context, rows []BatchInputRow, benchmark, vintage string) ([]BatchOutputRow, error)
+}
+
+// make sure Client implements Geocoder
+var _ Geocoder = Client{}
+
+// Kind of geocoder request.
+type RequestKind int
+
+const (
+  // street address locations
+  RequestLocations RequestKind = iota
+
+  // street address geographies
+  RequestGeographies
+
+  // structured address locations
+  RequestAddressLocations
+
+  // structured address geographies
+  RequestAddressGeographies
+
+  // coordinates geographies (reverse lookup)
+  RequestCoordinates
+
+  // batch locations
+  RequestBatchLocations
+
+  // batch geographies
+  RequestBatchGeographies
+)
+
+// request kind names
+var requestKindNames = []string {
+  "locations",
+  "geographies",
+  "address_locations",
+  "address_geographies",
+  "coordinates",
+  "batch_locations",
+  "batch_geographies",
+}
+
+// Get request kind name.
+func (k RequestKind) String() string {
+  if k >= 0 && int(k) < len(requestKindNames) {
+    return requestKindNames[k]
+  }
+
+  return fmt.Sprintf("RequestKind(%d)", int(k))
+}
+
+// Is this a batch request kind?
+func (k RequestKind) IsBatch() bool {
+  return k == RequestBatchLocations || k == RequestBatchGeographies
+}
+
+// Geocoder request passed to a [Handler].
+//
+// Only the fields used by the request kind are set.
+type Request struct {
+  // request kind
+  Kind RequestKind
+
+  // street address (locations and geographies requests)
+  Address string
+
+  // structured address (address requests)
+  StructuredAddress Address
+
+  // coordinates (coordinates requests)
+  Coordinates Coordinates
+
+  // batch input rows (batch requests)
+  Rows []BatchInputRow
+
+  // benchmark ID or name
+  Benchmark string
+
+  // vintage ID or name (geographies requests)
+  Vintage string
+
+  // geography layer IDs or names (non-batch geographies requests)
+  Layers []string
+}
+
+// Geocoder response returned by a [Handler].
+//
+// Only the field used by the request kind is set.
+type Response struct {
+  // address matches (locations, geographies, and address requests)
+  Matches []Match `json:"matches,omitempty"`
+
+  // geography layers (coordinates requests)
+  Geographies GeographyLayers `json:"geographies,omitempty"`
+
+  // batch output rows (batch requests)
+  Rows []BatchOutputRow `json:"rows,omitempty"`
+}
+
+// Geocoder request handler.
+//
+// Every [Geocoder] lookup can be expressed as a single handler call, so
+// middleware only needs to implement one method, like
+// [net/http.RoundTripper].
+type Handler interface {
+  Handle(ctx context.Context, req Request) (Response, error)
+}
+
+// Function which implements [Handler].
+type HandlerFunc func(ctx context.Context, req Request) (Response, error)
+
+// Call handler function.
+func (f HandlerFunc) Handle(ctx context.Context, req Request) (Response, error) {
+  return f(ctx, req)
+}
+
+// Handler middleware, which wraps a handler and returns a new handler.
+type Middleware func(Handler) Handler
+
+// Create handler which sends requests to the given geocoder.
+func NewHandler(g Geocoder) Handler {
+  // unwrap wrapped geocoders
+  if w, ok := g.(wrapped); ok {
+    return w.h
+  }
+
+  return HandlerFunc(func(ctx context.Context, req Request) (Response, error) {
+    var r Response
+    var err error
+
+    switch req.Kind {
+    case RequestLocations:
+      r.Matches, err = g.LocationsFromBenchmarkContext(ctx, req.Address, req.Benchmark)
+    case RequestGeographies:
+      r.Matches, err = g.GeographiesContext(ctx, req.Address, req.Benchmark, req.Vintage, req.Layers...)
+    case RequestAddressLocations:
+      r.Matches, err = g.AddressLocationsFromBenchmarkContext(ctx, req.StructuredAddress, req.Benchmark)
+    case RequestAddressGeographies:
+      r.Matches, err = g.AddressGeographiesContext(ctx, req.StructuredAddress, req.Benchmark, req.Vintage, req.Layers...)
+    case RequestCoordinates:
+      r.Geographies, err = g.GeographiesFromCoordinatesContext(ctx, req.Coordinates, req.Benchmark, req.Vintage, req.Layers...)
+    case RequestBatchLocations:
+      r.Rows, err = g.BatchLocationsFromBenchmarkContext(ctx, req.Rows, req.Benchmark)
+    case RequestBatchGeographies:
+      r.Rows, err = g.BatchGeographiesContext(ctx, req.Rows, req.Benchmark, req.Vintage)
+    default:
+      err = fmt.Errorf("unknown request kind: %s", req.Kind)
+    }
+
+    return r, err
+  })
+}
+
+// Geocoder which sends lookups to a handler.
+type wrapped struct {
+  h Handler
+}
+
+// Create geocoder which sends lookups to the given handler.
+func NewGeocoder(h Handler) Geocoder {
+  return wrapped { h }
+}
+
+// Wrap geocoder with middleware.
+//
+// The first middleware is the outermost, so it sees each request
+// first and each response last.  For example, the following geocoder
+// logs every attempt made by the retry middleware:
+//
+//   g := geocoder.Wrap(client, geocoder.RetryMiddleware(policy), geocoder.LoggingMiddleware(logger))
+func Wrap(g Geocoder, mw ...Middleware) Geocoder {
+  h := NewHandler(g)
+  for i := len(mw) - 1; i >= 0; i-- {
+    h = mw[i](h)
+  }
+
+  return NewGeocoder(h)
+}
+
+// Send request to handler and return matches.
+func (w wrapped) matches(ctx context.Context, req Request) ([]Match, error) {
+  r, err := w.h.Handle(ctx, req)
+  if err != nil {
+    return []Match{}, err
+  }
+  if r.Matches == nil {
+    r.Matches = []Match{}
+  }
+
+  return r.Matches, nil
+}
+
+// Send request to handler and return batch output rows.
+//
+// Rows are returned along with the error, so a [BatchReportError] is
+// passed through with the reconciled rows.
+func (w wrapped) rows(ctx context.Context, req Request) ([]BatchOutputRow, error) {
+  r, err := w.h.Handle(ctx, req)
+  if r.Rows == nil {
+    r.Rows = []BatchOutputRow{}
+  }
+
+  return r.Rows, err
+}
+
+// Geocode street address with given benchmark.
+func (w wrapped) LocationsFromBenchmarkContext(ctx context.Context, address, benchmark string) ([]Match, error) {
+  return w.matches(ctx, Request { Kind: RequestLocations, Address: address, Benchmark: benchmark })
+}
+
+// Geocode street address with given benchmark and vintage.
+func (w wrapped) GeographiesContext(ctx context.Context, address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  return w.matches(ctx, Request { Kind: RequestGeographies, Address: address, Benchmark: benchmark, Vintage: vintage, Layers: layers })
+}
+
+// Geocode structured address with given benchmark.
+func (w wrapped) AddressLocationsFromBenchmarkContext(ctx context.Context, address Address, benchmark string) ([]Match, error) {
+  return w.matches(ctx, Request { Kind: RequestAddressLocations, StructuredAddress: address, Benchmark: benchmark })
+}
+
+// Geocode structured address with given benchmark and vintage.
+func (w wrapped) AddressGeographiesContext(ctx context.Context, address Address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  return w.matches(ctx, Request { Kind: RequestAddressGeographies, StructuredAddress: address, Benchmark: benchmark, Vintage: vintage, Layers: layers })
+}
+
+// Get geography layers for coordinates with given benchmark and
+// vintage.
+func (w wrapped) GeographiesFromCoordinatesContext(ctx context.Context, coords Coordinates, benchmark, vintage string, layers ...string) (GeographyLayers, error) {
+  r, err := w.h.Handle(ctx, Request { Kind: RequestCoordinates, Coordinates: coords, Benchmark: benchmark, Vintage: vintage, Layers: layers })
+  if err != nil {
+    return GeographyLayers{}, err
+  }
+
+  return r.Geographies, nil
+}
+
+// Batch geocode street addresses with given benchmark.
+func (w wrapped) BatchLocationsFromBenchmarkContext(ctx context.Context, rows []BatchInputRow, benchmark string) ([]BatchOutputRow, error) {
+  return w.rows(ctx, Request { Kind: RequestBatchLocations, Rows: rows, Benchmark: benchmark })
+}
+
+// Batch geocode street addresses with given benchmark and vintage.
+func (w wrapped) BatchGeographiesContext(ctx context.Context, rows []BatchInputRow, benchmark, vintage string) ([]BatchOutputRow, error) {
+  return w.rows(ctx, Request { Kind: RequestBatchGeographies, Rows: rows, Benchmark: benchmark, Vintage: vintage })
+}
@@ -0,0 +1,210 @@
+package geocoder
+
+import (
+  "encoding/xml"
+  "errors"
+  "io"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// KML namespace.
+const kmlNamespace = "http://www.opengis.net/kml/2.2"
+
+// Error returned when writing to a closed [KMLWriter] or [GPXWriter].
+var ErrWriterClosed = errors.New("writer closed")
+
+// Named value written as KML extended data or GPX description.
+type placeData struct {
+  Name string `xml:"name,attr"`
+  Value string `xml:"value"`
+}
+
+// Place written as KML placemark or GPX waypoint.
+type place struct {
+  name string // place name
+  coords *Coordinates // coordinates, or nil if unmatched
+  data []placeData // extended data
+}
+
+// Build place data from optional row ID and map of layer name to
+// GEOIDs, sorted by layer name.
+func newPlaceData(id string, ids map[string][]string) []placeData {
+  var r []placeData
+  if id != "" {
+    r = append(r, placeData { "id", id })
+  }
+
+  // sort layer names
+  names := make([]string, 0, len(ids))
+  for name := range(ids) {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  for _, name := range(names) {
+    r = append(r, placeData { name, strings.Join(ids[name], ",") })
+  }
+
+  return r
+}
+
+// Create place from match.
+func matchPlace(m Match) place {
+  coords := m.Coordinates
+  return place {
+    name: m.MatchedAddress,
+    coords: &coords,
+    data: newPlaceData("", geoIds(m.Geographies)),
+  }
+}
+
+// Create place from batch output row.
+//
+// Unmatched rows have no coordinates and are named after the input
+// address.
+func batchPlace(r BatchOutputRow) place {
+  p := place {
+    name: r.MatchAddress,
+    data: newPlaceData(r.Id, r.geoIds()),
+  }
+
+  if r.Match {
+    coords := r.Coordinates
+    p.coords = &coords
+  } else {
+    p.name = r.InputAddress
+  }
+
+  return p
+}
+
+// Format coordinate value.
+func formatCoordinate(v float64) string {
+  return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// KML extended data.
+type kmlExtendedData struct {
+  Data []placeData `xml:"Data"`
+}
+
+// KML point.
+type kmlPoint struct {
+  Coordinates string `xml:"coordinates"`
+}
+
+// KML placemark.
+type kmlPlacemark struct {
+  XMLName xml.Name `xml:"Placemark"`
+  Name string `xml:"name"`
+  ExtendedData *kmlExtendedData `xml:"ExtendedData,omitempty"`
+  Point *kmlPoint `xml:"Point,omitempty"`
+}
+
+// Streaming KML writer.
+//
+// Writes matches and batch output rows as placemarks in a KML document.
+// Each placemark is named after the matched address and has the
+// geography GEOIDs as extended data.  Unmatched batch rows are written
+// as placemarks without a point, named after the input address.
+//
+// Each placemark is written as soon as it is encoded, so large batch
+// outputs do not need to be held in memory.  [KMLWriter.Close] must be
+// called to finish the document.
+type KMLWriter struct {
+  w io.Writer
+  e *xml.Encoder
+  started bool
+  closed bool
+}
+
+// Create KML writer.
+func NewKMLWriter(w io.Writer) *KMLWriter {
+  e := xml.NewEncoder(w)
+  e.Indent("", "  ")
+  return &KMLWriter { w: w, e: e }
+}
+
+// Write document header, if it has not been written yet.
+func (me *KMLWriter) start() error {
+  if me.closed {
+    return ErrWriterClosed
+  }
+
+  if me.started {
+    return nil
+  }
+  me.started = true
+
+  // write XML declaration
+  if _, err := io.WriteString(me.w, xml.Header); err != nil {
+    return err
+  }
+
+  // write kml element and document element
+  for _, token := range([]xml.Token {
+    xml.StartElement {
+      Name: xml.Name { Local: "kml" },
+      Attr: []xml.Attr {{ Name: xml.Name { Local: "xmlns" }, Value: kmlNamespace }},
+    },
+    xml.StartElement { Name: xml.Name { Local: "Document" } },
+  }) {
+    if err := me.e.EncodeToken(token); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+// Write place as placemark.
+func (me *KMLWriter) writePlace(p place) error {
+  if err := me.start(); err != nil {
+    return err
+  }
+
+  pm := kmlPlacemark { Name: p.name }
+  if len(p.data) > 0 {
+    pm.ExtendedData = &kmlExtendedData { p.data }
+  }
+  if p.coords != nil {
+    pm.Point = &kmlPoint { formatCoordinate(p.coords.X) + "," + formatCoordinate(p.coords.Y) }
+  }
+
+  return me.e.Encode(pm)
+}
+
+// Write match as placemark.
+func (me *KMLWriter) WriteMatch(m Match) error {
+  return me.writePlace(matchPlace(m))
+}
+
+// Write batch output row as placemark.
+func (me *KMLWriter) WriteBatchOutputRow(r BatchOutputRow) error {
+  return me.writePlace(batchPlace(r))
+}
+
+// Finish document and flush writes.  Does not close the underlying
+// writer.
+func (me *KMLWriter) Close() error {
+  if err := me.start(); err != nil {
+    return err
+  }
+  me.closed = true
+
+  // close document and kml elements
+  for _, name := range([]string { "Document", "kml" }) {
+    if err := me.e.EncodeToken(xml.EndElement { Name: xml.Name { Local: name } }); err != nil {
+      return err
+    }
+  }
+
+  // flush writes, then write trailing newline
+  if err := me.e.Flush(); err != nil {
+    return err
+  }
+  _, err := io.WriteString(me.w, "\n")
+  return err
+}
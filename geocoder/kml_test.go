@@ -0,0 +1,54 @@
+package geocoder
+
+import (
+  "bytes"
+  "testing"
+)
+
+func TestKMLWriterMatches(t *testing.T) {
+  var buf bytes.Buffer
+  w := NewKMLWriter(&buf)
+  for _, m := range(getMatches(t)) {
+    if err := w.WriteMatch(m); err != nil {
+      t.Fatal(err)
+    }
+  }
+  if err := w.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  checkGolden(t, "geographies.kml", buf.Bytes())
+}
+
+func TestKMLWriterBatchOutputRows(t *testing.T) {
+  var buf bytes.Buffer
+  w := NewKMLWriter(&buf)
+  for _, row := range(getBatchOutputRows(t, "testdata/data/batch-output-geographies-2020-2020.csv")) {
+    if err := w.WriteBatchOutputRow(row); err != nil {
+      t.Fatal(err)
+    }
+  }
+  if err := w.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  checkGolden(t, "batch-output-geographies-2020-2020.kml", buf.Bytes())
+}
+
+func TestKMLWriterEmpty(t *testing.T) {
+  var buf bytes.Buffer
+  w := NewKMLWriter(&buf)
+  if err := w.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  exp := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<kml xmlns=\"http://www.opengis.net/kml/2.2\">\n  <Document></Document>\n</kml>\n"
+  if got := buf.String(); got != exp {
+    t.Fatalf("got %q, exp %q", got, exp)
+  }
+
+  // check write after close
+  if err := w.WriteMatch(Match{}); err != ErrWriterClosed {
+    t.Fatalf("got %v, exp ErrWriterClosed", err)
+  }
+}
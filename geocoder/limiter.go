@@ -0,0 +1,130 @@
+package geocoder
+
+import (
+  "context"
+  "sync"
+  "time"
+)
+
+// Default request rate of [DefaultLimiter], in requests per second.
+const DefaultRateLimit = 10
+
+// Default request burst of [DefaultLimiter].
+const DefaultRateBurst = 10
+
+// Default maximum number of concurrent batch uploads of
+// [DefaultLimiter].
+const DefaultMaxUploads = 4
+
+// Client-side rate limiter.
+//
+// Limits the rate of requests with a token bucket which holds up to
+// `burst` tokens and refills at `rate` tokens per second, and limits
+// the number of concurrent batch uploads.  Each request attempt,
+// including retries, uses one token.
+//
+// A limiter is safe for concurrent use and may be shared by several
+// clients so they have a combined limit.  See [Client.Limiter].
+type Limiter struct {
+  rate float64 // tokens per second, or zero for no limit
+  burst float64 // maximum number of tokens
+
+  mu sync.Mutex // mutex for tokens and last
+  tokens float64 // available tokens (negative if reserved)
+  last time.Time // time of last refill
+
+  uploads chan struct{} // batch upload slots, or nil for no limit
+}
+
+// Create limiter which allows the given number of requests per second
+// with the given burst, and at most the given number of concurrent
+// batch uploads.
+//
+// If the rate is zero or negative, then the request rate is not
+// limited.  If the burst is less than one, then a burst of one is used.
+// If maxUploads is zero or negative, then the number of concurrent
+// batch uploads is not limited.
+func NewLimiter(rate float64, burst, maxUploads int) *Limiter {
+  if burst < 1 {
+    burst = 1
+  }
+
+  l := &Limiter {
+    rate: rate,
+    burst: float64(burst),
+    tokens: float64(burst),
+    last: time.Now(),
+  }
+
+  if maxUploads > 0 {
+    l.uploads = make(chan struct{}, maxUploads)
+  }
+
+  return l
+}
+
+// Limiter used by [DefaultClient]: [DefaultRateLimit] requests per
+// second, with a burst of [DefaultRateBurst] and at most
+// [DefaultMaxUploads] concurrent batch uploads.
+var DefaultLimiter = NewLimiter(DefaultRateLimit, DefaultRateBurst, DefaultMaxUploads)
+
+// Wait until a request is allowed or the context is done.
+//
+// Returns the context error if the context is done first.
+func (l *Limiter) Wait(ctx context.Context) error {
+  if l == nil || l.rate <= 0 {
+    return ctx.Err()
+  }
+
+  // refill tokens, then reserve token
+  l.mu.Lock()
+  now := time.Now()
+  l.tokens += now.Sub(l.last).Seconds() * l.rate
+  if l.tokens > l.burst {
+    l.tokens = l.burst
+  }
+  l.last = now
+  l.tokens--
+  tokens := l.tokens
+  l.mu.Unlock()
+
+  // check for available token
+  if tokens >= 0 {
+    return ctx.Err()
+  }
+
+  // wait for reserved token
+  if err := sleepContext(ctx, time.Duration(-tokens / l.rate * float64(time.Second))); err != nil {
+    // return reserved token
+    l.mu.Lock()
+    l.tokens++
+    l.mu.Unlock()
+    return err
+  }
+
+  return nil
+}
+
+// Wait for batch upload slot or until the context is done.
+//
+// Returns the context error if the context is done first.  Otherwise
+// the slot must be released with [Limiter.releaseUpload].
+func (l *Limiter) acquireUpload(ctx context.Context) error {
+  if l == nil || l.uploads == nil {
+    return ctx.Err()
+  }
+
+  select {
+  case l.uploads <- struct{}{}:
+    return nil
+  case <-ctx.Done():
+    return ctx.Err()
+  }
+}
+
+// Release batch upload slot acquired with [Limiter.acquireUpload].
+func (l *Limiter) releaseUpload() {
+  if l != nil && l.uploads != nil {
+    <-l.uploads
+  }
+}
@@ -0,0 +1,140 @@
+package geocoder
+
+import (
+  "context"
+  "errors"
+  "sync"
+  "testing"
+  "time"
+)
+
+func TestLimiterWait(t *testing.T) {
+  l := NewLimiter(100, 5, 0)
+
+  // burst should not wait, then each request waits about 10ms
+  start := time.Now()
+  for i := 0; i < 15; i++ {
+    if err := l.Wait(context.Background()); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  if d := time.Since(start); d < 80 * time.Millisecond || d > 2 * time.Second {
+    t.Fatalf("got %v, exp about 100ms", d)
+  }
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+  for _, l := range([]*Limiter { nil, NewLimiter(0, 0, 0) }) {
+    start := time.Now()
+    for i := 0; i < 1000; i++ {
+      if err := l.Wait(context.Background()); err != nil {
+        t.Fatal(err)
+      }
+    }
+
+    if d := time.Since(start); d > time.Second {
+      t.Fatalf("got %v, exp no wait", d)
+    }
+  }
+}
+
+func TestLimiterWaitContext(t *testing.T) {
+  l := NewLimiter(0.001, 1, 0)
+  if err := l.Wait(context.Background()); err != nil {
+    t.Fatal(err)
+  }
+
+  // next token is far away, so wait should stop at deadline
+  ctx, cancel := context.WithTimeout(context.Background(), 20 * time.Millisecond)
+  defer cancel()
+  if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+    t.Fatalf("got %v, exp context.DeadlineExceeded", err)
+  }
+}
+
+func TestClientLimiter(t *testing.T) {
+  ms, url, err := newMockServer()
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ms.Close()
+
+  // create two clients which share a limiter
+  l := NewLimiter(50, 1, 0)
+  a, b := NewClient(WithBaseURL(url)), NewClient(WithBaseURL(url))
+  a.Limiter, b.Limiter = l, l
+
+  // send requests concurrently from both clients
+  start := time.Now()
+  var wg sync.WaitGroup
+  errs := make([]error, 10)
+  for i := range(errs) {
+    c := a
+    if i % 2 == 1 {
+      c = b
+    }
+
+    wg.Add(1)
+    go func(i int, c Client) {
+      defer wg.Done()
+      _, errs[i] = c.Locations(testAddress)
+    }(i, c)
+  }
+  wg.Wait()
+
+  for _, err := range(errs) {
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  // check combined rate: 10 requests at 50 per second
+  if d := time.Since(start); d < 150 * time.Millisecond {
+    t.Fatalf("got %v, exp at least 150ms", d)
+  }
+}
+
+func TestClientLimiterUploads(t *testing.T) {
+  s, a := newEchoBatchServer(t, "")
+  defer s.Close()
+
+  // create two clients which share a limiter with 2 upload slots
+  l := NewLimiter(0, 0, 2)
+  b := a
+  a.Limiter, b.Limiter = l, l
+  a.BatchSize, b.BatchSize = 2, 2
+  a.BatchConcurrency, b.BatchConcurrency = 4, 4
+
+  // send batches concurrently from both clients
+  var wg sync.WaitGroup
+  errs := make([]error, 2)
+  for i, c := range([]Client { a, b }) {
+    wg.Add(1)
+    go func(i int, c Client) {
+      defer wg.Done()
+      _, errs[i] = c.BatchLocations(genBatchInputRows(16))
+    }(i, c)
+  }
+  wg.Wait()
+
+  for _, err := range(errs) {
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  // check upload limit
+  if s.maxInFlight > 2 {
+    t.Fatalf("got %d concurrent uploads, exp at most 2", s.maxInFlight)
+  }
+  if len(s.chunks) != 16 {
+    t.Fatalf("got %d chunks, exp 16", len(s.chunks))
+  }
+}
+
+func TestDefaultClientLimiter(t *testing.T) {
+  if DefaultClient.Limiter != DefaultLimiter || DefaultLimiter == nil {
+    t.Fatal("got no limiter, exp DefaultLimiter")
+  }
+}
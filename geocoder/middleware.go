@@ -0,0 +1,314 @@
+package geocoder
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log"
+  net_url "net/url"
+  "strings"
+  "sync"
+  "time"
+)
+
+// Create middleware which caches responses in the given cache.
+//
+// Non-batch responses are cached by request kind, normalized input,
+// benchmark, vintage, and layers.  Batch rows are cached individually, so only
+// uncached rows are sent to the next handler.  Failed responses are
+// not cached.
+//
+// Cache keys do not include settings of the wrapped geocoder (e.g.
+// [Client.Layers]), so do not share a cache between geocoders with
+// different settings.
+func CacheMiddleware(cache *Cache) Middleware {
+  return func(next Handler) Handler {
+    return HandlerFunc(func(ctx context.Context, req Request) (Response, error) {
+      if req.Kind.IsBatch() {
+        return cacheBatch(ctx, cache, next, req)
+      }
+
+      // check cache
+      key := requestCacheKey(req)
+      if val, ok := cache.Get(key); ok {
+        var r Response
+        if err := json.Unmarshal(val, &r); err == nil {
+          return r, nil
+        }
+      }
+
+      // send request
+      r, err := next.Handle(ctx, req)
+      if err != nil {
+        return r, err
+      }
+
+      // cache response (errors are ignored because the cache is only an
+      // optimization)
+      if val, err := json.Marshal(r); err == nil {
+        _ = cache.Set(key, val)
+      }
+
+      return r, nil
+    })
+  }
+}
+
+// Get cache key arguments shared by all requests.
+func requestCacheArgs(req Request) map[string]string {
+  return map[string]string {
+    "benchmark": req.Benchmark,
+    "vintage": req.Vintage,
+    "layers": strings.Join(req.Layers, ","),
+  }
+}
+
+// Build cache key for non-batch request.
+func requestCacheKey(req Request) string {
+  args := requestCacheArgs(req)
+  switch req.Kind {
+  case RequestLocations, RequestGeographies:
+    args["address"] = req.Address
+  case RequestAddressLocations, RequestAddressGeographies:
+    args["street"] = req.StructuredAddress.Street
+    args["city"] = req.StructuredAddress.City
+    args["state"] = req.StructuredAddress.State
+    args["zip"] = req.StructuredAddress.Zip
+  case RequestCoordinates:
+    args["x"] = fmt.Sprint(req.Coordinates.X)
+    args["y"] = fmt.Sprint(req.Coordinates.Y)
+  }
+
+  return cacheKey("middleware/" + req.Kind.String(), args)
+}
+
+// Send uncached batch rows to next handler, cache the results, then
+// return cached and sent rows in input order.
+//
+// If the next handler returns a [BatchReportError] or a
+// [BatchChunkError], then the rows it found are merged with the cached
+// rows, and the reconciled rows are returned with the error.
+func cacheBatch(ctx context.Context, cache *Cache, next Handler, req Request) (Response, error) {
+  endpoint := "middleware/" + req.Kind.String()
+  rows, err := cachedBatch(cache, req.Rows, endpoint, requestCacheArgs(req), func(uncached []BatchInputRow) ([]BatchOutputRow, error) {
+    sub := req
+    sub.Rows = uncached
+    r, err := next.Handle(ctx, sub)
+    return r.Rows, err
+  })
+
+  return Response { Rows: rows }, err
+}
+
+// Create middleware which retries transient failures according to the
+// given policy.
+//
+// API errors are retried if their status is in [RetryPolicy.Statuses],
+// and request errors (e.g. timeouts and refused connections) are
+// retried if [RetryPolicy.RetryError] allows it.  Other errors (e.g.
+// [ErrAddressRequired] or a [BatchReportError]) are returned
+// immediately.
+//
+// Delays match [Client.Retry]: if an API error has a `Retry-After`
+// header, then the header delay is used, and if it is longer than
+// [RetryPolicy.MaxDelay], then the error is returned without retrying.
+//
+// Use this to retry geocoders which do not retry on their own; a
+// [Client] already retries with [Client.Retry].
+func RetryMiddleware(p RetryPolicy) Middleware {
+  return func(next Handler) Handler {
+    return HandlerFunc(func(ctx context.Context, req Request) (Response, error) {
+      for attempt := 1; ; attempt++ {
+        r, err := next.Handle(ctx, req)
+
+        // check for final attempt, success, or non-retryable failure
+        last := attempt >= p.MaxAttempts || ctx.Err() != nil
+        if last || err == nil || !p.retryable(err) {
+          return r, err
+        }
+
+        // get delay; return error if the server asks for a longer
+        // delay than the maximum
+        d, ok := p.errorDelay(attempt - 1, err)
+        if !ok {
+          return r, err
+        }
+
+        // wait before retrying
+        if err := p.wait(ctx, d); err != nil {
+          return Response{}, err
+        }
+      }
+    })
+  }
+}
+
+// Is the error returned by a geocoder retryable?
+func (p RetryPolicy) retryable(err error) bool {
+  var apiErr *APIError
+  if errors.As(err, &apiErr) {
+    return p.retryStatus(apiErr.StatusCode)
+  }
+
+  var urlErr *net_url.Error
+  if errors.As(err, &urlErr) {
+    return p.retryError(err)
+  }
+
+  return false
+}
+
+// Describe request input for log messages.
+func describeRequest(req Request) string {
+  var s string
+  switch req.Kind {
+  case RequestLocations, RequestGeographies:
+    s = fmt.Sprintf("address=%q", req.Address)
+  case RequestAddressLocations, RequestAddressGeographies:
+    a := req.StructuredAddress
+    s = fmt.Sprintf("address=%q", strings.Join([]string { a.Street, a.City, a.State, a.Zip }, ", "))
+  case RequestCoordinates:
+    s = fmt.Sprintf("x=%v y=%v", req.Coordinates.X, req.Coordinates.Y)
+  default:
+    s = fmt.Sprintf("rows=%d", len(req.Rows))
+  }
+
+  if req.Benchmark != "" {
+    s += " benchmark=" + req.Benchmark
+  }
+  if req.Vintage != "" {
+    s += " vintage=" + req.Vintage
+  }
+
+  return s
+}
+
+// Get number of results in response.
+func (r Response) count() int {
+  switch {
+  case r.Rows != nil:
+    return len(r.Rows)
+  case r.Geographies != nil:
+    return len(r.Geographies)
+  default:
+    return len(r.Matches)
+  }
+}
+
+// Create middleware which logs each request, the number of results,
+// the duration, and any error to the given logger.
+//
+// If the logger is nil, then the standard logger is used.
+func LoggingMiddleware(l *log.Logger) Middleware {
+  if l == nil {
+    l = log.Default()
+  }
+
+  return func(next Handler) Handler {
+    return HandlerFunc(func(ctx context.Context, req Request) (Response, error) {
+      start := time.Now()
+      r, err := next.Handle(ctx, req)
+      d := time.Since(start).Round(time.Millisecond)
+
+      if err != nil {
+        l.Printf("geocoder: %s %s: failed after %v: %v", req.Kind, describeRequest(req), d, err)
+      } else {
+        l.Printf("geocoder: %s %s: %d results in %v", req.Kind, describeRequest(req), r.count(), d)
+      }
+
+      return r, err
+    })
+  }
+}
+
+// Request counts and durations recorded by [MetricsMiddleware].
+type MetricsCounts struct {
+  // number of requests
+  Requests int
+
+  // number of failed requests
+  Errors int
+
+  // number of results (matches, geography layers, or batch rows)
+  Results int
+
+  // total request duration
+  Duration time.Duration
+}
+
+// Add counts.
+func (c MetricsCounts) add(o MetricsCounts) MetricsCounts {
+  return MetricsCounts {
+    Requests: c.Requests + o.Requests,
+    Errors: c.Errors + o.Errors,
+    Results: c.Results + o.Results,
+    Duration: c.Duration + o.Duration,
+  }
+}
+
+// Request metrics by request kind.
+//
+// The zero value is ready to use, and metrics are safe for concurrent
+// use.
+type Metrics struct {
+  mu sync.Mutex // mutex for counts
+  counts map[RequestKind]MetricsCounts // counts by request kind
+}
+
+// Record request.
+func (m *Metrics) record(kind RequestKind, c MetricsCounts) {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+
+  if m.counts == nil {
+    m.counts = make(map[RequestKind]MetricsCounts)
+  }
+  m.counts[kind] = m.counts[kind].add(c)
+}
+
+// Get counts for given request kind.
+func (m *Metrics) Get(kind RequestKind) MetricsCounts {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  return m.counts[kind]
+}
+
+// Get counts for all request kinds.
+func (m *Metrics) Total() MetricsCounts {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+
+  var r MetricsCounts
+  for _, c := range(m.counts) {
+    r = r.add(c)
+  }
+
+  return r
+}
+
+// Reset all counts.
+func (m *Metrics) Reset() {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  m.counts = nil
+}
+
+// Create middleware which records request counts and durations in the
+// given metrics.
+func MetricsMiddleware(m *Metrics) Middleware {
+  return func(next Handler) Handler {
+    return HandlerFunc(func(ctx context.Context, req Request) (Response, error) {
+      start := time.Now()
+      r, err := next.Handle(ctx, req)
+
+      c := MetricsCounts { Requests: 1, Results: r.count(), Duration: time.Since(start) }
+      if err != nil {
+        c.Errors = 1
+      }
+      m.record(req.Kind, c)
+
+      return r, err
+    })
+  }
+}
@@ -0,0 +1,429 @@
+package geocoder
+
+import (
+  "bytes"
+  "context"
+  "errors"
+  "log"
+  "net/http"
+  "reflect"
+  "strings"
+  "sync/atomic"
+  "testing"
+  "time"
+)
+
+// Handler which counts requests and returns the result of the given
+// function.
+type countingHandler struct {
+  n int32 // number of requests
+  fn HandlerFunc // response function
+}
+
+// Count request, then call response function.
+func (h *countingHandler) Handle(ctx context.Context, req Request) (Response, error) {
+  atomic.AddInt32(&h.n, 1)
+  return h.fn(ctx, req)
+}
+
+// Response function which echoes batch rows and returns one match for
+// other requests.
+func echoResponse(ctx context.Context, req Request) (Response, error) {
+  switch {
+  case req.Kind.IsBatch():
+    rows := make([]BatchOutputRow, len(req.Rows))
+    for i, row := range(req.Rows) {
+      rows[i] = BatchOutputRow { Id: row.Id, InputAddress: row.Address }
+    }
+    return Response { Rows: rows }, nil
+  case req.Kind == RequestCoordinates:
+    return Response { Geographies: GeographyLayers { "States": nil } }, nil
+  default:
+    return Response { Matches: []Match {{ MatchedAddress: req.Address }} }, nil
+  }
+}
+
+func TestRequestKindString(t *testing.T) {
+  if got := RequestBatchGeographies.String(); got != "batch_geographies" {
+    t.Fatalf("got %q, exp \"batch_geographies\"", got)
+  }
+  if got := RequestKind(99).String(); got != "RequestKind(99)" {
+    t.Fatalf("got %q, exp \"RequestKind(99)\"", got)
+  }
+}
+
+func TestWrapOrder(t *testing.T) {
+  // middleware which records its name
+  var got []string
+  named := func(name string) Middleware {
+    return func(next Handler) Handler {
+      return HandlerFunc(func(ctx context.Context, req Request) (Response, error) {
+        got = append(got, name)
+        return next.Handle(ctx, req)
+      })
+    }
+  }
+
+  g := Wrap(NewGeocoder(HandlerFunc(echoResponse)), named("a"), named("b"))
+  g = Wrap(g, named("outer"))
+  if _, err := g.LocationsFromBenchmarkContext(context.Background(), testAddress, ""); err != nil {
+    t.Fatal(err)
+  }
+
+  exp := []string { "outer", "a", "b" }
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestWrapClient(t *testing.T) {
+  ms, url, err := newMockServer()
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ms.Close()
+
+  var m Metrics
+  g := Wrap(NewClient(WithBaseURL(url)), MetricsMiddleware(&m))
+  ctx := context.Background()
+
+  // check single lookup
+  matches, err := g.LocationsFromBenchmarkContext(ctx, testAddress, "2020")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(matches) != 1 {
+    t.Fatalf("got %v, exp 1 match", matches)
+  }
+
+  // check reverse lookup
+  layers, err := g.GeographiesFromCoordinatesContext(ctx, testCoordinates, "2020", "2020")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(layers) == 0 {
+    t.Fatal("got no layers, exp layers")
+  }
+
+  // check metrics
+  exp := []struct {
+    kind RequestKind // request kind
+    requests int // expected requests
+  } {
+    { RequestLocations, 1 },
+    { RequestCoordinates, 1 },
+    { RequestBatchLocations, 0 },
+  }
+  for _, test := range(exp) {
+    if got := m.Get(test.kind).Requests; got != test.requests {
+      t.Fatalf("%s: got %d requests, exp %d", test.kind, got, test.requests)
+    }
+  }
+  if got := m.Total(); got.Requests != 2 || got.Errors != 0 || got.Results != 1 + len(layers) {
+    t.Fatalf("got %v, exp 2 requests", got)
+  }
+}
+
+func TestCacheMiddleware(t *testing.T) {
+  h := &countingHandler { fn: echoResponse }
+  g := Wrap(NewGeocoder(h), CacheMiddleware(&Cache{}))
+  ctx := context.Background()
+
+  // geocode address twice, with different case and whitespace
+  for _, s := range([]string { testAddress, " 4600 SILVER HILL RD, Washington, DC 20233" }) {
+    got, err := g.LocationsFromBenchmarkContext(ctx, s, "2020")
+    if err != nil {
+      t.Fatal(err)
+    }
+    if len(got) != 1 || got[0].MatchedAddress != testAddress {
+      t.Fatalf("got %v, exp cached match", got)
+    }
+  }
+
+  // different benchmark is not cached
+  if _, err := g.LocationsFromBenchmarkContext(ctx, testAddress, "4"); err != nil {
+    t.Fatal(err)
+  }
+
+  // coordinates are cached
+  for i := 0; i < 2; i++ {
+    if _, err := g.GeographiesFromCoordinatesContext(ctx, testCoordinates, "2020", "2020"); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  if h.n != 3 {
+    t.Fatalf("got %d requests, exp 3", h.n)
+  }
+}
+
+func TestCacheMiddlewareBatch(t *testing.T) {
+  var sent [][]string
+  h := &countingHandler { fn: func(ctx context.Context, req Request) (Response, error) {
+    sent = append(sent, chunkIds(req.Rows))
+    return echoResponse(ctx, req)
+  }}
+  g := Wrap(NewGeocoder(h), CacheMiddleware(&Cache{}))
+  ctx := context.Background()
+
+  // send first rows
+  rows := genBatchInputRows(4)
+  if _, err := g.BatchLocationsFromBenchmarkContext(ctx, rows[:2], "2020"); err != nil {
+    t.Fatal(err)
+  }
+
+  // send all rows, with the first cached row under a new ID
+  rows[0].Id = "new"
+  got, err := g.BatchLocationsFromBenchmarkContext(ctx, rows, "2020")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // check that only uncached rows were sent
+  exp := [][]string {{ "0", "1" }, { "2", "3" }}
+  if !reflect.DeepEqual(sent, exp) {
+    t.Fatalf("got %v, exp %v", sent, exp)
+  }
+
+  // check merged results
+  for i, row := range(got) {
+    if row.Id != rows[i].Id || row.InputAddress != rows[i].Address {
+      t.Fatalf("%d: got %v, exp id %s", i, row, rows[i].Id)
+    }
+  }
+}
+
+func TestCacheMiddlewareBatchReport(t *testing.T) {
+  // handler which drops row "3" and reports it as missing
+  h := &countingHandler { fn: func(ctx context.Context, req Request) (Response, error) {
+    r, _ := echoResponse(ctx, req)
+    var rows []BatchOutputRow
+    for _, row := range(r.Rows) {
+      if row.Id != "3" {
+        rows = append(rows, row)
+      }
+    }
+    rows, report := ReconcileBatchOutput(req.Rows, rows)
+    if !report.Ok() {
+      return Response { Rows: rows }, &BatchReportError { report }
+    }
+    return Response { Rows: rows }, nil
+  }}
+  g := Wrap(NewGeocoder(h), CacheMiddleware(&Cache{}))
+  ctx := context.Background()
+
+  // cache first rows
+  rows := genBatchInputRows(4)
+  if _, err := g.BatchLocationsFromBenchmarkContext(ctx, rows[:2], "2020"); err != nil {
+    t.Fatal(err)
+  }
+
+  // send all rows, check that cached and found rows are returned
+  got, err := g.BatchLocationsFromBenchmarkContext(ctx, rows, "2020")
+  var reportErr *BatchReportError
+  if !errors.As(err, &reportErr) {
+    t.Fatalf("got %v, exp BatchReportError", err)
+  }
+  if exp := []string { "3" }; !reflect.DeepEqual(reportErr.Report.Missing, exp) {
+    t.Fatalf("got %v, exp %v", reportErr.Report.Missing, exp)
+  }
+  if ids, exp := chunkIds(toInputRows(got)), []string { "0", "1", "2" }; !reflect.DeepEqual(ids, exp) {
+    t.Fatalf("got %v, exp %v", ids, exp)
+  }
+
+  // check that found row was cached
+  if _, err := g.BatchLocationsFromBenchmarkContext(ctx, rows[:3], "2020"); err != nil {
+    t.Fatal(err)
+  }
+  if h.n != 2 {
+    t.Fatalf("got %d requests, exp 2", h.n)
+  }
+}
+
+// Get batch input rows with IDs of batch output rows.
+func toInputRows(rows []BatchOutputRow) []BatchInputRow {
+  r := make([]BatchInputRow, len(rows))
+  for i, row := range(rows) {
+    r[i] = BatchInputRow { Id: row.Id }
+  }
+  return r
+}
+
+func TestCacheMiddlewareLayers(t *testing.T) {
+  h := &countingHandler { fn: echoResponse }
+  g := Wrap(NewGeocoder(h), CacheMiddleware(&Cache{}))
+  ctx := context.Background()
+
+  // each distinct set of layers is a separate request
+  for _, layers := range([][]string { nil, { "Counties" }, { "Counties" }, { "Census Tracts" }, nil }) {
+    if _, err := g.GeographiesFromCoordinatesContext(ctx, testCoordinates, "2020", "2020", layers...); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  if h.n != 3 {
+    t.Fatalf("got %d requests, exp 3", h.n)
+  }
+}
+
+func TestCacheMiddlewareError(t *testing.T) {
+  h := &countingHandler { fn: func(ctx context.Context, req Request) (Response, error) {
+    return Response{}, errors.New("failed")
+  }}
+  g := Wrap(NewGeocoder(h), CacheMiddleware(&Cache{}))
+
+  // check that failures are not cached
+  for i := 0; i < 2; i++ {
+    got, err := g.LocationsFromBenchmarkContext(context.Background(), testAddress, "")
+    if err == nil || got == nil {
+      t.Fatalf("got (%v, %v), exp empty matches and error", got, err)
+    }
+  }
+  if h.n != 2 {
+    t.Fatalf("got %d requests, exp 2", h.n)
+  }
+}
+
+func TestRetryMiddleware(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    errs []error // errors returned before success
+    attempts int32 // expected attempts
+    ok bool // expect success?
+    delay time.Duration // expected first delay, if any
+  } {{
+    name: "success",
+    attempts: 1,
+    ok: true,
+  }, {
+    name: "retry status",
+    errs: []error { &APIError { StatusCode: 503 }, &APIError { StatusCode: 502 } },
+    attempts: 3,
+    ok: true,
+  }, {
+    name: "retry chunk",
+    errs: []error { &BatchChunkError { Err: &APIError { StatusCode: 500 } } },
+    attempts: 2,
+    ok: true,
+  }, {
+    name: "retry after",
+    errs: []error { &APIError { StatusCode: 429, Header: http.Header { "Retry-After": { "2" } } } },
+    attempts: 2,
+    ok: true,
+    delay: 2 * time.Second,
+  }, {
+    name: "retry after exceeds max delay",
+    errs: []error { &APIError { StatusCode: 503, Header: http.Header { "Retry-After": { "3600" } } } },
+    attempts: 1,
+  }, {
+    name: "not retryable status",
+    errs: []error { &APIError { StatusCode: 400 } },
+    attempts: 1,
+  }, {
+    name: "not retryable error",
+    errs: []error { ErrAddressRequired },
+    attempts: 1,
+  }, {
+    name: "exhausted",
+    errs: []error { &APIError { StatusCode: 500 }, &APIError { StatusCode: 500 }, &APIError { StatusCode: 500 } },
+    attempts: 3,
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      h := &countingHandler{}
+      h.fn = func(ctx context.Context, req Request) (Response, error) {
+        if n := int(h.n) - 1; n < len(test.errs) {
+          return Response{}, test.errs[n]
+        }
+        return echoResponse(ctx, req)
+      }
+
+      // create retry policy which records delays
+      var delays []time.Duration
+      p := RetryPolicy {
+        MaxAttempts: 3,
+        BaseDelay: 100 * time.Millisecond,
+        MaxDelay: 5 * time.Second,
+        sleep: func(ctx context.Context, d time.Duration) error {
+          delays = append(delays, d)
+          return nil
+        },
+      }
+
+      g := Wrap(NewGeocoder(h), RetryMiddleware(p))
+      _, err := g.LocationsFromBenchmarkContext(context.Background(), testAddress, "")
+      if (err == nil) != test.ok {
+        t.Fatalf("got %v, exp ok = %v", err, test.ok)
+      }
+      if h.n != test.attempts {
+        t.Fatalf("got %d attempts, exp %d", h.n, test.attempts)
+      }
+      if len(delays) != int(test.attempts) - 1 {
+        t.Fatalf("got delays %v, exp %d", delays, test.attempts - 1)
+      }
+      if test.delay > 0 && delays[0] != test.delay {
+        t.Fatalf("got delay %v, exp %v", delays[0], test.delay)
+      }
+    })
+  }
+}
+
+func TestRetryMiddlewareMaxDelay(t *testing.T) {
+  h := &countingHandler{}
+  h.fn = func(ctx context.Context, req Request) (Response, error) {
+    return Response{}, &APIError { StatusCode: 503 }
+  }
+
+  // create retry policy with a base delay longer than the max delay
+  var delays []time.Duration
+  p := RetryPolicy {
+    MaxAttempts: 3,
+    BaseDelay: time.Minute,
+    MaxDelay: time.Second,
+    sleep: func(ctx context.Context, d time.Duration) error {
+      delays = append(delays, d)
+      return nil
+    },
+  }
+
+  g := Wrap(NewGeocoder(h), RetryMiddleware(p))
+  if _, err := g.LocationsFromBenchmarkContext(context.Background(), testAddress, ""); err == nil {
+    t.Fatal("got nil, exp error")
+  }
+
+  // check delays
+  exp := []time.Duration { time.Second, time.Second }
+  if !reflect.DeepEqual(delays, exp) {
+    t.Fatalf("got %v, exp %v", delays, exp)
+  }
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+  var buf bytes.Buffer
+  l := log.New(&buf, "", 0)
+
+  // log success
+  g := Wrap(NewGeocoder(HandlerFunc(echoResponse)), LoggingMiddleware(l))
+  if _, err := g.BatchLocationsFromBenchmarkContext(context.Background(), genBatchInputRows(3), "2020"); err != nil {
+    t.Fatal(err)
+  }
+
+  // log failure
+  g = Wrap(NewGeocoder(HandlerFunc(func(ctx context.Context, req Request) (Response, error) {
+    return Response{}, ErrAddressRequired
+  })), LoggingMiddleware(l))
+  if _, err := g.LocationsFromBenchmarkContext(context.Background(), "", ""); err == nil {
+    t.Fatal("got success, exp error")
+  }
+
+  lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+  for i, exp := range([]string {
+    "geocoder: batch_locations rows=3 benchmark=2020: 3 results in ",
+    "geocoder: locations address=\"\": failed after ",
+  }) {
+    if i >= len(lines) || !strings.HasPrefix(lines[i], exp) {
+      t.Fatalf("got %q, exp line %d to start with %q", lines, i, exp)
+    }
+  }
+}
@@ -0,0 +1,96 @@
+package geocoder
+
+import (
+  "io"
+  "log"
+  "net/http"
+  "net/http/httptest"
+  net_url "net/url"
+  "os"
+  "time"
+)
+
+// Add mock handler to mux at given URL path.
+//
+// If delay is non-zero, then the handler waits for the given duration
+// (or until the request is cancelled) before writing the response.
+func addHandler(mux *http.ServeMux, urlPath, dataPath string, delay time.Duration) error {
+  // read mock data
+  data, err := os.ReadFile(dataPath)
+  if err != nil {
+    return err
+  }
+
+  // add endpoint handler
+  mux.HandleFunc(urlPath, func(w http.ResponseWriter, r *http.Request) {
+    // wait for delay
+    if delay > 0 {
+      // read request body so the server notices cancelled requests
+      if _, err := io.Copy(io.Discard, r.Body); err != nil {
+        return
+      }
+
+      select {
+      case <-time.After(delay):
+      case <-r.Context().Done():
+        return
+      }
+    }
+
+    // write response, check for error
+    if _, err := w.Write(data); err != nil {
+      // log error
+      log.Print(err)
+    }
+  })
+
+  // return success
+  return nil
+}
+
+// mock server endpoints and data
+var mockEndpoints = []struct {
+  urlPath string // url path
+  dataPath string // mock data path
+} {
+  { "/benchmarks", "geocodertest/fixtures/benchmarks.json" },
+  { "/vintages", "geocodertest/fixtures/vintages.json" },
+  { "/locations/onelineaddress", "geocodertest/fixtures/locations.json" },
+  { "/geographies/onelineaddress", "geocodertest/fixtures/geographies.json" },
+  { "/locations/address", "geocodertest/fixtures/address-locations.json" },
+  { "/geographies/address", "geocodertest/fixtures/address-geographies.json" },
+  { "/geographies/coordinates", "geocodertest/fixtures/coordinates.json" },
+  { "/locations/addressbatch", "geocodertest/fixtures/batch-locations-2020.csv" },
+  { "/geographies/addressbatch", "geocodertest/fixtures/batch-geographies-2020-2020.csv" },
+}
+
+// Start new mock server, then return server and server URL.
+func newMockServer() (*httptest.Server, *net_url.URL, error) {
+  return newSlowMockServer(0)
+}
+
+// Start new mock server which waits for the given delay before sending
+// each response, then return server and server URL.
+func newSlowMockServer(delay time.Duration) (*httptest.Server, *net_url.URL, error) {
+  // create mux
+  mux := http.NewServeMux()
+
+  // add handlers
+  for _, row := range(mockEndpoints) {
+    if err := addHandler(mux, row.urlPath, row.dataPath, delay); err != nil {
+      return nil, nil, err
+    }
+  }
+
+  // create mock server
+  server := httptest.NewServer(mux)
+
+  // parse server URL
+  url, err := net_url.Parse(server.URL)
+  if err != nil {
+    return nil, nil, err
+  }
+
+  // return server and url
+  return server, url, nil
+}
@@ -0,0 +1,149 @@
+package geocoder
+
+import (
+  "log"
+  "net/http"
+  net_url "net/url"
+  "time"
+)
+
+// Library version.
+const Version = "0.2.0"
+
+// User-Agent header sent with every request.  If a client has a
+// [Client.UserAgent], then it is prepended to this value.
+const DefaultUserAgent = "census-geocoder/" + Version + " (+https://github.com/pablotron/census-geocoder)"
+
+// Client option.  See [NewClient].
+type Option func(*Client)
+
+// Create new geocoder client with the given options.
+//
+// Without options, the client uses [DefaultUrl] and the zero value for
+// every other setting.  Options are applied in order, so later options
+// override earlier ones.
+//
+// Example:
+//
+//   c := geocoder.NewClient(
+//     geocoder.WithUserAgent("example-app/1.0"),
+//     geocoder.WithTimeout(30 * time.Second),
+//     geocoder.WithLayers("Counties", "Census Tracts"),
+//   )
+func NewClient(opts ...Option) Client {
+  c := Client { Url: DefaultUrl }
+  for _, opt := range(opts) {
+    opt(&c)
+  }
+
+  return c
+}
+
+// Set base API URL.
+func WithBaseURL(url *net_url.URL) Option {
+  return func(c *Client) {
+    c.Url = url
+  }
+}
+
+// Use the given HTTP client to send requests (e.g. to set a custom
+// transport).  See [Client.HTTPClient].
+//
+// The client is shared rather than copied, so later changes to it
+// apply to the geocoder client too.  A nil client is ignored.
+func WithHTTPClient(hc *http.Client) Option {
+  return func(c *Client) {
+    if hc != nil {
+      c.HTTPClient = hc
+    }
+  }
+}
+
+// Set application User-Agent.  The value is prepended to
+// [DefaultUserAgent].
+func WithUserAgent(ua string) Option {
+  return func(c *Client) {
+    c.UserAgent = ua
+  }
+}
+
+// Set benchmark used by methods without a benchmark parameter and
+// when an empty benchmark is given.
+func WithDefaultBenchmark(benchmark string) Option {
+  return func(c *Client) {
+    c.Benchmark = benchmark
+  }
+}
+
+// Set vintage used when an empty vintage is given.
+func WithDefaultVintage(vintage string) Option {
+  return func(c *Client) {
+    c.Vintage = vintage
+  }
+}
+
+// Set HTTP request timeout.  The timeout covers each attempt,
+// including reading the response body.
+//
+// The timeout is set on [Client.Client], so it does not apply to an
+// HTTP client passed to [WithHTTPClient]; set the timeout of that
+// client instead.
+func WithTimeout(timeout time.Duration) Option {
+  return func(c *Client) {
+    c.Client.Timeout = timeout
+  }
+}
+
+// Set geography layers requested from geographies endpoints.  See
+// [Client.Layers].
+func WithLayers(layers ...string) Option {
+  return func(c *Client) {
+    c.Layers = layers
+  }
+}
+
+// Set maximum number of rows per batch upload.  See
+// [Client.BatchSize].
+func WithBatchSize(size int) Option {
+  return func(c *Client) {
+    c.BatchSize = size
+  }
+}
+
+// Set maximum number of concurrent batch uploads per batch call.  See
+// [Client.BatchConcurrency].
+func WithBatchConcurrency(n int) Option {
+  return func(c *Client) {
+    c.BatchConcurrency = n
+  }
+}
+
+// Set response cache.  See [Client.Cache].
+func WithCache(cache *Cache) Option {
+  return func(c *Client) {
+    c.Cache = cache
+  }
+}
+
+// Set retry policy.  See [Client.Retry].
+func WithRetryPolicy(p RetryPolicy) Option {
+  return func(c *Client) {
+    c.Retry = p
+  }
+}
+
+// Set logger for retry and cache events.  See [Client.Logger].
+//
+// Use [LoggingMiddleware] to log every request.
+func WithLogger(l *log.Logger) Option {
+  return func(c *Client) {
+    c.Logger = l
+  }
+}
+
+// Set rate limiter.  See [Client.Limiter].
+func WithLimiter(l *Limiter) Option {
+  return func(c *Client) {
+    c.Limiter = l
+  }
+}
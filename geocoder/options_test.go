@@ -0,0 +1,276 @@
+package geocoder
+
+import (
+  "bytes"
+  "io"
+  "log"
+  "net/http"
+  "net/http/httptest"
+  net_url "net/url"
+  "os"
+  "reflect"
+  "strings"
+  "testing"
+  "time"
+)
+
+// Start server which records the last request and writes the mock
+// locations or coordinates response, then return server, URL, and
+// pointer to the last request.
+func newRecordingServer(t *testing.T) (*httptest.Server, *net_url.URL, **http.Request) {
+  // read mock data
+  locations, err := os.ReadFile("geocodertest/fixtures/locations.json")
+  if err != nil {
+    t.Fatal(err)
+  }
+  coords, err := os.ReadFile("geocodertest/fixtures/coordinates.json")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  var last *http.Request
+  s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    last = r
+    if strings.HasSuffix(r.URL.Path, "/coordinates") {
+      w.Write(coords)
+    } else {
+      w.Write(locations)
+    }
+  }))
+
+  // parse server URL
+  url, err := net_url.Parse(s.URL)
+  if err != nil {
+    s.Close()
+    t.Fatal(err)
+  }
+
+  return s, url, &last
+}
+
+func TestNewClient(t *testing.T) {
+  // check defaults
+  c := NewClient()
+  if c.Url != DefaultUrl || c.UserAgent != "" || c.Layers != nil {
+    t.Fatalf("got %v, exp defaults", c)
+  }
+
+  // check options
+  cache := &Cache{}
+  limiter := NewLimiter(1, 1, 1)
+  logger := log.New(io.Discard, "", 0)
+  hc := &http.Client { Timeout: time.Minute }
+  c = NewClient(
+    WithHTTPClient(hc),
+    WithTimeout(time.Second),
+    WithUserAgent("test/1.0"),
+    WithDefaultBenchmark("4"),
+    WithDefaultVintage("4"),
+    WithLayers("Counties", "States"),
+    WithBatchSize(10),
+    WithBatchConcurrency(2),
+    WithCache(cache),
+    WithRetryPolicy(DefaultRetryPolicy),
+    WithLimiter(limiter),
+    WithLogger(logger),
+  )
+
+  for _, test := range([]struct {
+    name string // field name
+    got, exp any // field value and expected value
+  } {
+    { "Timeout", c.Client.Timeout, time.Second },
+    { "UserAgent", c.UserAgent, "test/1.0" },
+    { "Benchmark", c.Benchmark, "4" },
+    { "Vintage", c.Vintage, "4" },
+    { "Layers", c.Layers, []string { "Counties", "States" } },
+    { "BatchSize", c.BatchSize, 10 },
+    { "BatchConcurrency", c.BatchConcurrency, 2 },
+    { "Cache", c.Cache, cache },
+    { "Retry", c.Retry.MaxAttempts, DefaultRetryPolicy.MaxAttempts },
+    { "Limiter", c.Limiter, limiter },
+    { "Logger", c.Logger, logger },
+  }) {
+    if !reflect.DeepEqual(test.got, test.exp) {
+      t.Fatalf("%s: got %v, exp %v", test.name, test.got, test.exp)
+    }
+  }
+
+  // check that HTTP client is shared and was not changed
+  if c.HTTPClient != hc || hc.Timeout != time.Minute {
+    t.Fatalf("got %v, exp shared client with 1m timeout", c.HTTPClient)
+  }
+}
+
+func TestWithHTTPClient(t *testing.T) {
+  s, url, _ := newRecordingServer(t)
+  defer s.Close()
+
+  // change transport after creating client
+  hc := &http.Client{}
+  c := NewClient(WithBaseURL(url), WithHTTPClient(hc))
+  tr := &countingTransport{}
+  hc.Transport = tr
+
+  // check that later changes to the HTTP client are used
+  if _, err := c.Locations(testAddress); err != nil {
+    t.Fatal(err)
+  }
+  if tr.n != 1 {
+    t.Fatalf("got %d requests, exp 1", tr.n)
+  }
+
+  // check that nil client is ignored
+  c = NewClient(WithBaseURL(url), WithHTTPClient(hc), WithHTTPClient(nil))
+  if c.HTTPClient != hc {
+    t.Fatalf("got %v, exp %v", c.HTTPClient, hc)
+  }
+  if _, err := NewClient(WithBaseURL(url), WithHTTPClient(nil)).Locations(testAddress); err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestWithLogger(t *testing.T) {
+  s, url, _ := newRecordingServer(t)
+  defer s.Close()
+
+  // create client with cache and logger
+  cache, err := NewCache(t.TempDir(), 0)
+  if err != nil {
+    t.Fatal(err)
+  }
+  var buf bytes.Buffer
+  c := NewClient(WithBaseURL(url), WithCache(cache), WithLogger(log.New(&buf, "", 0)))
+
+  // send request twice; the second response is cached
+  for i := 0; i < 2; i++ {
+    if _, err := c.Locations(testAddress); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  // check log
+  if got := buf.String(); !strings.Contains(got, "cache hit") {
+    t.Fatalf("got %q, exp cache hit", got)
+  }
+
+  // check retry log
+  buf.Reset()
+  fs, fc, _ := newFlakyServer(t, []int { 503 }, nil, locationsHandler(t))
+  defer fs.Close()
+  fc.Logger = log.New(&buf, "", 0)
+  if _, err := fc.Locations(testAddress); err != nil {
+    t.Fatal(err)
+  }
+  if got := buf.String(); !strings.Contains(got, "retrying GET") || !strings.Contains(got, "503") {
+    t.Fatalf("got %q, exp retry", got)
+  }
+}
+
+func TestClientUserAgent(t *testing.T) {
+  s, url, last := newRecordingServer(t)
+  defer s.Close()
+
+  tests := []struct {
+    name string // test name
+    ua string // client user agent
+    exp string // expected header
+  } {
+    { "default", "", DefaultUserAgent },
+    { "custom", "test/1.0", "test/1.0 " + DefaultUserAgent },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      c := NewClient(WithBaseURL(url), WithUserAgent(test.ua))
+      if _, err := c.Locations(testAddress); err != nil {
+        t.Fatal(err)
+      }
+
+      got := (*last).Header.Get("User-Agent")
+      if got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+      if !strings.Contains(got, Version) {
+        t.Fatalf("got %q, exp version %s", got, Version)
+      }
+    })
+  }
+}
+
+func TestClientDefaultBenchmarkAndVintage(t *testing.T) {
+  s, url, last := newRecordingServer(t)
+  defer s.Close()
+
+  tests := []struct {
+    name string // test name
+    opts []Option // client options
+    fn func(Client) error // request function
+    benchmark string // expected benchmark parameter
+    vintage string // expected vintage parameter
+  } {{
+    name: "defaults",
+    fn: func(c Client) error {
+      _, err := c.GeographiesFromCoordinates(testCoordinates, "", "")
+      return err
+    },
+    benchmark: DefaultBenchmark,
+    vintage: DefaultVintage,
+  }, {
+    name: "client defaults",
+    opts: []Option { WithDefaultBenchmark("4"), WithDefaultVintage("Census2010_Current") },
+    fn: func(c Client) error {
+      _, err := c.GeographiesFromCoordinates(testCoordinates, "", "")
+      return err
+    },
+    benchmark: "4",
+    vintage: "Census2010_Current",
+  }, {
+    name: "explicit",
+    opts: []Option { WithDefaultBenchmark("4"), WithDefaultVintage("Census2010_Current") },
+    fn: func(c Client) error {
+      _, err := c.GeographiesFromCoordinates(testCoordinates, "2020", "2020")
+      return err
+    },
+    benchmark: "2020",
+    vintage: "2020",
+  }, {
+    name: "locations",
+    opts: []Option { WithDefaultBenchmark("4") },
+    fn: func(c Client) error {
+      _, err := c.Locations(testAddress)
+      return err
+    },
+    benchmark: "4",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      c := NewClient(append([]Option { WithBaseURL(url) }, test.opts...)...)
+      if err := test.fn(c); err != nil {
+        t.Fatal(err)
+      }
+
+      q := (*last).URL.Query()
+      if got := q.Get("benchmark"); got != test.benchmark {
+        t.Fatalf("got benchmark %q, exp %q", got, test.benchmark)
+      }
+      if got := q.Get("vintage"); got != test.vintage {
+        t.Fatalf("got vintage %q, exp %q", got, test.vintage)
+      }
+    })
+  }
+}
+
+func TestClientTimeout(t *testing.T) {
+  ms, url, err := newSlowMockServer(time.Second)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ms.Close()
+
+  c := NewClient(WithBaseURL(url), WithTimeout(20 * time.Millisecond))
+  if _, err := c.Locations(testAddress); err == nil {
+    t.Fatal("got success, exp timeout")
+  }
+}
@@ -0,0 +1,461 @@
+package geocoder
+
+import (
+  "bytes"
+  "encoding/csv"
+  "encoding/json"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+  "text/tabwriter"
+)
+
+// Output format for [WriteMatches], [WriteBatchOutputRows],
+// [WriteBenchmarks], and [WriteVintages].
+type Format string
+
+const (
+  // JSON array.
+  FormatJSON Format = "json"
+
+  // Newline-delimited JSON (one object per line).
+  FormatNDJSON Format = "ndjson"
+
+  // Comma-separated values with header row.
+  FormatCSV Format = "csv"
+
+  // Tab-separated values with header row.
+  FormatTSV Format = "tsv"
+
+  // Aligned human-readable table with header row.
+  FormatTable Format = "table"
+
+  // GeoJSON feature collection.
+  FormatGeoJSON Format = "geojson"
+
+  // KML document (see [KMLWriter]).
+  FormatKML Format = "kml"
+
+  // GPX document (see [GPXWriter]).
+  FormatGPX Format = "gpx"
+)
+
+// All output formats, in order.
+var Formats = []Format {
+  FormatJSON,
+  FormatNDJSON,
+  FormatCSV,
+  FormatTSV,
+  FormatTable,
+  FormatGeoJSON,
+  FormatKML,
+  FormatGPX,
+}
+
+// Parse output format name (case-insensitive).
+func ParseFormat(s string) (Format, error) {
+  for _, f := range(Formats) {
+    if strings.EqualFold(s, string(f)) {
+      return f, nil
+    }
+  }
+
+  return "", fmt.Errorf("unknown format: %s", s)
+}
+
+// Output column.
+type outputColumn[T any] struct {
+  name string // column name
+  get func(T) any // get column value
+}
+
+// Get first geography from typed layer accessor, or an empty value if
+// the layer is missing or invalid.
+func firstGeography[T any](fn func() ([]T, error)) T {
+  var r T
+  if rows, err := fn(); err == nil && len(rows) > 0 {
+    r = rows[0]
+  }
+
+  return r
+}
+
+// Available match output columns, in order.
+var matchColumns = []outputColumn[Match] {
+  { "matched_address", func(m Match) any { return m.MatchedAddress } },
+  { "x", func(m Match) any { return m.Coordinates.X } },
+  { "y", func(m Match) any { return m.Coordinates.Y } },
+  { "tiger_line_id", func(m Match) any { return m.TigerLine.Id } },
+  { "tiger_line_side", func(m Match) any { return m.TigerLine.Side } },
+  { "from_address", func(m Match) any { return m.AddressComponents.FromAddress } },
+  { "to_address", func(m Match) any { return m.AddressComponents.ToAddress } },
+  { "pre_qualifier", func(m Match) any { return m.AddressComponents.PreQualifier } },
+  { "pre_direction", func(m Match) any { return m.AddressComponents.PreDirection } },
+  { "pre_type", func(m Match) any { return m.AddressComponents.PreType } },
+  { "street_name", func(m Match) any { return m.AddressComponents.StreetName } },
+  { "suffix_type", func(m Match) any { return m.AddressComponents.SuffixType } },
+  { "suffix_direction", func(m Match) any { return m.AddressComponents.SuffixDirection } },
+  { "suffix_qualifier", func(m Match) any { return m.AddressComponents.SuffixQualifier } },
+  { "city", func(m Match) any { return m.AddressComponents.City } },
+  { "state", func(m Match) any { return m.AddressComponents.State } },
+  { "zip", func(m Match) any { return m.AddressComponents.Zip } },
+  { "state_fips", func(m Match) any { return firstGeography(m.States).State } },
+  { "county_fips", func(m Match) any { return firstGeography(m.Counties).County } },
+  { "tract", func(m Match) any { return firstGeography(m.Tracts).Tract } },
+  { "block", func(m Match) any { return firstGeography(m.Blocks).Block } },
+  { "block_geoid", func(m Match) any { return firstGeography(m.Blocks).GeoId } },
+}
+
+// Default match output columns.
+var DefaultMatchColumns = []string {
+  "matched_address",
+  "x",
+  "y",
+  "tiger_line_id",
+  "tiger_line_side",
+}
+
+// Available batch output row columns, in order.
+var batchColumns = []outputColumn[BatchOutputRow] {
+  { "id", func(r BatchOutputRow) any { return r.Id } },
+  { "input_address", func(r BatchOutputRow) any { return r.InputAddress } },
+  { "is_match", func(r BatchOutputRow) any { return r.Match } },
+  { "is_exact", func(r BatchOutputRow) any { return r.Exact } },
+  { "match_address", func(r BatchOutputRow) any { return r.MatchAddress } },
+  { "x", func(r BatchOutputRow) any { return r.Coordinates.X } },
+  { "y", func(r BatchOutputRow) any { return r.Coordinates.Y } },
+  { "tiger_line_id", func(r BatchOutputRow) any { return r.TigerLine.Id } },
+  { "tiger_line_side", func(r BatchOutputRow) any { return r.TigerLine.Side } },
+  { "state", func(r BatchOutputRow) any { return r.State } },
+  { "county", func(r BatchOutputRow) any { return r.County } },
+  { "tract", func(r BatchOutputRow) any { return r.Tract } },
+  { "block", func(r BatchOutputRow) any { return r.Block } },
+}
+
+// Default batch output row columns.
+var DefaultBatchColumns = []string {
+  "id",
+  "input_address",
+  "is_match",
+  "is_exact",
+  "match_address",
+  "x",
+  "y",
+  "state",
+  "county",
+  "tract",
+  "block",
+}
+
+// Available benchmark columns, in order.
+var benchmarkColumns = []outputColumn[Benchmark] {
+  { "id", func(b Benchmark) any { return b.Id } },
+  { "name", func(b Benchmark) any { return b.Name } },
+  { "description", func(b Benchmark) any { return b.Description } },
+  { "is_default", func(b Benchmark) any { return b.Default } },
+}
+
+// Default benchmark columns.
+var DefaultBenchmarkColumns = []string { "id", "name", "description", "is_default" }
+
+// Available vintage columns, in order.
+var vintageColumns = []outputColumn[Vintage] {
+  { "id", func(v Vintage) any { return v.Id } },
+  { "name", func(v Vintage) any { return v.Name } },
+  { "description", func(v Vintage) any { return v.Description } },
+  { "is_default", func(v Vintage) any { return v.Default } },
+}
+
+// Default vintage columns.
+var DefaultVintageColumns = []string { "id", "name", "description", "is_default" }
+
+// Get names of columns.
+func columnNames[T any](cols []outputColumn[T]) []string {
+  r := make([]string, len(cols))
+  for i, col := range(cols) {
+    r[i] = col.name
+  }
+
+  return r
+}
+
+// Get names of available match output columns.
+func MatchColumns() []string {
+  return columnNames(matchColumns)
+}
+
+// Get names of available batch output row columns.
+func BatchColumns() []string {
+  return columnNames(batchColumns)
+}
+
+// Find columns by name.
+func findColumns[T any](cols []outputColumn[T], names []string) ([]outputColumn[T], error) {
+  r := make([]outputColumn[T], 0, len(names))
+  for _, name := range(names) {
+    found := false
+    for _, col := range(cols) {
+      if col.name == name {
+        r = append(r, col)
+        found = true
+        break
+      }
+    }
+
+    if !found {
+      return nil, fmt.Errorf("unknown column: %s", name)
+    }
+  }
+
+  return r, nil
+}
+
+// Format column value as string.
+func formatColumnValue(val any) string {
+  switch v := val.(type) {
+  case string:
+    return v
+  case float64:
+    return strconv.FormatFloat(v, 'f', -1, 64)
+  case bool:
+    return strconv.FormatBool(v)
+  default:
+    return fmt.Sprint(v)
+  }
+}
+
+// Output row which is encoded as a JSON object with keys in column
+// order.
+type outputRow struct {
+  names []string // column names
+  vals []any // column values
+}
+
+// Encode row as JSON object with keys in column order.
+//
+// Repeated columns are only written once.
+func (r outputRow) MarshalJSON() ([]byte, error) {
+  var b bytes.Buffer
+  seen := make(map[string]bool, len(r.names))
+
+  b.WriteByte('{')
+  for i, name := range(r.names) {
+    if seen[name] {
+      continue
+    }
+    seen[name] = true
+
+    // encode key and value
+    key, err := json.Marshal(name)
+    if err != nil {
+      return nil, err
+    }
+    val, err := json.Marshal(r.vals[i])
+    if err != nil {
+      return nil, err
+    }
+
+    if b.Len() > 1 {
+      b.WriteByte(',')
+    }
+    b.Write(key)
+    b.WriteByte(':')
+    b.Write(val)
+  }
+  b.WriteByte('}')
+
+  return b.Bytes(), nil
+}
+
+// Write items in given format.
+//
+// If no column names are given, then JSON and NDJSON output contain
+// the full encoded items and other formats contain the given default
+// columns.
+func writeResults[T any](w io.Writer, format Format, cols []outputColumn[T], names, defaults []string, items []T) error {
+  // encode full items as JSON if no columns were given
+  if len(names) == 0 {
+    switch format {
+    case FormatJSON:
+      e := json.NewEncoder(w)
+      e.SetIndent("", "  ")
+      return e.Encode(items)
+    case FormatNDJSON:
+      e := json.NewEncoder(w)
+      for _, item := range(items) {
+        if err := e.Encode(item); err != nil {
+          return err
+        }
+      }
+      return nil
+    }
+
+    names = defaults
+  }
+
+  // find columns
+  cols, err := findColumns(cols, names)
+  if err != nil {
+    return err
+  }
+
+  switch format {
+  case FormatJSON, FormatNDJSON:
+    // build rows with values in column order
+    rows := make([]outputRow, len(items))
+    for i, item := range(items) {
+      rows[i] = outputRow { names: names, vals: make([]any, len(cols)) }
+      for j, col := range(cols) {
+        rows[i].vals[j] = col.get(item)
+      }
+    }
+
+    if format == FormatJSON {
+      e := json.NewEncoder(w)
+      e.SetIndent("", "  ")
+      return e.Encode(rows)
+    }
+
+    e := json.NewEncoder(w)
+    for _, row := range(rows) {
+      if err := e.Encode(row); err != nil {
+        return err
+      }
+    }
+    return nil
+  case FormatCSV, FormatTSV:
+    cw := csv.NewWriter(w)
+    if format == FormatTSV {
+      cw.Comma = '\t'
+    }
+
+    // write header and rows
+    if err := cw.Write(names); err != nil {
+      return err
+    }
+    for _, item := range(items) {
+      row := make([]string, len(cols))
+      for i, col := range(cols) {
+        row[i] = formatColumnValue(col.get(item))
+      }
+
+      if err := cw.Write(row); err != nil {
+        return err
+      }
+    }
+
+    // flush writes
+    cw.Flush()
+    return cw.Error()
+  case FormatTable:
+    tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+    // write header and rows
+    fmt.Fprintln(tw, strings.Join(names, "\t"))
+    for _, item := range(items) {
+      row := make([]string, len(cols))
+      for i, col := range(cols) {
+        // replace tabs so they do not break alignment
+        row[i] = strings.ReplaceAll(formatColumnValue(col.get(item)), "\t", " ")
+      }
+
+      fmt.Fprintln(tw, strings.Join(row, "\t"))
+    }
+
+    // flush writes
+    return tw.Flush()
+  default:
+    return fmt.Errorf("unknown format: %s", format)
+  }
+}
+
+// Streaming KML or GPX writer.
+type placeWriter interface {
+  writePlace(place) error
+  Close() error
+}
+
+// Write items as places, then finish document.
+func writePlaces[T any](pw placeWriter, items []T, fn func(T) place) error {
+  for _, item := range(items) {
+    if err := pw.writePlace(fn(item)); err != nil {
+      return err
+    }
+  }
+
+  return pw.Close()
+}
+
+// Write address matches to writer in given format.
+//
+// The column names select and order the output columns (see
+// [MatchColumns]).  If no column names are given, then JSON and NDJSON
+// output contain the full encoded matches, GeoJSON output contains the
+// properties described in [Match.GeoJSONFeature], and other formats
+// contain [DefaultMatchColumns].  Column names are ignored for KML and
+// GPX output.
+func WriteMatches(w io.Writer, format Format, columns []string, matches []Match) error {
+  switch format {
+  case FormatGeoJSON:
+    return writeGeoJSON(w, matchColumns, columns, matches, Match.GeoJSONFeature)
+  case FormatKML:
+    return writePlaces(NewKMLWriter(w), matches, matchPlace)
+  case FormatGPX:
+    return writePlaces(NewGPXWriter(w), matches, matchPlace)
+  }
+
+  return writeResults(w, format, matchColumns, columns, DefaultMatchColumns, matches)
+}
+
+// Write benchmarks to writer in given format.
+//
+// The column names select and order the output columns (see
+// [DefaultBenchmarkColumns]).  If no column names are given, then JSON
+// and NDJSON output contain the full encoded benchmarks, and other
+// formats contain [DefaultBenchmarkColumns].
+//
+// Benchmarks have no coordinates, so GeoJSON, KML, and GPX output
+// return an error which wraps [ErrNotSupported].
+func WriteBenchmarks(w io.Writer, format Format, columns []string, benchmarks []Benchmark) error {
+  switch format {
+  case FormatGeoJSON, FormatKML, FormatGPX:
+    return fmt.Errorf("%w: %s output of benchmarks", ErrNotSupported, format)
+  }
+
+  return writeResults(w, format, benchmarkColumns, columns, DefaultBenchmarkColumns, benchmarks)
+}
+
+// Write vintages to writer in given format.
+//
+// See [WriteBenchmarks] for details; the default columns are
+// [DefaultVintageColumns].
+func WriteVintages(w io.Writer, format Format, columns []string, vintages []Vintage) error {
+  switch format {
+  case FormatGeoJSON, FormatKML, FormatGPX:
+    return fmt.Errorf("%w: %s output of vintages", ErrNotSupported, format)
+  }
+
+  return writeResults(w, format, vintageColumns, columns, DefaultVintageColumns, vintages)
+}
+
+// Write batch output rows to writer in given format.
+//
+// The column names select and order the output columns (see
+// [BatchColumns]).  If no column names are given, then JSON and NDJSON
+// output contain the full encoded rows, GeoJSON output contains the
+// properties described in [BatchOutputRow.GeoJSONFeature], and other
+// formats contain [DefaultBatchColumns].  Column names are ignored for
+// KML and GPX output.
+func WriteBatchOutputRows(w io.Writer, format Format, columns []string, rows []BatchOutputRow) error {
+  switch format {
+  case FormatGeoJSON:
+    return writeGeoJSON(w, batchColumns, columns, rows, BatchOutputRow.GeoJSONFeature)
+  case FormatKML:
+    return writePlaces(NewKMLWriter(w), rows, batchPlace)
+  case FormatGPX:
+    return writePlaces(NewGPXWriter(w), rows, batchPlace)
+  }
+
+  return writeResults(w, format, batchColumns, columns, DefaultBatchColumns, rows)
+}
@@ -0,0 +1,238 @@
+package geocoder
+
+import (
+  "bytes"
+  "encoding/json"
+  "errors"
+  "io"
+  "strings"
+  "testing"
+)
+
+// test batch output rows
+var testOutputRows = []BatchOutputRow {{
+  Id: "1",
+  InputAddress: "4600 Silver Hill Rd, Washington, DC, 20233",
+  Match: true,
+  Exact: true,
+  MatchAddress: "4600 SILVER HILL RD, WASHINGTON, DC, 20233",
+  Coordinates: Coordinates { -76.92744, 38.845985 },
+  TigerLine: TigerLine { Id: "76355984", Side: "L" },
+  State: "11",
+  County: "001",
+  Tract: "980000",
+  Block: "1034",
+}, {
+  Id: "2",
+  InputAddress: "1 Nowhere Ln",
+}}
+
+func TestParseFormat(t *testing.T) {
+  passTests := []struct {
+    val string // input value
+    exp Format // expected format
+  } {
+    { "json", FormatJSON },
+    { "NDJSON", FormatNDJSON },
+    { "csv", FormatCSV },
+    { "Tsv", FormatTSV },
+    { "table", FormatTable },
+  }
+
+  for _, test := range(passTests) {
+    t.Run(test.val, func(t *testing.T) {
+      got, err := ParseFormat(test.val)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+
+  t.Run("fail", func(t *testing.T) {
+    if got, err := ParseFormat("xml"); err == nil {
+      t.Fatalf("got %q, exp error", got)
+    }
+  })
+}
+
+func TestWriteBatchOutputRows(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    format Format // output format
+    columns []string // output columns
+    exp string // expected output
+  } {{
+    name: "csv",
+    format: FormatCSV,
+    columns: []string { "id", "is_match", "x", "tract" },
+    exp: "id,is_match,x,tract\n1,true,-76.92744,980000\n2,false,0,\n",
+  }, {
+    name: "tsv",
+    format: FormatTSV,
+    columns: []string { "id", "match_address" },
+    exp: "id\tmatch_address\n1\t4600 SILVER HILL RD, WASHINGTON, DC, 20233\n2\t\n",
+  }, {
+    name: "table",
+    format: FormatTable,
+    columns: []string { "id", "is_match", "block" },
+    exp: "id  is_match  block\n1   true      1034\n2   false     \n",
+  }, {
+    name: "ndjson",
+    format: FormatNDJSON,
+    columns: []string { "id", "state" },
+    exp: "{\"id\":\"1\",\"state\":\"11\"}\n{\"id\":\"2\",\"state\":\"\"}\n",
+  }, {
+    name: "json",
+    format: FormatJSON,
+    columns: []string { "id" },
+    exp: "[\n  {\n    \"id\": \"1\"\n  },\n  {\n    \"id\": \"2\"\n  }\n]\n",
+  }, {
+    name: "ndjson column order",
+    format: FormatNDJSON,
+    columns: []string { "state", "is_match", "id" },
+    exp: "{\"state\":\"11\",\"is_match\":true,\"id\":\"1\"}\n{\"state\":\"\",\"is_match\":false,\"id\":\"2\"}\n",
+  }, {
+    name: "json column order",
+    format: FormatJSON,
+    columns: []string { "x", "id" },
+    exp: "[\n  {\n    \"x\": -76.92744,\n    \"id\": \"1\"\n  },\n  {\n    \"x\": 0,\n    \"id\": \"2\"\n  }\n]\n",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      var buf bytes.Buffer
+      if err := WriteBatchOutputRows(&buf, test.format, test.columns, testOutputRows); err != nil {
+        t.Fatal(err)
+      }
+
+      if got := buf.String(); got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestWriteBatchOutputRowsDefaults(t *testing.T) {
+  t.Run("csv", func(t *testing.T) {
+    var buf bytes.Buffer
+    if err := WriteBatchOutputRows(&buf, FormatCSV, nil, testOutputRows); err != nil {
+      t.Fatal(err)
+    }
+
+    // check header
+    exp := strings.Join(DefaultBatchColumns, ",") + "\n"
+    if got := buf.String(); !strings.HasPrefix(got, exp) {
+      t.Fatalf("got %q, exp prefix %q", got, exp)
+    }
+  })
+
+  t.Run("json", func(t *testing.T) {
+    var buf bytes.Buffer
+    if err := WriteBatchOutputRows(&buf, FormatJSON, nil, testOutputRows); err != nil {
+      t.Fatal(err)
+    }
+
+    // decode full rows
+    var got []BatchOutputRow
+    if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+      t.Fatal(err)
+    }
+
+    if len(got) != 2 || got[0] != testOutputRows[0] || got[1] != testOutputRows[1] {
+      t.Fatalf("got %v, exp %v", got, testOutputRows)
+    }
+  })
+}
+
+func TestWriteMatches(t *testing.T) {
+  // decode matches
+  var matches []Match
+  if err := json.Unmarshal(mockGeographiesJson, &matches); err != nil {
+    t.Fatal(err)
+  }
+
+  var buf bytes.Buffer
+  columns := []string { "matched_address", "state_fips", "county_fips", "tract" }
+  if err := WriteMatches(&buf, FormatCSV, columns, matches); err != nil {
+    t.Fatal(err)
+  }
+
+  exp := "matched_address,state_fips,county_fips,tract\n\"4600 SILVER HILL RD, WASHINGTON, DC, 20233\",24,033,802405\n"
+  if got := buf.String(); got != exp {
+    t.Fatalf("got %q, exp %q", got, exp)
+  }
+}
+
+func TestWriteBenchmarksAndVintages(t *testing.T) {
+  benchmarks := []Benchmark {{ Id: "4", Name: "Public_AR_Current", Description: "Public Address Ranges - Current Benchmark", Default: true }}
+  vintages := []Vintage {{ Id: "4", Name: "Current_Current", Description: "Current Vintage - Current Benchmark", Default: true }}
+
+  tests := []struct {
+    name string // test name
+    fn func(io.Writer) error // write function
+    exp string // expected output
+  } {{
+    name: "benchmarks tsv",
+    fn: func(w io.Writer) error { return WriteBenchmarks(w, FormatTSV, nil, benchmarks) },
+    exp: "id\tname\tdescription\tis_default\n4\tPublic_AR_Current\tPublic Address Ranges - Current Benchmark\ttrue\n",
+  }, {
+    name: "benchmarks json",
+    fn: func(w io.Writer) error { return WriteBenchmarks(w, FormatNDJSON, nil, benchmarks) },
+    exp: "{\"id\":\"4\",\"benchmarkName\":\"Public_AR_Current\",\"benchmarkDescription\":\"Public Address Ranges - Current Benchmark\",\"isDefault\":true}\n",
+  }, {
+    name: "vintages csv",
+    fn: func(w io.Writer) error { return WriteVintages(w, FormatCSV, []string { "name", "id" }, vintages) },
+    exp: "name,id\nCurrent_Current,4\n",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      var buf bytes.Buffer
+      if err := test.fn(&buf); err != nil {
+        t.Fatal(err)
+      }
+
+      if got := buf.String(); got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+
+  // check unsupported formats
+  for _, format := range([]Format { FormatGeoJSON, FormatKML, FormatGPX }) {
+    if err := WriteBenchmarks(io.Discard, format, nil, benchmarks); !errors.Is(err, ErrNotSupported) {
+      t.Fatalf("%s: got %v, exp ErrNotSupported", format, err)
+    }
+    if err := WriteVintages(io.Discard, format, nil, vintages); !errors.Is(err, ErrNotSupported) {
+      t.Fatalf("%s: got %v, exp ErrNotSupported", format, err)
+    }
+  }
+}
+
+func TestWriteOutputFail(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    format Format // output format
+    columns []string // output columns
+  } {
+    { "unknown column", FormatCSV, []string { "bogus" } },
+    { "unknown format", Format("xml"), nil },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      var buf bytes.Buffer
+      if err := WriteBatchOutputRows(&buf, test.format, test.columns, testOutputRows); err == nil {
+        t.Fatal("got success, exp error")
+      }
+
+      if err := WriteMatches(&buf, test.format, test.columns, []Match{}); err == nil {
+        t.Fatal("got success, exp error")
+      }
+    })
+  }
+}
@@ -0,0 +1,291 @@
+package geocoder
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "io"
+  "math/rand"
+  "net"
+  "net/http"
+  "strconv"
+  "syscall"
+  "time"
+)
+
+// Default initial delay between attempts.
+const DefaultRetryBaseDelay = 500 * time.Millisecond
+
+// Default maximum delay between attempts.
+const DefaultRetryMaxDelay = 30 * time.Second
+
+// Default HTTP response statuses which are retried.
+var DefaultRetryStatuses = []int {
+  http.StatusTooManyRequests,
+  http.StatusInternalServerError,
+  http.StatusBadGateway,
+  http.StatusServiceUnavailable,
+  http.StatusGatewayTimeout,
+}
+
+// Retry policy for transient failures.
+//
+// Failed requests are retried with exponential backoff: the delay
+// before retry N (starting at zero) is `BaseDelay * 2^N`, capped at
+// `MaxDelay`, then reduced by a random fraction of at most `Jitter`.
+// If a retryable response has a `Retry-After` header, then the header
+// delay is used instead; if the header delay is longer than `MaxDelay`,
+// then the request is not retried and the response is returned.
+//
+// Retries stop when the request context is done.
+//
+// The zero value does not retry.  See [Client.Retry].
+type RetryPolicy struct {
+  // Maximum number of attempts, including the first attempt.  If zero
+  // or one, then requests are not retried.
+  MaxAttempts int
+
+  // Delay before the first retry.  If zero, then
+  // [DefaultRetryBaseDelay] is used.
+  BaseDelay time.Duration
+
+  // Maximum delay between attempts.  If zero, then
+  // [DefaultRetryMaxDelay] is used.
+  MaxDelay time.Duration
+
+  // Fraction of each delay to randomize, between 0 and 1.  Used to keep
+  // concurrent clients from retrying in lockstep.
+  Jitter float64
+
+  // HTTP response statuses which are retried.  If nil, then
+  // [DefaultRetryStatuses] is used.
+  Statuses []int
+
+  // Returns true if the given request error should be retried.  If nil,
+  // then [DefaultRetryError] is used.
+  RetryError func(error) bool
+
+  sleep func(context.Context, time.Duration) error // wait between attempts (used for testing)
+}
+
+// Default retry policy: 4 attempts, with delays starting at
+// [DefaultRetryBaseDelay] and 20% jitter.
+var DefaultRetryPolicy = RetryPolicy {
+  MaxAttempts: 4,
+  Jitter: 0.2,
+}
+
+// Is the response status retryable?
+func (p RetryPolicy) retryStatus(status int) bool {
+  statuses := p.Statuses
+  if statuses == nil {
+    statuses = DefaultRetryStatuses
+  }
+
+  for _, s := range(statuses) {
+    if s == status {
+      return true
+    }
+  }
+
+  return false
+}
+
+// Default request error check.  Returns true if the error is
+// transient: a timeout, a reset or refused connection, or a response
+// which ended unexpectedly.
+//
+// Other request errors (e.g. TLS certificate verification failures,
+// unsupported protocol schemes, invalid URLs, and unknown hosts) will
+// not succeed on retry, so they are not retried.
+func DefaultRetryError(err error) bool {
+  // check for reset or refused connection, or truncated response
+  if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF) {
+    return true
+  }
+
+  // check for timeout
+  var netErr net.Error
+  return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Is the request error retryable?
+func (p RetryPolicy) retryError(err error) bool {
+  if p.RetryError != nil {
+    return p.RetryError(err)
+  }
+
+  return DefaultRetryError(err)
+}
+
+// Get maximum delay between attempts.
+func (p RetryPolicy) maxDelay() time.Duration {
+  if p.MaxDelay <= 0 {
+    return DefaultRetryMaxDelay
+  }
+
+  return p.MaxDelay
+}
+
+// Get delay before given retry (starting at zero).
+func (p RetryPolicy) delay(retry int) time.Duration {
+  base, max := p.BaseDelay, p.maxDelay()
+  if base <= 0 {
+    base = DefaultRetryBaseDelay
+  }
+
+  // calculate exponential delay, checking for overflow
+  d := base
+  for i := 0; i < retry && d < max; i++ {
+    d *= 2
+  }
+  if d > max || d <= 0 {
+    d = max
+  }
+
+  // apply jitter
+  if p.Jitter > 0 {
+    d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+  }
+
+  return d
+}
+
+// Parse Retry-After header as a number of seconds or an HTTP date,
+// then return the delay and true, or false if the header is missing
+// or invalid.
+func parseRetryAfter(h string, now time.Time) (time.Duration, bool) {
+  if h == "" {
+    return 0, false
+  }
+
+  // parse seconds
+  if secs, err := strconv.Atoi(h); err == nil {
+    if secs < 0 {
+      return 0, false
+    }
+    return time.Duration(secs) * time.Second, true
+  }
+
+  // parse date
+  if t, err := http.ParseTime(h); err == nil {
+    if d := t.Sub(now); d > 0 {
+      return d, true
+    }
+    return 0, true
+  }
+
+  return 0, false
+}
+
+// Wait for delay or until context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+  t := time.NewTimer(d)
+  defer t.Stop()
+
+  select {
+  case <-t.C:
+    return nil
+  case <-ctx.Done():
+    return ctx.Err()
+  }
+}
+
+// Get delay before given retry (starting at zero), preferring the
+// Retry-After header of the given response headers.
+//
+// Returns false if the Retry-After delay is longer than the maximum
+// delay, so the request should not be retried.
+func (p RetryPolicy) retryDelay(retry int, h http.Header) (time.Duration, bool) {
+  if ra, ok := parseRetryAfter(h.Get("Retry-After"), time.Now()); ok {
+    return ra, ra <= p.maxDelay()
+  }
+
+  return p.delay(retry), true
+}
+
+// Get delay before given retry (starting at zero) of a failed request,
+// preferring the Retry-After header of the [APIError] wrapped by the
+// given error, if any.
+//
+// Returns false if the Retry-After delay is longer than the maximum
+// delay, so the request should not be retried.
+func (p RetryPolicy) errorDelay(retry int, err error) (time.Duration, bool) {
+  var apiErr *APIError
+  if errors.As(err, &apiErr) {
+    return p.retryDelay(retry, apiErr.Header)
+  }
+
+  return p.retryDelay(retry, nil)
+}
+
+// Wait for delay or until context is done.
+func (p RetryPolicy) wait(ctx context.Context, d time.Duration) error {
+  if p.sleep != nil {
+    return p.sleep(ctx, d)
+  }
+
+  return sleepContext(ctx, d)
+}
+
+// Send request created by the given function, retrying transient
+// failures according to [Client.Retry].  Each attempt waits for
+// [Client.Limiter].
+//
+// A new request is created for each attempt, so request bodies (e.g.
+// streaming batch uploads) are sent in full each time.  The response
+// to the last attempt is returned, even if it has a retryable status.
+func (c Client) do(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+  p := c.Retry
+  for attempt := 1; ; attempt++ {
+    // wait for rate limiter
+    if err := c.Limiter.Wait(ctx); err != nil {
+      return nil, err
+    }
+
+    // create request
+    req, err := newRequest()
+    if err != nil {
+      return nil, err
+    }
+    req.Header.Set("User-Agent", c.userAgent())
+
+    // send request
+    resp, err := c.httpClient().Do(req)
+
+    // check for final attempt, success, or non-retryable failure
+    last := attempt >= p.MaxAttempts || ctx.Err() != nil
+    if last || (err != nil && !p.retryError(err)) || (err == nil && !p.retryStatus(resp.StatusCode)) {
+      return resp, err
+    }
+
+    // get delay; return response if the server asks for a longer
+    // delay than the maximum
+    var h http.Header
+    if resp != nil {
+      h = resp.Header
+    }
+    d, ok := p.retryDelay(attempt - 1, h)
+    if !ok {
+      return resp, err
+    }
+
+    // log retry
+    reason := fmt.Sprint(err)
+    if resp != nil {
+      reason = resp.Status
+    }
+    c.logf("geocoder: retrying %s %s in %v (attempt %d of %d): %s", req.Method, req.URL.Path, d, attempt + 1, p.MaxAttempts, reason)
+
+    // discard response
+    if resp != nil {
+      io.Copy(io.Discard, io.LimitReader(resp.Body, apiErrorBodySize))
+      resp.Body.Close()
+    }
+
+    // wait before retrying
+    if err := p.wait(ctx, d); err != nil {
+      return nil, err
+    }
+  }
+}
@@ -0,0 +1,324 @@
+package geocoder
+
+import (
+  "context"
+  "crypto/x509"
+  "errors"
+  "fmt"
+  "io"
+  "net"
+  "net/http"
+  "net/http/httptest"
+  net_url "net/url"
+  "os"
+  "sync"
+  "syscall"
+  "testing"
+  "time"
+)
+
+// Server which fails the first requests with the given statuses, then
+// sends a successful response from the given handler.
+type flakyServer struct {
+  *httptest.Server
+
+  mu sync.Mutex // mutex for attempts
+  attempts int // number of requests
+}
+
+// Start flaky server, then return server and client with given retry
+// policy.
+//
+// The retry policy records delays instead of sleeping.
+func newFlakyServer(t *testing.T, statuses []int, header http.Header, ok http.HandlerFunc) (*flakyServer, Client, *[]time.Duration) {
+  s := &flakyServer{}
+  s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    n := s.attempts
+    s.attempts++
+    s.mu.Unlock()
+
+    // send failure
+    if n < len(statuses) {
+      for k, vals := range(header) {
+        for _, v := range(vals) {
+          w.Header().Add(k, v)
+        }
+      }
+      http.Error(w, "flaky", statuses[n])
+      return
+    }
+
+    ok(w, r)
+  }))
+
+  // parse server URL
+  url, err := net_url.Parse(s.URL)
+  if err != nil {
+    s.Close()
+    t.Fatal(err)
+  }
+
+  // create client with retry policy which records delays
+  var delays []time.Duration
+  c := NewClient(WithBaseURL(url))
+  c.Retry = RetryPolicy {
+    MaxAttempts: 3,
+    BaseDelay: 100 * time.Millisecond,
+    sleep: func(ctx context.Context, d time.Duration) error {
+      delays = append(delays, d)
+      return ctx.Err()
+    },
+  }
+
+  return s, c, &delays
+}
+
+// Handler which writes the mock locations response.
+func locationsHandler(t *testing.T) http.HandlerFunc {
+  data, err := os.ReadFile("geocodertest/fixtures/locations.json")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  return func(w http.ResponseWriter, r *http.Request) {
+    w.Write(data)
+  }
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+  p := RetryPolicy { BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second }
+
+  // check delays without jitter
+  exp := []time.Duration { 100, 200, 400, 800, 1000, 1000 }
+  for i, ms := range(exp) {
+    if got := p.delay(i); got != ms * time.Millisecond {
+      t.Fatalf("%d: got %v, exp %v", i, got, ms * time.Millisecond)
+    }
+  }
+
+  // check large retry count
+  if got := p.delay(100); got != time.Second {
+    t.Fatalf("got %v, exp 1s", got)
+  }
+
+  // check delays with jitter
+  p.Jitter = 0.5
+  for i := 0; i < 100; i++ {
+    if got := p.delay(1); got < 100 * time.Millisecond || got > 200 * time.Millisecond {
+      t.Fatalf("got %v, exp between 100ms and 200ms", got)
+    }
+  }
+}
+
+func TestParseRetryAfter(t *testing.T) {
+  now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+  tests := []struct {
+    val string // header value
+    exp time.Duration // expected delay
+    ok bool // expected result
+  } {
+    { "", 0, false },
+    { "3", 3 * time.Second, true },
+    { "-1", 0, false },
+    { "soon", 0, false },
+    { "Sat, 01 Oct 2022 12:00:10 GMT", 10 * time.Second, true },
+    { "Sat, 01 Oct 2022 11:00:00 GMT", 0, true },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.val, func(t *testing.T) {
+      got, ok := parseRetryAfter(test.val, now)
+      if got != test.exp || ok != test.ok {
+        t.Fatalf("got (%v, %v), exp (%v, %v)", got, ok, test.exp, test.ok)
+      }
+    })
+  }
+}
+
+func TestClientRetry(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    statuses []int // failure statuses
+    header http.Header // failure headers
+    attempts int // expected number of attempts
+    delays []time.Duration // expected delays
+    status int // expected error status, or 0 for success
+  } {{
+    name: "success",
+    attempts: 1,
+  }, {
+    name: "retry",
+    statuses: []int { 502, 503 },
+    attempts: 3,
+    delays: []time.Duration { 100 * time.Millisecond, 200 * time.Millisecond },
+  }, {
+    name: "retry after",
+    statuses: []int { 429 },
+    header: http.Header { "Retry-After": []string { "7" } },
+    attempts: 2,
+    delays: []time.Duration { 7 * time.Second },
+  }, {
+    name: "retry after too long",
+    statuses: []int { 503 },
+    header: http.Header { "Retry-After": []string { "3600" } },
+    attempts: 1,
+    status: 503,
+  }, {
+    name: "exhausted",
+    statuses: []int { 500, 500, 500 },
+    attempts: 3,
+    delays: []time.Duration { 100 * time.Millisecond, 200 * time.Millisecond },
+    status: 500,
+  }, {
+    name: "not retryable",
+    statuses: []int { 400 },
+    attempts: 1,
+    status: 400,
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      s, c, delays := newFlakyServer(t, test.statuses, test.header, locationsHandler(t))
+      defer s.Close()
+
+      got, err := c.Locations(testAddress)
+
+      // check result
+      if test.status == 0 {
+        if err != nil {
+          t.Fatal(err)
+        }
+        if len(got) != 1 {
+          t.Fatalf("got %v, exp 1 match", got)
+        }
+      } else {
+        var apiErr *APIError
+        if !errors.As(err, &apiErr) || apiErr.StatusCode != test.status {
+          t.Fatalf("got %v, exp status %d", err, test.status)
+        }
+      }
+
+      // check attempts and delays
+      if s.attempts != test.attempts {
+        t.Fatalf("got %d attempts, exp %d", s.attempts, test.attempts)
+      }
+      if fmt.Sprint(*delays) != fmt.Sprint(test.delays) {
+        t.Fatalf("got delays %v, exp %v", *delays, test.delays)
+      }
+    })
+  }
+}
+
+func TestClientRetryError(t *testing.T) {
+  // create client for closed server
+  s, c, delays := newFlakyServer(t, nil, nil, locationsHandler(t))
+  s.Close()
+
+  // check retried request error
+  if _, err := c.Locations(testAddress); err == nil {
+    t.Fatal("got success, exp error")
+  }
+  if len(*delays) != 2 {
+    t.Fatalf("got %d delays, exp 2", len(*delays))
+  }
+
+  // check non-retryable request error
+  *delays = nil
+  c.Retry.RetryError = func(error) bool { return false }
+  if _, err := c.Locations(testAddress); err == nil {
+    t.Fatal("got success, exp error")
+  }
+  if len(*delays) != 0 {
+    t.Fatalf("got %d delays, exp 0", len(*delays))
+  }
+}
+
+func TestDefaultRetryError(t *testing.T) {
+  // wrap error like http.Client.Do
+  urlErr := func(err error) error {
+    return &net_url.Error { Op: "Get", URL: "https://example.com/", Err: err }
+  }
+
+  tests := []struct {
+    name string // test name
+    err error // request error
+    exp bool // expected result
+  } {
+    { "timeout", urlErr(&net.DNSError { Err: "timeout", IsTimeout: true }), true },
+    { "connection reset", urlErr(&net.OpError { Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET) }), true },
+    { "connection refused", urlErr(&net.OpError { Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED) }), true },
+    { "unexpected eof", urlErr(io.ErrUnexpectedEOF), true },
+    { "tls verification", urlErr(x509.UnknownAuthorityError{}), false },
+    { "unsupported protocol scheme", urlErr(errors.New("unsupported protocol scheme \"ftp\"")), false },
+    { "invalid url", &net_url.Error { Op: "parse", URL: "%zz", Err: net_url.EscapeError("%zz") }, false },
+    { "nxdomain", urlErr(&net.OpError { Op: "dial", Err: &net.DNSError { Err: "no such host", Name: "example.invalid", IsNotFound: true } }), false },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      if got := DefaultRetryError(test.err); got != test.exp {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestClientRetryContext(t *testing.T) {
+  s, c, _ := newFlakyServer(t, []int { 503, 503 }, nil, locationsHandler(t))
+  defer s.Close()
+
+  // wait for real, with long delay
+  c.Retry.sleep = nil
+  c.Retry.BaseDelay = time.Minute
+
+  // cancel while waiting
+  ctx, cancel := context.WithTimeout(context.Background(), 50 * time.Millisecond)
+  defer cancel()
+
+  start := time.Now()
+  _, err := c.LocationsContext(ctx, testAddress)
+  if !errors.Is(err, context.DeadlineExceeded) {
+    t.Fatalf("got %v, exp context.DeadlineExceeded", err)
+  }
+  if d := time.Since(start); d > 5 * time.Second {
+    t.Fatalf("got %v, exp prompt return", d)
+  }
+}
+
+func TestClientBatchRetry(t *testing.T) {
+  // echo uploaded rows after failing the first upload
+  s, c, _ := newFlakyServer(t, []int { 502 }, nil, func(w http.ResponseWriter, r *http.Request) {
+    f, _, err := r.FormFile("addressFile")
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    defer f.Close()
+
+    rows, err := NewBatchInputReader(f).ReadAll()
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+
+    for _, row := range(rows) {
+      fmt.Fprintf(w, "%q,%q,\"No_Match\"\n", row.Id, row.Address)
+    }
+  })
+  defer s.Close()
+
+  // check that the full body was re-sent
+  rows := genBatchInputRows(500)
+  got, err := c.BatchLocations(rows)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(got) != len(rows) {
+    t.Fatalf("got %d rows, exp %d", len(got), len(rows))
+  }
+  if s.attempts != 2 {
+    t.Fatalf("got %d attempts, exp 2", s.attempts)
+  }
+}
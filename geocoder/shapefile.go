@@ -0,0 +1,288 @@
+package geocoder
+
+import (
+  "bufio"
+  "encoding/binary"
+  "io"
+  "math"
+  "os"
+  "strings"
+  "time"
+  "unicode/utf8"
+)
+
+// Shapefile shape types.
+const (
+  shapeNull = 0 // null shape
+  shapePoint = 1 // point
+  shapePolyline = 3 // polyline
+  shapePolygon = 5 // polygon
+  shapePointZ = 11 // point with z and measure
+  shapePolylineZ = 13 // polyline with z and measure
+  shapePolygonZ = 15 // polygon with z and measure
+  shapePointM = 21 // point with measure
+  shapePolylineM = 23 // polyline with measure
+  shapePolygonM = 25 // polygon with measure
+)
+
+// Shapefile header length, in bytes.
+const shpHeaderLen = 100
+
+// Projection of Census geocoder coordinates (NAD83, which matches WGS84
+// to within about a meter), as ESRI WKT.
+const ShapefilePrj = `GEOGCS["GCS_North_American_1983",` +
+  `DATUM["D_North_American_1983",SPHEROID["GRS_1980",6378137.0,298.257222101]],` +
+  `PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`
+
+// DBF field.
+type dbfField struct {
+  name string // field name (at most 10 characters)
+  kind byte // field type ('C' for character, 'L' for logical)
+  size int // field length, in bytes
+  get func(BatchOutputRow) string // get field value
+}
+
+// Format logical DBF value.
+func dbfBool(v bool) string {
+  if v {
+    return "T"
+  }
+
+  return "F"
+}
+
+// DBF fields for batch output rows.
+var shapefileFields = []dbfField {
+  { "ID", 'C', 64, func(r BatchOutputRow) string { return r.Id } },
+  { "INPUT_ADDR", 'C', 254, func(r BatchOutputRow) string { return r.InputAddress } },
+  { "MATCH", 'L', 1, func(r BatchOutputRow) string { return dbfBool(r.Match) } },
+  { "EXACT", 'L', 1, func(r BatchOutputRow) string { return dbfBool(r.Exact) } },
+  { "MATCH_ADDR", 'C', 254, func(r BatchOutputRow) string { return r.MatchAddress } },
+  { "TIGER_ID", 'C', 20, func(r BatchOutputRow) string { return r.TigerLine.Id } },
+  { "TIGER_SIDE", 'C', 1, func(r BatchOutputRow) string { return r.TigerLine.Side } },
+  { "STATE", 'C', 2, func(r BatchOutputRow) string { return r.State } },
+  { "COUNTY", 'C', 3, func(r BatchOutputRow) string { return r.County } },
+  { "TRACT", 'C', 6, func(r BatchOutputRow) string { return r.Tract } },
+  { "BLOCK", 'C', 4, func(r BatchOutputRow) string { return r.Block } },
+}
+
+// Truncate string to at most n bytes without splitting a UTF-8
+// character.
+func truncateString(s string, n int) string {
+  if len(s) <= n {
+    return s
+  }
+
+  for n > 0 && !utf8.RuneStart(s[n]) {
+    n--
+  }
+
+  return s[:n]
+}
+
+// Write shapefile main file header.
+//
+// The file length is in 16-bit words.  The bounding box is written as
+// [xmin, ymin, xmax, ymax].
+func writeShpHeader(w io.Writer, fileLen int, bbox [4]float64) error {
+  // file code and unused fields (big-endian)
+  if err := binary.Write(w, binary.BigEndian, [6]int32 { 9994 }); err != nil {
+    return err
+  }
+
+  // file length, in words (big-endian)
+  if err := binary.Write(w, binary.BigEndian, int32(fileLen)); err != nil {
+    return err
+  }
+
+  // version and shape type (little-endian)
+  if err := binary.Write(w, binary.LittleEndian, [2]int32 { 1000, shapePoint }); err != nil {
+    return err
+  }
+
+  // bounding box, then empty z and m ranges (little-endian)
+  if err := binary.Write(w, binary.LittleEndian, bbox); err != nil {
+    return err
+  }
+  return binary.Write(w, binary.LittleEndian, [4]float64{})
+}
+
+// Write batch output rows as an ESRI Point shapefile.
+//
+// The main file (.shp), index (.shx), attributes (.dbf), and
+// projection (.prj) are written to the given writers.  Matched rows
+// are written as points and unmatched rows are written as null shapes,
+// so the shape records and attribute records line up with the input
+// rows.
+//
+// The attribute table has the following fields: ID, INPUT_ADDR, MATCH,
+// EXACT, MATCH_ADDR, TIGER_ID, TIGER_SIDE, STATE, COUNTY, TRACT, and
+// BLOCK.  Values longer than a field are truncated.
+func WriteShapefile(shp, shx, dbf, prj io.Writer, rows []BatchOutputRow) error {
+  // calculate bounding box of matched rows
+  var bbox [4]float64
+  found := false
+  for _, row := range(rows) {
+    if !row.Match {
+      continue
+    }
+
+    x, y := row.Coordinates.X, row.Coordinates.Y
+    if !found {
+      bbox = [4]float64 { x, y, x, y }
+      found = true
+    } else {
+      bbox = [4]float64 {
+        math.Min(bbox[0], x),
+        math.Min(bbox[1], y),
+        math.Max(bbox[2], x),
+        math.Max(bbox[3], y),
+      }
+    }
+  }
+
+  // calculate file lengths, in words
+  shpLen := shpHeaderLen / 2
+  for _, row := range(rows) {
+    // record header (4 words), shape type (2 words), and point (8 words)
+    if row.Match {
+      shpLen += 14
+    } else {
+      shpLen += 6
+    }
+  }
+  shxLen := shpHeaderLen / 2 + 4 * len(rows)
+
+  // write headers of main file and index
+  //
+  // note: buffered writers keep the first write error and return it
+  // from Flush(), so the record writes below are not checked
+  sw, xw := bufio.NewWriter(shp), bufio.NewWriter(shx)
+  writeShpHeader(sw, shpLen, bbox)
+  writeShpHeader(xw, shxLen, bbox)
+
+  // stream records
+  offset := shpHeaderLen / 2
+  for i, row := range(rows) {
+    // get content length, in words
+    contentLen := 2
+    if row.Match {
+      contentLen = 10
+    }
+
+    // write index record: offset and content length (big-endian)
+    binary.Write(xw, binary.BigEndian, [2]int32 { int32(offset), int32(contentLen) })
+
+    // write record header: record number and content length (big-endian)
+    binary.Write(sw, binary.BigEndian, [2]int32 { int32(i + 1), int32(contentLen) })
+
+    // write shape (little-endian)
+    if row.Match {
+      binary.Write(sw, binary.LittleEndian, int32(shapePoint))
+      binary.Write(sw, binary.LittleEndian, [2]float64 { row.Coordinates.X, row.Coordinates.Y })
+    } else {
+      binary.Write(sw, binary.LittleEndian, int32(shapeNull))
+    }
+
+    // advance offset past record header and content
+    offset += 4 + contentLen
+  }
+
+  // flush main file and index
+  if err := sw.Flush(); err != nil {
+    return err
+  }
+  if err := xw.Flush(); err != nil {
+    return err
+  }
+
+  // write attributes and projection
+  if err := writeDbf(dbf, rows, time.Now()); err != nil {
+    return err
+  }
+  _, err := io.WriteString(prj, ShapefilePrj)
+  return err
+}
+
+// Write batch output rows as dBase III attribute table.
+func writeDbf(w io.Writer, rows []BatchOutputRow, modTime time.Time) error {
+  // calculate header and record lengths
+  headerLen := 32 + 32 * len(shapefileFields) + 1
+  recordLen := 1
+  for _, f := range(shapefileFields) {
+    recordLen += f.size
+  }
+
+  // note: buffered writers keep the first write error and return it
+  // from Flush(), so the writes below are not checked
+  buf := bufio.NewWriter(w)
+
+  // write version and last update date
+  buf.Write([]byte { 0x03, byte(modTime.Year() - 1900), byte(modTime.Month()), byte(modTime.Day()) })
+
+  // write number of records, header length, and record length
+  binary.Write(buf, binary.LittleEndian, uint32(len(rows)))
+  binary.Write(buf, binary.LittleEndian, [2]uint16 { uint16(headerLen), uint16(recordLen) })
+  buf.Write(make([]byte, 20))
+
+  // write field descriptors
+  for _, f := range(shapefileFields) {
+    var desc [32]byte
+    copy(desc[:11], f.name)
+    desc[11] = f.kind
+    desc[16] = byte(f.size)
+    buf.Write(desc[:])
+  }
+  buf.WriteByte(0x0d)
+
+  // stream records
+  for _, row := range(rows) {
+    // write deletion flag
+    buf.WriteByte(' ')
+
+    // write space-padded field values
+    for _, f := range(shapefileFields) {
+      val := truncateString(f.get(row), f.size)
+      buf.WriteString(val)
+      buf.WriteString(strings.Repeat(" ", f.size - len(val)))
+    }
+  }
+
+  // write end of file marker
+  buf.WriteByte(0x1a)
+
+  return buf.Flush()
+}
+
+// Write batch output rows as an ESRI Point shapefile to files with the
+// given base path.
+//
+// For example, if the base path is "out/results", then the files
+// "out/results.shp", "out/results.shx", "out/results.dbf", and
+// "out/results.prj" are created.  See [WriteShapefile] for details.
+func WriteShapefileFiles(base string, rows []BatchOutputRow) error {
+  // create files
+  var files [4]*os.File
+  for i, ext := range([]string { ".shp", ".shx", ".dbf", ".prj" }) {
+    f, err := os.Create(base + ext)
+    if err != nil {
+      for _, f := range(files[:i]) {
+        f.Close()
+      }
+      return err
+    }
+    files[i] = f
+  }
+
+  // write shapefile
+  err := WriteShapefile(files[0], files[1], files[2], files[3], rows)
+
+  // close files, keep first error
+  for _, f := range(files) {
+    if closeErr := f.Close(); err == nil {
+      err = closeErr
+    }
+  }
+
+  return err
+}
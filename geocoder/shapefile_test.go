@@ -0,0 +1,288 @@
+package geocoder
+
+import (
+  "bytes"
+  "encoding/binary"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+// Shape read from shapefile main file.
+type testShape struct {
+  kind int32 // shape type
+  x, y float64 // point coordinates
+}
+
+// Read shapefile main file or index header, check it, then return the
+// file length (in words), shape type, and bounding box.
+func readShpHeader(t *testing.T, buf []byte) (int, int32, [4]float64) {
+  if len(buf) < shpHeaderLen {
+    t.Fatalf("got %d bytes, exp at least %d", len(buf), shpHeaderLen)
+  }
+
+  var head struct {
+    Code int32
+    Unused [5]int32
+    Len int32
+  }
+  binary.Read(bytes.NewReader(buf[:28]), binary.BigEndian, &head)
+  if head.Code != 9994 {
+    t.Fatalf("got file code %d, exp 9994", head.Code)
+  }
+
+  var tail struct {
+    Version int32
+    Kind int32
+    Bbox [4]float64
+  }
+  binary.Read(bytes.NewReader(buf[28:]), binary.LittleEndian, &tail)
+  if tail.Version != 1000 {
+    t.Fatalf("got version %d, exp 1000", tail.Version)
+  }
+
+  if int(head.Len) * 2 != len(buf) {
+    t.Fatalf("got file length %d words, exp %d bytes", head.Len, len(buf))
+  }
+
+  return int(head.Len), tail.Kind, tail.Bbox
+}
+
+// Read shapes from shapefile main file, and check them against the
+// index.
+func readShp(t *testing.T, shp, shx []byte) []testShape {
+  _, kind, _ := readShpHeader(t, shp)
+  if kind != shapePoint {
+    t.Fatalf("got shape type %d, exp %d", kind, shapePoint)
+  }
+  readShpHeader(t, shx)
+
+  var r []testShape
+  r2 := bytes.NewReader(shx[shpHeaderLen:])
+  for ofs := shpHeaderLen; ofs < len(shp); {
+    // read record header
+    var head [2]int32
+    binary.Read(bytes.NewReader(shp[ofs:ofs + 8]), binary.BigEndian, &head)
+    if int(head[0]) != len(r) + 1 {
+      t.Fatalf("got record number %d, exp %d", head[0], len(r) + 1)
+    }
+
+    // check index record
+    var index [2]int32
+    if err := binary.Read(r2, binary.BigEndian, &index); err != nil {
+      t.Fatal(err)
+    }
+    if int(index[0]) * 2 != ofs || index[1] != head[1] {
+      t.Fatalf("got index %v, exp [%d %d]", index, ofs / 2, head[1])
+    }
+
+    // read shape
+    var s testShape
+    content := bytes.NewReader(shp[ofs + 8:ofs + 8 + int(head[1]) * 2])
+    binary.Read(content, binary.LittleEndian, &s.kind)
+    if s.kind == shapePoint {
+      binary.Read(content, binary.LittleEndian, &s.x)
+      binary.Read(content, binary.LittleEndian, &s.y)
+    }
+    r = append(r, s)
+
+    ofs += 8 + int(head[1]) * 2
+  }
+
+  return r
+}
+
+// Read records from dBase III attribute table as maps of field name to
+// trimmed value.
+func readDbf(t *testing.T, buf []byte) []map[string]string {
+  if buf[0] != 0x03 {
+    t.Fatalf("got version %d, exp 3", buf[0])
+  }
+
+  // read number of records, header length, and record length
+  numRecords := int(binary.LittleEndian.Uint32(buf[4:8]))
+  headerLen := int(binary.LittleEndian.Uint16(buf[8:10]))
+  recordLen := int(binary.LittleEndian.Uint16(buf[10:12]))
+
+  // read field descriptors
+  type field struct {
+    name string
+    size int
+  }
+  var fields []field
+  for ofs := 32; buf[ofs] != 0x0d; ofs += 32 {
+    fields = append(fields, field {
+      name: strings.TrimRight(string(buf[ofs:ofs + 11]), "\x00"),
+      size: int(buf[ofs + 16]),
+    })
+  }
+
+  // check lengths
+  if exp := headerLen + numRecords * recordLen + 1; len(buf) != exp || buf[exp - 1] != 0x1a {
+    t.Fatalf("got %d bytes, exp %d bytes ending with EOF marker", len(buf), exp)
+  }
+
+  // read records
+  r := make([]map[string]string, numRecords)
+  for i := range(r) {
+    ofs := headerLen + i * recordLen + 1
+    r[i] = make(map[string]string, len(fields))
+    for _, f := range(fields) {
+      r[i][f.name] = strings.TrimRight(string(buf[ofs:ofs + f.size]), " ")
+      ofs += f.size
+    }
+  }
+
+  return r
+}
+
+func TestWriteShapefile(t *testing.T) {
+  rows := append(getBatchOutputRows(t, "testdata/data/batch-output-geographies-2020-2020.csv"), testOutputRows...)
+
+  // write shapefile
+  var shp, shx, dbf, prj bytes.Buffer
+  if err := WriteShapefile(&shp, &shx, &dbf, &prj, rows); err != nil {
+    t.Fatal(err)
+  }
+
+  // check shapes
+  shapes := readShp(t, shp.Bytes(), shx.Bytes())
+  if len(shapes) != len(rows) {
+    t.Fatalf("got %d shapes, exp %d", len(shapes), len(rows))
+  }
+  for i, row := range(rows) {
+    exp := testShape { kind: shapeNull }
+    if row.Match {
+      exp = testShape { shapePoint, row.Coordinates.X, row.Coordinates.Y }
+    }
+
+    if shapes[i] != exp {
+      t.Fatalf("%d: got %v, exp %v", i, shapes[i], exp)
+    }
+  }
+
+  // check bounding box
+  _, _, bbox := readShpHeader(t, shp.Bytes())
+  if exp := [4]float64 { -123.27156825499998, 38.845985, -76.92744, 44.56853142400007 }; bbox != exp {
+    t.Fatalf("got bbox %v, exp %v", bbox, exp)
+  }
+
+  // check attributes
+  records := readDbf(t, dbf.Bytes())
+  if len(records) != len(rows) {
+    t.Fatalf("got %d records, exp %d", len(records), len(rows))
+  }
+  for i, row := range(rows) {
+    // note: DBF values are space-padded, so trailing spaces are lost
+    exp := map[string]string {
+      "ID": row.Id,
+      "INPUT_ADDR": strings.TrimRight(row.InputAddress, " "),
+      "MATCH": dbfBool(row.Match),
+      "EXACT": dbfBool(row.Exact),
+      "MATCH_ADDR": row.MatchAddress,
+      "TIGER_ID": row.TigerLine.Id,
+      "TIGER_SIDE": row.TigerLine.Side,
+      "STATE": row.State,
+      "COUNTY": row.County,
+      "TRACT": row.Tract,
+      "BLOCK": row.Block,
+    }
+
+    if got := fmt.Sprint(records[i]); got != fmt.Sprint(exp) {
+      t.Fatalf("%d: got %s, exp %s", i, got, exp)
+    }
+  }
+
+  // check projection
+  if got := prj.String(); got != ShapefilePrj {
+    t.Fatalf("got %q, exp %q", got, ShapefilePrj)
+  }
+}
+
+func TestWriteShapefileTruncate(t *testing.T) {
+  // write row with long UTF-8 address
+  addr := strings.Repeat("é", 200)
+  var shp, shx, dbf, prj bytes.Buffer
+  if err := WriteShapefile(&shp, &shx, &dbf, &prj, []BatchOutputRow {{ Id: "1", InputAddress: addr }}); err != nil {
+    t.Fatal(err)
+  }
+
+  // check truncated value
+  records := readDbf(t, dbf.Bytes())
+  if exp := strings.Repeat("é", 127); records[0]["INPUT_ADDR"] != exp {
+    t.Fatalf("got %q, exp %q", records[0]["INPUT_ADDR"], exp)
+  }
+}
+
+// Writer which fails after a given number of bytes.
+type limitedWriter struct {
+  n int // remaining bytes
+}
+
+var errLimitedWriter = errors.New("write limit exceeded")
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+  if len(p) > w.n {
+    n := w.n
+    w.n = 0
+    return n, errLimitedWriter
+  }
+
+  w.n -= len(p)
+  return len(p), nil
+}
+
+func TestWriteShapefileError(t *testing.T) {
+  // write enough rows to span several buffered writes
+  var rows []BatchOutputRow
+  for i := 0; i < 1000; i++ {
+    rows = append(rows, BatchOutputRow { Id: fmt.Sprint(i), Match: i % 2 == 0 })
+  }
+
+  tests := []struct {
+    name string // test name
+    shp, shx, dbf io.Writer // writers
+  } {
+    { "shp", &limitedWriter { 5000 }, io.Discard, io.Discard },
+    { "shx", io.Discard, &limitedWriter { 5000 }, io.Discard },
+    { "dbf", io.Discard, io.Discard, &limitedWriter { 5000 } },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      err := WriteShapefile(test.shp, test.shx, test.dbf, io.Discard, rows)
+      if !errors.Is(err, errLimitedWriter) {
+        t.Fatalf("got %v, exp %v", err, errLimitedWriter)
+      }
+    })
+  }
+}
+
+func TestWriteShapefileFiles(t *testing.T) {
+  base := filepath.Join(t.TempDir(), "results")
+  if err := WriteShapefileFiles(base, testOutputRows); err != nil {
+    t.Fatal(err)
+  }
+
+  // read files
+  var bufs [4][]byte
+  for i, ext := range([]string { ".shp", ".shx", ".dbf", ".prj" }) {
+    buf, err := os.ReadFile(base + ext)
+    if err != nil {
+      t.Fatal(err)
+    }
+    bufs[i] = buf
+  }
+
+  // check shapes and records
+  if got := readShp(t, bufs[0], bufs[1]); len(got) != 2 || got[1].kind != shapeNull {
+    t.Fatalf("got %v, exp point and null shape", got)
+  }
+  if got := readDbf(t, bufs[2]); len(got) != 2 || got[0]["TRACT"] != "980000" {
+    t.Fatalf("got %v, exp 2 records", got)
+  }
+}
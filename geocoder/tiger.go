@@ -0,0 +1,688 @@
+package geocoder
+
+import (
+  "context"
+  "io"
+  "math"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// Address range on one side of a TIGER/Line edge, from an address
+// feature (ADDRFEAT) file.
+type AddressRange struct {
+  // TIGER/Line edge ID (TLID)
+  TigerLineId string
+
+  // full street name (FULLNAME, e.g. "Silver Hill Rd")
+  Street string
+
+  // side of edge ("L" or "R")
+  Side string
+
+  // house number at the start of the edge
+  From int
+
+  // house number at the end of the edge
+  To int
+
+  // house number parity: "O" (odd), "E" (even), or "B" (both).  If
+  // empty, then parity is derived from From and To.
+  Parity string
+
+  // zip code
+  Zip string
+
+  // city and state (optional; not part of ADDRFEAT files)
+  City, State string
+
+  // edge geometry, from start to end
+  Line []Coordinates
+}
+
+// Get house number parity.
+func (r AddressRange) parity() string {
+  if r.Parity != "" {
+    return r.Parity
+  }
+
+  if r.From % 2 == r.To % 2 {
+    if r.From % 2 == 0 {
+      return "E"
+    }
+    return "O"
+  }
+
+  return "B"
+}
+
+// Does the address range contain the given house number?
+func (r AddressRange) contains(n int) bool {
+  lo, hi := r.From, r.To
+  if lo > hi {
+    lo, hi = hi, lo
+  }
+  if n < lo || n > hi {
+    return false
+  }
+
+  switch r.parity() {
+  case "O":
+    return n % 2 == 1
+  case "E":
+    return n % 2 == 0
+  default:
+    return true
+  }
+}
+
+// Interpolate position of house number along the edge.
+//
+// Distances are measured in degrees, with longitude scaled by the
+// cosine of the latitude.
+func (r AddressRange) interpolate(n int) Coordinates {
+  if len(r.Line) == 0 {
+    return Coordinates{}
+  }
+
+  // get fraction of the way along the edge
+  t := 0.5
+  if r.From != r.To {
+    t = float64(n - r.From) / float64(r.To - r.From)
+  }
+
+  // get segment lengths
+  scale := math.Cos(r.Line[0].Y * math.Pi / 180)
+  lens := make([]float64, len(r.Line) - 1)
+  total := 0.0
+  for i := range(lens) {
+    a, b := r.Line[i], r.Line[i + 1]
+    lens[i] = math.Hypot((b.X - a.X) * scale, b.Y - a.Y)
+    total += lens[i]
+  }
+
+  // find segment which contains the point
+  d := t * total
+  for i, l := range(lens) {
+    if d <= l && l > 0 {
+      a, b := r.Line[i], r.Line[i + 1]
+      f := d / l
+      return Coordinates { X: a.X + f * (b.X - a.X), Y: a.Y + f * (b.Y - a.Y) }
+    }
+    d -= l
+  }
+
+  return r.Line[len(r.Line) - 1]
+}
+
+// Get address ranges from ADDRFEAT features.
+//
+// Each feature has up to two ranges: left (LFROMHN, LTOHN, ZIPL,
+// PARITYL) and right (RFROMHN, RTOHN, ZIPR, PARITYR).  Sides with
+// missing or non-numeric house numbers are skipped.  Optional CITY and
+// STATE attributes are used if present.
+func addressRanges(fs []feature) []AddressRange {
+  var r []AddressRange
+  for _, f := range(fs) {
+    // join line parts
+    var line []Coordinates
+    for _, part := range(f.parts) {
+      line = append(line, part...)
+    }
+
+    for _, side := range([]string { "L", "R" }) {
+      from, fromErr := strconv.Atoi(f.props[side + "FROMHN"])
+      to, toErr := strconv.Atoi(f.props[side + "TOHN"])
+      if fromErr != nil || toErr != nil {
+        continue
+      }
+
+      r = append(r, AddressRange {
+        TigerLineId: f.props["TLID"],
+        Street: f.props["FULLNAME"],
+        Side: side,
+        From: from,
+        To: to,
+        Parity: f.props["PARITY" + side],
+        Zip: f.props["ZIP" + side],
+        City: f.props["CITY"],
+        State: f.props["STATE"],
+        Line: line,
+      })
+    }
+  }
+
+  return r
+}
+
+// Read address ranges from ADDRFEAT shapefile main file and DBF file.
+func ReadAddressRanges(shp, dbf io.Reader) ([]AddressRange, error) {
+  fs, err := readShapefileFeatures(shp, dbf)
+  if err != nil {
+    return nil, err
+  }
+
+  return addressRanges(fs), nil
+}
+
+// Read address ranges from GeoJSON feature collection with ADDRFEAT
+// properties and LineString or MultiLineString geometries.
+func ReadAddressRangesGeoJSON(r io.Reader) ([]AddressRange, error) {
+  fs, err := readGeoJSONFeatures(r)
+  if err != nil {
+    return nil, err
+  }
+
+  return addressRanges(fs), nil
+}
+
+// Load address ranges from ADDRFEAT shapefile (".shp", with a ".dbf"
+// file next to it) or GeoJSON file (".geojson" or ".json").
+func LoadAddressRanges(path string) ([]AddressRange, error) {
+  fs, err := loadFeatures(path)
+  if err != nil {
+    return nil, err
+  }
+
+  return addressRanges(fs), nil
+}
+
+// street name abbreviations, in the style of TIGER/Line FULLNAME
+var streetAbbrevs = map[string]string {
+  "NORTH": "N",
+  "SOUTH": "S",
+  "EAST": "E",
+  "WEST": "W",
+  "NORTHEAST": "NE",
+  "NORTHWEST": "NW",
+  "SOUTHEAST": "SE",
+  "SOUTHWEST": "SW",
+  "AVENUE": "AVE",
+  "BOULEVARD": "BLVD",
+  "CIRCLE": "CIR",
+  "COURT": "CT",
+  "DRIVE": "DR",
+  "HIGHWAY": "HWY",
+  "LANE": "LN",
+  "PARKWAY": "PKWY",
+  "PLACE": "PL",
+  "ROAD": "RD",
+  "SQUARE": "SQ",
+  "STREET": "ST",
+  "TERRACE": "TER",
+  "TRAIL": "TRL",
+}
+
+// directional prefixes and suffixes
+var streetDirections = map[string]bool {
+  "N": true, "S": true, "E": true, "W": true,
+  "NE": true, "NW": true, "SE": true, "SW": true,
+}
+
+// street suffix types
+var streetTypes = map[string]bool {
+  "AVE": true, "BLVD": true, "CIR": true, "CT": true, "DR": true,
+  "HWY": true, "LN": true, "PKWY": true, "PL": true, "RD": true,
+  "SQ": true, "ST": true, "TER": true, "TRL": true, "WAY": true,
+}
+
+// Split address into uppercase words, without punctuation.
+func addressWords(s string) []string {
+  return strings.Fields(strings.Map(func(r rune) rune {
+    if r == '.' || r == ',' || r == '#' {
+      return ' '
+    }
+    return r
+  }, strings.ToUpper(s)))
+}
+
+// Normalize street name words: abbreviate directions and types.
+func normalizeStreetWords(words []string) string {
+  r := make([]string, len(words))
+  for i, w := range(words) {
+    if abbrev, ok := streetAbbrevs[w]; ok {
+      w = abbrev
+    }
+    r[i] = w
+  }
+
+  return strings.Join(r, " ")
+}
+
+// Normalize street name (e.g. "Silver Hill Road" -> "SILVER HILL RD").
+func normalizeStreet(s string) string {
+  return normalizeStreetWords(addressWords(s))
+}
+
+// Is the string a zip code (e.g. "20233" or "20233-0001")?
+func isZip(s string) bool {
+  if len(s) != 5 && len(s) != 10 {
+    return false
+  }
+
+  for i, c := range(s) {
+    if (i == 5 && c != '-') || (i != 5 && (c < '0' || c > '9')) {
+      return false
+    }
+  }
+
+  return true
+}
+
+// Is the string a two-letter state abbreviation?
+func isState(s string) bool {
+  return len(s) == 2 && s[0] >= 'A' && s[0] <= 'Z' && s[1] >= 'A' && s[1] <= 'Z'
+}
+
+// Split trailing state abbreviation from city words, then return the
+// state and city.
+func splitState(words []string) (string, string) {
+  if len(words) > 0 && isState(words[len(words) - 1]) {
+    return words[len(words) - 1], strings.Join(words[:len(words) - 1], " ")
+  }
+
+  return "", strings.Join(words, " ")
+}
+
+// Parsed address.
+type parsedAddress struct {
+  number int // house number
+  street []string // street words, or street, city, and state words if the boundary is unknown
+  split bool // is the boundary between street and city known?
+  city, state, zip string // city, state, and zip code
+}
+
+// Parse single-line address (e.g. "4600 Silver Hill Rd, Washington, DC
+// 20233").
+//
+// If the address contains commas, then the street is the first
+// comma-separated part.  Otherwise the street, city, and state words
+// are returned together, and the street is found by
+// [AddressRangeGeocoder] using known street names.
+func parseAddress(s string) (parsedAddress, bool) {
+  var r parsedAddress
+
+  // get house number and street words
+  head, tail, split := strings.Cut(s, ",")
+  words := addressWords(head)
+  if len(words) < 2 {
+    return r, false
+  }
+  n, err := strconv.Atoi(words[0])
+  if err != nil {
+    return r, false
+  }
+  r.number, r.street, r.split = n, words[1:], split
+
+  // get zip code and state from end of address
+  rest := addressWords(tail)
+  if !split {
+    rest, r.street = r.street, nil
+  }
+  if len(rest) > 0 && isZip(rest[len(rest) - 1]) {
+    r.zip = rest[len(rest) - 1][:5]
+    rest = rest[:len(rest) - 1]
+  }
+  if !split {
+    // note: state is found with the street, because street words such
+    // as "ST" look like state abbreviations
+    r.street = rest
+    return r, len(r.street) > 0
+  }
+  r.state, r.city = splitState(rest)
+
+  return r, len(r.street) > 0
+}
+
+// Offline geocoder which interpolates addresses along TIGER/Line
+// address ranges.
+//
+// Geography lookups are answered with the boundaries in Boundaries.  If
+// Boundaries is nil, then geography lookups return [ErrNotSupported].
+// Benchmarks and vintages are ignored.
+type AddressRangeGeocoder struct {
+  // boundaries used for geography lookups (optional)
+  Boundaries *BoundaryGeocoder
+
+  ranges []AddressRange // address ranges
+  streets map[string][]int // indices of address ranges by normalized street name
+}
+
+// make sure AddressRangeGeocoder implements Geocoder
+var _ Geocoder = (*AddressRangeGeocoder)(nil)
+
+// Create offline geocoder from address ranges.
+func NewAddressRangeGeocoder(ranges []AddressRange) *AddressRangeGeocoder {
+  g := &AddressRangeGeocoder {
+    ranges: ranges,
+    streets: make(map[string][]int),
+  }
+
+  for i, r := range(ranges) {
+    key := normalizeStreet(r.Street)
+    g.streets[key] = append(g.streets[key], i)
+  }
+
+  return g
+}
+
+// Create offline geocoder from ADDRFEAT files (see [LoadAddressRanges]).
+func LoadAddressRangeGeocoder(paths ...string) (*AddressRangeGeocoder, error) {
+  var ranges []AddressRange
+  for _, path := range(paths) {
+    rs, err := LoadAddressRanges(path)
+    if err != nil {
+      return nil, err
+    }
+    ranges = append(ranges, rs...)
+  }
+
+  return NewAddressRangeGeocoder(ranges), nil
+}
+
+// Find street in parsed address, then return normalized street name.
+//
+// If the boundary between street and city is unknown, then the longest
+// known street name at the start of the street words is used, and the
+// remaining words are used as the city and state.
+func (g *AddressRangeGeocoder) findStreet(a *parsedAddress) (string, bool) {
+  if a.split {
+    street := normalizeStreetWords(a.street)
+    _, ok := g.streets[street]
+    return street, ok
+  }
+
+  for n := len(a.street); n > 0; n-- {
+    street := normalizeStreetWords(a.street[:n])
+    if _, ok := g.streets[street]; ok {
+      a.state, a.city = splitState(a.street[n:])
+      return street, true
+    }
+  }
+
+  return "", false
+}
+
+// Build match for house number in address range.
+func (g *AddressRangeGeocoder) newMatch(r AddressRange, a parsedAddress) Match {
+  var m Match
+  m.TigerLine = TigerLine { Id: r.TigerLineId, Side: r.Side }
+  m.Coordinates = r.interpolate(a.number)
+
+  // split street name into components
+  words := addressWords(normalizeStreet(r.Street))
+  c := &m.AddressComponents
+  if len(words) > 1 && streetDirections[words[0]] {
+    c.PreDirection, words = words[0], words[1:]
+  }
+  if len(words) > 1 && streetDirections[words[len(words) - 1]] {
+    c.SuffixDirection, words = words[len(words) - 1], words[:len(words) - 1]
+  }
+  if len(words) > 1 && streetTypes[words[len(words) - 1]] {
+    c.SuffixType, words = words[len(words) - 1], words[:len(words) - 1]
+  }
+  c.StreetName = strings.Join(words, " ")
+
+  // get range, city, state, and zip
+  c.FromAddress = strconv.Itoa(r.From)
+  c.ToAddress = strconv.Itoa(r.To)
+  c.City = strings.ToUpper(r.City)
+  if c.City == "" {
+    c.City = a.city
+  }
+  c.State = strings.ToUpper(r.State)
+  if c.State == "" {
+    c.State = a.state
+  }
+  c.Zip = r.Zip
+
+  // build matched address (e.g. "4600 SILVER HILL RD, WASHINGTON, DC,
+  // 20233")
+  var parts []string
+  for _, s := range([]string {
+    strconv.Itoa(a.number) + " " + normalizeStreet(r.Street),
+    c.City,
+    c.State,
+    c.Zip,
+  }) {
+    if s != "" {
+      parts = append(parts, s)
+    }
+  }
+  m.MatchedAddress = strings.Join(parts, ", ")
+
+  return m
+}
+
+// Geocode parsed address, then return matches and whether the zip code
+// matched.
+//
+// If the address has a zip code, then ranges with that zip code are
+// preferred; if no range has that zip code, then all ranges on the
+// street are used.
+func (g *AddressRangeGeocoder) geocode(a parsedAddress) ([]Match, bool) {
+  street, ok := g.findStreet(&a)
+  if !ok {
+    return []Match{}, false
+  }
+
+  // find ranges which contain house number
+  var found, zipFound []AddressRange
+  for _, i := range(g.streets[street]) {
+    if r := g.ranges[i]; r.contains(a.number) {
+      found = append(found, r)
+      if a.zip != "" && r.Zip == a.zip {
+        zipFound = append(zipFound, r)
+      }
+    }
+  }
+
+  exact := len(zipFound) > 0
+  if exact {
+    found = zipFound
+  }
+
+  // sort by edge ID and side so results are stable
+  sort.SliceStable(found, func(i, j int) bool {
+    if found[i].TigerLineId != found[j].TigerLineId {
+      return found[i].TigerLineId < found[j].TigerLineId
+    }
+    return found[i].Side < found[j].Side
+  })
+
+  r := make([]Match, len(found))
+  for i, rng := range(found) {
+    r[i] = g.newMatch(rng, a)
+  }
+
+  return r, exact
+}
+
+// Geocode single-line address.
+func (g *AddressRangeGeocoder) geocodeString(address string) ([]Match, bool) {
+  a, ok := parseAddress(address)
+  if !ok {
+    return []Match{}, false
+  }
+
+  return g.geocode(a)
+}
+
+// Geocode structured address.
+func (g *AddressRangeGeocoder) geocodeAddress(address Address) ([]Match, bool) {
+  words := addressWords(address.Street)
+  if len(words) < 2 {
+    return []Match{}, false
+  }
+  n, err := strconv.Atoi(words[0])
+  if err != nil {
+    return []Match{}, false
+  }
+
+  zip := address.Zip
+  if len(zip) > 5 {
+    zip = zip[:5]
+  }
+
+  return g.geocode(parsedAddress {
+    number: n,
+    street: words[1:],
+    split: true,
+    city: strings.Join(addressWords(address.City), " "),
+    state: strings.ToUpper(strings.TrimSpace(address.State)),
+    zip: zip,
+  })
+}
+
+// Geocode street address.  The benchmark is ignored.
+func (g *AddressRangeGeocoder) LocationsFromBenchmarkContext(ctx context.Context, address, benchmark string) ([]Match, error) {
+  if strings.TrimSpace(address) == "" {
+    return []Match{}, ErrAddressRequired
+  }
+  if err := ctx.Err(); err != nil {
+    return []Match{}, err
+  }
+
+  r, _ := g.geocodeString(address)
+  return r, nil
+}
+
+// Add geography layers from boundaries to matches.
+func (g *AddressRangeGeocoder) addGeographies(matches []Match, layers []string) []Match {
+  for i := range(matches) {
+    matches[i].Geographies = g.Boundaries.Lookup(matches[i].Coordinates, layers...)
+  }
+  return matches
+}
+
+// Geocode street address, then get geography layers from boundaries.
+// The benchmark and vintage are ignored.
+//
+// Returns [ErrNotSupported] if Boundaries is nil.
+func (g *AddressRangeGeocoder) GeographiesContext(ctx context.Context, address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  if g.Boundaries == nil {
+    return []Match{}, ErrNotSupported
+  }
+
+  r, err := g.LocationsFromBenchmarkContext(ctx, address, benchmark)
+  if err != nil {
+    return []Match{}, err
+  }
+
+  return g.addGeographies(r, layers), nil
+}
+
+// Geocode structured address.  The benchmark is ignored.
+func (g *AddressRangeGeocoder) AddressLocationsFromBenchmarkContext(ctx context.Context, address Address, benchmark string) ([]Match, error) {
+  if strings.TrimSpace(address.Street) == "" {
+    return []Match{}, ErrAddressRequired
+  }
+  if err := ctx.Err(); err != nil {
+    return []Match{}, err
+  }
+
+  r, _ := g.geocodeAddress(address)
+  return r, nil
+}
+
+// Geocode structured address, then get geography layers from
+// boundaries.  The benchmark and vintage are ignored.
+//
+// Returns [ErrNotSupported] if Boundaries is nil.
+func (g *AddressRangeGeocoder) AddressGeographiesContext(ctx context.Context, address Address, benchmark, vintage string, layers ...string) ([]Match, error) {
+  if g.Boundaries == nil {
+    return []Match{}, ErrNotSupported
+  }
+
+  r, err := g.AddressLocationsFromBenchmarkContext(ctx, address, benchmark)
+  if err != nil {
+    return []Match{}, err
+  }
+
+  return g.addGeographies(r, layers), nil
+}
+
+// Get geography layers for coordinates from boundaries.  The benchmark
+// and vintage are ignored.
+//
+// Returns [ErrNotSupported] if Boundaries is nil.
+func (g *AddressRangeGeocoder) GeographiesFromCoordinatesContext(ctx context.Context, coords Coordinates, benchmark, vintage string, layers ...string) (GeographyLayers, error) {
+  if g.Boundaries == nil {
+    return GeographyLayers{}, ErrNotSupported
+  }
+
+  return g.Boundaries.GeographiesFromCoordinatesContext(ctx, coords, benchmark, vintage, layers...)
+}
+
+// Batch geocode street addresses.  The benchmark is ignored.
+//
+// Rows with exactly one match are matched; rows with several matches
+// (a tie) or no matches are not.  Matches are exact if the row zip code
+// matches the address range zip code.
+func (g *AddressRangeGeocoder) BatchLocationsFromBenchmarkContext(ctx context.Context, rows []BatchInputRow, benchmark string) ([]BatchOutputRow, error) {
+  r := make([]BatchOutputRow, len(rows))
+  for i, row := range(rows) {
+    if err := ctx.Err(); err != nil {
+      return []BatchOutputRow{}, err
+    }
+
+    out := BatchOutputRow {
+      Id: row.Id,
+      InputAddress: strings.Join([]string { row.Address, row.City, row.State, row.Zip }, ", "),
+    }
+
+    matches, exact := g.geocodeAddress(Address {
+      Street: row.Address,
+      City: row.City,
+      State: row.State,
+      Zip: row.Zip,
+    })
+    if len(matches) == 1 {
+      m := matches[0]
+      out.Match = true
+      out.Exact = exact
+      out.MatchAddress = m.MatchedAddress
+      out.Coordinates = m.Coordinates
+      out.TigerLine = m.TigerLine
+    }
+
+    r[i] = out
+  }
+
+  return r, nil
+}
+
+// Batch geocode street addresses, then get state, county, tract, and
+// block of matched rows from the census blocks layer of the boundaries
+// (see [GeographyLayers.Blocks()]).  The benchmark and vintage are
+// ignored.
+//
+// Returns [ErrNotSupported] if Boundaries is nil.
+func (g *AddressRangeGeocoder) BatchGeographiesContext(ctx context.Context, rows []BatchInputRow, benchmark, vintage string) ([]BatchOutputRow, error) {
+  if g.Boundaries == nil {
+    return []BatchOutputRow{}, ErrNotSupported
+  }
+
+  r, err := g.BatchLocationsFromBenchmarkContext(ctx, rows, benchmark)
+  if err != nil {
+    return []BatchOutputRow{}, err
+  }
+
+  for i, row := range(r) {
+    if !row.Match {
+      continue
+    }
+
+    blocks, err := g.Boundaries.Lookup(row.Coordinates).Blocks()
+    if err != nil {
+      return []BatchOutputRow{}, err
+    }
+    if len(blocks) > 0 {
+      b := blocks[0]
+      r[i].State, r[i].County, r[i].Tract, r[i].Block = b.State, b.County, b.Tract, b.Block
+    }
+  }
+
+  return r, nil
+}
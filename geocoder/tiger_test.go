@@ -0,0 +1,404 @@
+package geocoder
+
+import (
+  "bytes"
+  "context"
+  "errors"
+  "math"
+  "reflect"
+  "testing"
+)
+
+// test ADDRFEAT file
+const testAddrFeatPath = "testdata/tiger/addrfeat.geojson"
+
+// Load test address range geocoder.
+func newTestAddressRangeGeocoder(t *testing.T) *AddressRangeGeocoder {
+  g, err := LoadAddressRangeGeocoder(testAddrFeatPath)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return g
+}
+
+// Are coordinates within 1e-9 degrees of each other?
+func nearCoordinates(a, b Coordinates) bool {
+  return math.Abs(a.X - b.X) < 1e-9 && math.Abs(a.Y - b.Y) < 1e-9
+}
+
+func TestLoadAddressRanges(t *testing.T) {
+  ranges, err := LoadAddressRanges(testAddrFeatPath)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // note: left side of Oak Ln has non-numeric house numbers
+  if len(ranges) != 9 {
+    t.Fatalf("got %d ranges, exp 9", len(ranges))
+  }
+
+  exp := AddressRange {
+    TigerLineId: "300",
+    Street: "Oak Ln",
+    Side: "R",
+    From: 1,
+    To: 99,
+    Parity: "B",
+    Zip: "20001",
+    Line: []Coordinates {
+      { X: -77.0, Y: 38.9 },
+      { X: -77.0, Y: 38.91 },
+      { X: -77.0, Y: 38.91 },
+      { X: -77.0, Y: 38.92 },
+    },
+  }
+  if got := ranges[8]; !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestReadAddressRanges(t *testing.T) {
+  shp, dbf := writeTestShapefile(shapePolyline, []testFeature {{
+    parts: [][]Coordinates {{ { X: 0, Y: 0 }, { X: 0, Y: 1 } }},
+    props: map[string]string {
+      "TLID": "1",
+      "FULLNAME": "Elm St",
+      "LFROMHN": "2",
+      "LTOHN": "10",
+      "ZIPL": "12345",
+      "PARITYL": "E",
+      "RFROMHN": "",
+      "RTOHN": "",
+      "ZIPR": "",
+      "PARITYR": "",
+    },
+  }})
+
+  got, err := ReadAddressRanges(bytes.NewReader(shp), bytes.NewReader(dbf))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  exp := []AddressRange {{
+    TigerLineId: "1",
+    Street: "Elm St",
+    Side: "L",
+    From: 2,
+    To: 10,
+    Parity: "E",
+    Zip: "12345",
+    Line: []Coordinates { { X: 0, Y: 0 }, { X: 0, Y: 1 } },
+  }}
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestAddressRangeContains(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    r AddressRange // address range
+    n int // house number
+    exp bool // expected result
+  } {
+    { "even", AddressRange { From: 100, To: 198 }, 150, true },
+    { "even odd", AddressRange { From: 100, To: 198 }, 151, false },
+    { "odd", AddressRange { From: 101, To: 199 }, 151, true },
+    { "odd even", AddressRange { From: 101, To: 199 }, 150, false },
+    { "reversed", AddressRange { From: 198, To: 100 }, 100, true },
+    { "below", AddressRange { From: 100, To: 198 }, 98, false },
+    { "above", AddressRange { From: 100, To: 198 }, 200, false },
+    { "mixed", AddressRange { From: 100, To: 199 }, 151, true },
+    { "both", AddressRange { From: 100, To: 198, Parity: "B" }, 151, true },
+    { "explicit odd", AddressRange { From: 100, To: 198, Parity: "O" }, 150, false },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      if got := test.r.contains(test.n); got != test.exp {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestAddressRangeInterpolate(t *testing.T) {
+  line := []Coordinates { { X: 0, Y: 0 }, { X: 0, Y: 1 }, { X: 1, Y: 1 } }
+
+  tests := []struct {
+    name string // test name
+    r AddressRange // address range
+    n int // house number
+    exp Coordinates // expected result
+  } {
+    { "start", AddressRange { From: 0, To: 100, Line: line }, 0, line[0] },
+    { "end", AddressRange { From: 0, To: 100, Line: line }, 100, line[2] },
+    { "first segment", AddressRange { From: 0, To: 100, Line: line }, 25, Coordinates { X: 0, Y: 0.5 } },
+    { "second segment", AddressRange { From: 0, To: 100, Line: line }, 75, Coordinates { X: 0.5, Y: 1 } },
+    { "reversed", AddressRange { From: 100, To: 0, Line: line }, 75, Coordinates { X: 0, Y: 0.5 } },
+    { "single number", AddressRange { From: 10, To: 10, Line: line }, 10, line[1] },
+    { "empty", AddressRange { From: 0, To: 100 }, 50, Coordinates{} },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      if got := test.r.interpolate(test.n); !nearCoordinates(got, test.exp) {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestNormalizeStreet(t *testing.T) {
+  tests := []struct {
+    val, exp string
+  } {
+    { "Silver Hill Rd", "SILVER HILL RD" },
+    { "silver hill road", "SILVER HILL RD" },
+    { "North Main Street", "N MAIN ST" },
+    { "n. main st.", "N MAIN ST" },
+    { "  Pennsylvania   Avenue Northwest ", "PENNSYLVANIA AVE NW" },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.val, func(t *testing.T) {
+      if got := normalizeStreet(test.val); got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestParseAddress(t *testing.T) {
+  tests := []struct {
+    val string // address
+    exp parsedAddress // expected result
+    ok bool // expected success
+  } {{
+    val: "4600 Silver Hill Rd, Washington, DC 20233",
+    exp: parsedAddress { 4600, []string { "SILVER", "HILL", "RD" }, true, "WASHINGTON", "DC", "20233" },
+    ok: true,
+  }, {
+    val: "4600 silver hill rd washington dc 20233-0001",
+    exp: parsedAddress { 4600, []string { "SILVER", "HILL", "RD", "WASHINGTON", "DC" }, false, "", "", "20233" },
+    ok: true,
+  }, {
+    val: "4600 silver hill rd, 20233",
+    exp: parsedAddress { 4600, []string { "SILVER", "HILL", "RD" }, true, "", "", "20233" },
+    ok: true,
+  }, {
+    val: "silver hill rd",
+  }, {
+    val: "4600",
+  }, {
+    val: "4600 20233",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.val, func(t *testing.T) {
+      got, ok := parseAddress(test.val)
+      if ok != test.ok {
+        t.Fatalf("got %v, exp %v", ok, test.ok)
+      }
+      if ok && !reflect.DeepEqual(got, test.exp) {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestAddressRangeGeocoderLocations(t *testing.T) {
+  g := newTestAddressRangeGeocoder(t)
+
+  tests := []struct {
+    val string // address
+    exp []string // expected matched addresses
+    tiger []TigerLine // expected TIGER/Line edges
+  } {{
+    val: "4600 Silver Hill Rd, Washington, DC 20233",
+    exp: []string { "4600 SILVER HILL RD, WASHINGTON, DC, 20233" },
+    tiger: []TigerLine { { "100", "L" } },
+  }, {
+    val: "4649 silver hill road washington dc 20233",
+    exp: []string { "4649 SILVER HILL RD, WASHINGTON, DC, 20233" },
+    tiger: []TigerLine { { "100", "R" } },
+  }, {
+    val: "4750 Silver Hill Rd, 20233",
+    exp: []string { "4750 SILVER HILL RD, 20746" },
+    tiger: []TigerLine { { "101", "L" } },
+  }, {
+    val: "150 North Main Street, Springfield, IL 62701",
+    exp: []string { "150 N MAIN ST, SPRINGFIELD, IL, 62701" },
+    tiger: []TigerLine { { "200", "L" } },
+  }, {
+    val: "150 N Main St",
+    exp: []string { "150 N MAIN ST, SPRINGFIELD, IL, 62701", "150 N MAIN ST, SPRINGFIELD, MA, 01103" },
+    tiger: []TigerLine { { "200", "L" }, { "201", "L" } },
+  }, {
+    val: "50 oak lane",
+    exp: []string { "50 OAK LN, 20001" },
+    tiger: []TigerLine { { "300", "R" } },
+  }, {
+    val: "9999 Silver Hill Rd",
+  }, {
+    val: "4600 Nowhere St, Washington, DC",
+  }, {
+    val: "Silver Hill Rd",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.val, func(t *testing.T) {
+      matches, err := g.LocationsFromBenchmarkContext(context.Background(), test.val, "")
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      got := []string{}
+      gotTiger := []TigerLine{}
+      for _, m := range(matches) {
+        got = append(got, m.MatchedAddress)
+        gotTiger = append(gotTiger, m.TigerLine)
+      }
+
+      exp, expTiger := test.exp, test.tiger
+      if exp == nil {
+        exp, expTiger = []string{}, []TigerLine{}
+      }
+      if !reflect.DeepEqual(got, exp) {
+        t.Fatalf("got %v, exp %v", got, exp)
+      }
+      if !reflect.DeepEqual(gotTiger, expTiger) {
+        t.Fatalf("got %v, exp %v", gotTiger, expTiger)
+      }
+    })
+  }
+}
+
+func TestAddressRangeGeocoderMatch(t *testing.T) {
+  g := newTestAddressRangeGeocoder(t)
+
+  matches, err := g.AddressLocationsFromBenchmarkContext(context.Background(), Address {
+    Street: "150 N Main St",
+    City: "Springfield",
+    State: "IL",
+    Zip: "62701-1234",
+  }, "")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(matches) != 1 {
+    t.Fatalf("got %d matches, exp 1", len(matches))
+  }
+  m := matches[0]
+
+  // check coordinates (range is 198 to 100)
+  expCoords := Coordinates { X: -89.65, Y: 39.80 + 0.01 * 48.0 / 98.0 }
+  if !nearCoordinates(m.Coordinates, expCoords) {
+    t.Fatalf("got %v, exp %v", m.Coordinates, expCoords)
+  }
+
+  // check address components
+  c := m.AddressComponents
+  got := []string { c.PreDirection, c.StreetName, c.SuffixType, c.SuffixDirection, c.FromAddress, c.ToAddress, c.City, c.State, c.Zip }
+  exp := []string { "N", "MAIN", "ST", "", "198", "100", "SPRINGFIELD", "IL", "62701" }
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
+
+func TestAddressRangeGeocoderBatch(t *testing.T) {
+  g := newTestAddressRangeGeocoder(t)
+
+  rows, err := g.BatchLocationsFromBenchmarkContext(context.Background(), []BatchInputRow {
+    { Id: "1", Address: "4600 Silver Hill Rd", City: "Washington", State: "DC", Zip: "20233" },
+    { Id: "2", Address: "150 N Main St" },
+    { Id: "3", Address: "150 N Main St", City: "Springfield", State: "MA", Zip: "01103" },
+    { Id: "4", Address: "4600 Silver Hill Rd", Zip: "99999" },
+    { Id: "5", Address: "nowhere" },
+  }, "")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  exp := []struct {
+    id string // row ID
+    match, exact bool // match and exact flags
+    tiger TigerLine // TIGER/Line edge
+  } {
+    { "1", true, true, TigerLine { "100", "L" } },
+    { "2", false, false, TigerLine{} },
+    { "3", true, true, TigerLine { "201", "L" } },
+    { "4", true, false, TigerLine { "100", "L" } },
+    { "5", false, false, TigerLine{} },
+  }
+  if len(rows) != len(exp) {
+    t.Fatalf("got %d rows, exp %d", len(rows), len(exp))
+  }
+  for i, row := range(rows) {
+    if row.Id != exp[i].id || row.Match != exp[i].match || row.Exact != exp[i].exact || row.TigerLine != exp[i].tiger {
+      t.Fatalf("%d: got %v, exp %v", i, row, exp[i])
+    }
+  }
+
+  if exp := "4600 Silver Hill Rd, Washington, DC, 20233"; rows[0].InputAddress != exp {
+    t.Fatalf("got %q, exp %q", rows[0].InputAddress, exp)
+  }
+}
+
+func TestAddressRangeGeocoderFail(t *testing.T) {
+  g := newTestAddressRangeGeocoder(t)
+  canceled, cancel := context.WithCancel(context.Background())
+  cancel()
+
+  tests := []struct {
+    name string // test name
+    fn func() error // function to call
+    exp error // expected error
+  } {
+    { "empty address", func() error {
+      _, err := g.LocationsFromBenchmarkContext(context.Background(), "", "")
+      return err
+    }, ErrAddressRequired },
+    { "empty structured address", func() error {
+      _, err := g.AddressLocationsFromBenchmarkContext(context.Background(), Address {}, "")
+      return err
+    }, ErrAddressRequired },
+    { "canceled", func() error {
+      _, err := g.LocationsFromBenchmarkContext(canceled, "4600 Silver Hill Rd", "")
+      return err
+    }, context.Canceled },
+    { "canceled batch", func() error {
+      _, err := g.BatchLocationsFromBenchmarkContext(canceled, []BatchInputRow { { Id: "1" } }, "")
+      return err
+    }, context.Canceled },
+    { "geographies", func() error {
+      _, err := g.GeographiesContext(context.Background(), "4600 Silver Hill Rd", "", "")
+      return err
+    }, ErrNotSupported },
+    { "address geographies", func() error {
+      _, err := g.AddressGeographiesContext(context.Background(), Address { Street: "4600 Silver Hill Rd" }, "", "")
+      return err
+    }, ErrNotSupported },
+    { "coordinates", func() error {
+      _, err := g.GeographiesFromCoordinatesContext(context.Background(), Coordinates{}, "", "")
+      return err
+    }, ErrNotSupported },
+    { "batch geographies", func() error {
+      _, err := g.BatchGeographiesContext(context.Background(), nil, "", "")
+      return err
+    }, ErrNotSupported },
+    { "missing file", func() error {
+      _, err := LoadAddressRangeGeocoder("testdata/tiger/missing.geojson")
+      return err
+    }, nil },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      err := test.fn()
+      if err == nil || (test.exp != nil && !errors.Is(err, test.exp)) {
+        t.Fatalf("got %v, exp %v", err, test.exp)
+      }
+    })
+  }
+}
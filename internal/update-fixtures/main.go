@@ -0,0 +1,257 @@
+// Regenerate test fixtures from the live Census geocoder API.
+//
+// Run from the root of the repository:
+//
+//   go run ./internal/update-fixtures
+//
+// This tool does the following:
+//
+//   1. Fetches raw API responses and writes them to
+//      geocoder/geocodertest/fixtures.
+//   2. Writes the decoded results that tests expect to
+//      geocoder/testdata/data.
+//   3. Runs the geocoder tests with -record and -update to record
+//      geocoder/testdata/cassettes/live.json and regenerate the golden
+//      files in geocoder/testdata/golden.
+package main
+
+import (
+  "encoding/json"
+  "flag"
+  "fmt"
+  "log"
+  "os"
+  "os/exec"
+  "path/filepath"
+
+  "pablotron.org/census-geocoder/geocoder"
+  "pablotron.org/census-geocoder/geocoder/cassette"
+)
+
+// fixture directories, relative to the repository root
+const (
+  fixturesDir = "geocoder/geocodertest/fixtures"
+  dataDir = "geocoder/testdata/data"
+)
+
+// test address
+const testAddress = "4600 Silver Hill Rd, Washington, DC 20233"
+
+// test structured address
+var testStructuredAddress = geocoder.Address {
+  Street: "4600 Silver Hill Rd",
+  City: "Washington",
+  State: "DC",
+  Zip: "20233",
+}
+
+// test coordinates
+var testCoordinates = geocoder.Coordinates { X: -77.19902696904677, Y: 38.88701576684785 }
+
+// Decoded output written to testdata/data.
+type output struct {
+  path string // output path, relative to data directory
+  keys []string // JSON keys of decoded value, or nil for the raw body
+}
+
+// Fixture fetched from the live API.
+type fixture struct {
+  name string // fixture file name, or empty for data-only fixtures
+  fetch func(geocoder.Client) error // send request
+  outputs []output // decoded outputs
+}
+
+// Read batch input rows from testdata/data/batch-input.csv.
+//
+// The header row is uploaded as a regular row, so the responses
+// contain the echoed header row like the existing fixtures do.
+func batchInput() ([]geocoder.BatchInputRow, error) {
+  f, err := os.Open(filepath.Join(dataDir, "batch-input.csv"))
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+
+  return geocoder.NewBatchInputReader(f).ReadAll()
+}
+
+// Create batch fetch function with given benchmark and vintage.
+//
+// If vintage is empty, then batch locations are fetched.
+func fetchBatch(benchmark, vintage string) func(geocoder.Client) error {
+  return func(c geocoder.Client) error {
+    rows, err := batchInput()
+    if err != nil {
+      return err
+    }
+
+    if vintage == "" {
+      _, err = c.BatchLocationsFromBenchmark(rows, benchmark)
+    } else {
+      _, err = c.BatchGeographies(rows, benchmark, vintage)
+    }
+    return err
+  }
+}
+
+// fixtures
+var fixtures = []fixture {{
+  name: "benchmarks.json",
+  fetch: func(c geocoder.Client) error {
+    _, err := c.Benchmarks()
+    return err
+  },
+  outputs: []output {{ "benchmarks.json", []string { "benchmarks" } }},
+}, {
+  name: "vintages.json",
+  fetch: func(c geocoder.Client) error {
+    _, err := c.Vintages("4")
+    return err
+  },
+  outputs: []output {{ "vintages.json", []string { "vintages" } }},
+}, {
+  name: "locations.json",
+  fetch: func(c geocoder.Client) error {
+    _, err := c.LocationsFromBenchmark(testAddress, "2020")
+    return err
+  },
+  outputs: []output {{ "locations.json", []string { "result", "addressMatches" } }},
+}, {
+  name: "geographies.json",
+  fetch: func(c geocoder.Client) error {
+    _, err := c.Geographies("4600 silver hill rd, 20233", "2020", "2010")
+    return err
+  },
+  outputs: []output {{ "geographies.json", []string { "result", "addressMatches" } }},
+}, {
+  name: "address-locations.json",
+  fetch: func(c geocoder.Client) error {
+    _, err := c.AddressLocationsFromBenchmark(testStructuredAddress, "2020")
+    return err
+  },
+}, {
+  name: "address-geographies.json",
+  fetch: func(c geocoder.Client) error {
+    _, err := c.AddressGeographies(testStructuredAddress, "2020", "2010")
+    return err
+  },
+}, {
+  name: "coordinates.json",
+  fetch: func(c geocoder.Client) error {
+    _, err := c.GeographiesFromCoordinates(testCoordinates, "2020", "2020")
+    return err
+  },
+  outputs: []output {{ "coordinates.json", []string { "result", "geographies" } }},
+}, {
+  fetch: func(c geocoder.Client) error {
+    _, err := c.Geographies("2525 buckelew dr falls church va 22046", "2020", "2020")
+    return err
+  },
+  outputs: []output {{ "2525-buckelew-geographies-2020-2020.json", nil }},
+}, {
+  name: "batch-locations-2020.csv",
+  fetch: fetchBatch("2020", ""),
+  outputs: []output {{ "batch-output-locations-2020.csv", nil }},
+}, {
+  name: "batch-geographies-2020-2020.csv",
+  fetch: fetchBatch("2020", "2020"),
+  outputs: []output {{ "batch-output-geographies-2020-2020.csv", nil }},
+}, {
+  fetch: fetchBatch("4", "4"),
+  outputs: []output {{ "batch-output-geographies-4-4.csv", nil }},
+}}
+
+// Get JSON value at given keys from JSON document.
+//
+// The raw value is returned so key order and number formatting match
+// the API response.
+func extract(body []byte, keys []string) ([]byte, error) {
+  for _, key := range(keys) {
+    var m map[string]json.RawMessage
+    if err := json.Unmarshal(body, &m); err != nil {
+      return nil, err
+    }
+
+    val, ok := m[key]
+    if !ok {
+      return nil, fmt.Errorf("missing key: %s", key)
+    }
+    body = val
+  }
+
+  return body, nil
+}
+
+// Fetch fixture, then write fixture file and decoded outputs.
+func update(f fixture) error {
+  // create client which records responses
+  r, err := cassette.New("", cassette.ModeRecord)
+  if err != nil {
+    return err
+  }
+  c := geocoder.NewClient(
+    geocoder.WithRetryPolicy(geocoder.DefaultRetryPolicy),
+    geocoder.WithLimiter(geocoder.DefaultLimiter),
+  )
+  c.Client.Transport = r
+
+  // send request
+  if err := f.fetch(c); err != nil {
+    return err
+  }
+
+  // get body of last (successful) response
+  ias := r.Interactions()
+  if len(ias) == 0 {
+    return fmt.Errorf("no response")
+  }
+  body := []byte(ias[len(ias) - 1].Response.Body)
+
+  // write fixture
+  if f.name != "" {
+    path := filepath.Join(fixturesDir, f.name)
+    log.Print(path)
+    if err := os.WriteFile(path, body, 0644); err != nil {
+      return err
+    }
+  }
+
+  // write decoded outputs
+  for _, o := range(f.outputs) {
+    data, err := extract(body, o.keys)
+    if err != nil {
+      return fmt.Errorf("%s: %w", o.path, err)
+    }
+
+    path := filepath.Join(dataDir, o.path)
+    log.Print(path)
+    if err := os.WriteFile(path, data, 0644); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+func main() {
+  skipTests := flag.Bool("skip-tests", false, "do not record cassettes or update golden files")
+  flag.Parse()
+
+  // fetch fixtures
+  for _, f := range(fixtures) {
+    if err := update(f); err != nil {
+      log.Fatal(err)
+    }
+  }
+
+  if *skipTests {
+    return
+  }
+
+  // record cassette and update golden files
+  cmd := exec.Command("go", "test", "-count=1", "./geocoder", "-record", "-update")
+  cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+  if err := cmd.Run(); err != nil {
+    log.Fatal(err)
+  }
+}
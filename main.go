@@ -1,24 +1,44 @@
-// minimal address geocoder
+// Command-line interface for the Census geocoder.
+//
+// Usage:
+//
+//   census-geocoder <command> [flags] [args...]
+//
+// Commands:
+//
+//   benchmarks             list available benchmarks
+//   vintages <benchmark>   list vintages for benchmark
+//   locate [address...]    geocode addresses
+//   geographies [address...]
+//                          geocode addresses and get geography layers
+//   reverse [x,y...]       get geography layers for coordinates
+//   batch [file.csv]       batch geocode addresses from CSV file
+//
+// The locate, geographies, and reverse commands read inputs from
+// standard input (one per line) if no arguments are given.  The batch
+// command reads CSV from standard input if no file is given.
+//
+// Use "--" before coordinates with a negative X value (e.g.
+// "census-geocoder reverse -- -77.199,38.887").
+//
+// Exit codes:
+//
+//   0  all inputs matched
+//   1  error
+//   2  no inputs matched
+//   3  some inputs matched
 package main
 
 import (
-  "fmt"
-  "log"
+  "context"
   "os"
-  "pablotron.org/census-geocoder/geocoder"
+  "os/signal"
 )
 
 func main() {
-  for _, arg := range(os.Args[1:]) {
-    // get address matches
-    matches, err := geocoder.Locations(arg)
-    if err != nil {
-      log.Fatal(err)
-    }
+  // cancel requests on interrupt
+  ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+  defer stop()
 
-    // print matches
-    for _, match := range(matches) {
-      fmt.Println(match)
-    }
-  }
+  os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+  "bytes"
+  "context"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "strings"
+  "testing"
+  "pablotron.org/census-geocoder/geocoder"
+)
+
+// Start server which serves fixture responses from the geocoder
+// package test data.
+//
+// Single address requests for addresses containing "nowhere" return no
+// matches.
+func newTestServer(t *testing.T) *httptest.Server {
+  endpoints := map[string]string {
+    "/benchmarks": "benchmarks.json",
+    "/vintages": "vintages.json",
+    "/locations/onelineaddress": "locations.json",
+    "/geographies/onelineaddress": "geographies.json",
+    "/geographies/coordinates": "coordinates.json",
+    "/locations/addressbatch": "batch-locations-2020.csv",
+    "/geographies/addressbatch": "batch-geographies-2020-2020.csv",
+  }
+
+  mux := http.NewServeMux()
+  for urlPath, name := range(endpoints) {
+    data, err := os.ReadFile("geocoder/geocodertest/fixtures/" + name)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    mux.HandleFunc(urlPath, func(w http.ResponseWriter, r *http.Request) {
+      if strings.Contains(r.URL.Query().Get("address"), "nowhere") {
+        w.Write([]byte(`{"result":{"addressMatches":[]}}`))
+        return
+      }
+
+      w.Write(data)
+    })
+  }
+
+  return httptest.NewServer(mux)
+}
+
+func TestRun(t *testing.T) {
+  ms := newTestServer(t)
+  defer ms.Close()
+
+  tests := []struct {
+    name string // test name
+    args []string // command-line arguments
+    stdin string // standard input
+    exp int // expected exit code
+    out string // expected substring of standard output
+  } {
+    { "no command", []string {}, "", exitError, "" },
+    { "unknown command", []string { "bogus" }, "", exitError, "" },
+    { "bad flag", []string { "locate", "-bogus" }, "", exitError, "" },
+    { "benchmarks", []string { "benchmarks" }, "", exitOk, "Public_AR_Current" },
+    { "vintages", []string { "vintages", "4" }, "", exitOk, "Census2010_Current" },
+    { "benchmarks json", []string { "benchmarks", "-format", "json" }, "", exitOk, `"benchmarkName": "Public_AR_Current"` },
+    { "vintages csv", []string { "vintages", "-format", "csv", "-columns", "name", "4" }, "", exitOk, "name\nCurrent_Current\n" },
+    { "benchmarks geojson", []string { "benchmarks", "-format", "geojson" }, "", exitError, "" },
+    { "locate", []string { "locate", "4600 silver hill rd" }, "", exitOk, "4600 SILVER HILL RD" },
+    { "locate stdin", []string { "locate" }, "4600 silver hill rd\n\n", exitOk, "4600 SILVER HILL RD" },
+    { "locate no match", []string { "locate", "1 nowhere ln" }, "", exitNoMatch, "" },
+    { "locate partial", []string { "locate" }, "4600 silver hill rd\n1 nowhere ln\n", exitPartial, "4600 SILVER HILL RD" },
+    { "geographies", []string { "geographies", "-layers", "Counties", "4600 silver hill rd" }, "", exitOk, "4600 SILVER HILL RD" },
+    { "geographies json", []string { "geographies", "-format", "json", "4600 silver hill rd" }, "", exitOk, `"Census Tracts"` },
+    { "reverse", []string { "reverse", "--", "-77.199,38.887" }, "", exitOk, "510594714011007" },
+    { "reverse bad coordinates", []string { "reverse", "north" }, "", exitError, "" },
+    { "batch", []string { "batch", "geocoder/testdata/data/batch-input.csv" }, "", exitOk, "2525 BUCKELEW DR" },
+    { "batch geographies", []string { "batch", "-vintage", "2020" }, "id,address,city,state,zip\n2022,2525 buckelew dr,falls church,va,22046\n", exitOk, "2525 BUCKELEW DR" },
+    { "batch missing file", []string { "batch", "does-not-exist.csv" }, "", exitError, "" },
+    { "batch csv", []string { "batch", "-format", "csv", "-columns", "id,tract", "-vintage", "2020", "geocoder/testdata/data/batch-input.csv" }, "", exitOk, "id,tract\n2022,471401\n" },
+    { "locate ndjson", []string { "locate", "-format", "ndjson", "-columns", "matched_address", "4600 silver hill rd" }, "", exitOk, `{"matched_address":"4600 SILVER HILL RD, WASHINGTON, DC, 20233"}` },
+    { "batch geojson", []string { "batch", "-format", "geojson", "geocoder/testdata/data/batch-input.csv" }, "", exitOk, `"type": "FeatureCollection"` },
+    { "batch kml", []string { "batch", "-format", "kml", "geocoder/testdata/data/batch-input.csv" }, "", exitOk, "<Placemark>" },
+    { "locate gpx", []string { "locate", "-format", "gpx", "4600 silver hill rd" }, "", exitOk, "<wpt " },
+    { "bad format", []string { "locate", "-format", "xml", "4600 silver hill rd" }, "", exitError, "" },
+    { "bad column", []string { "batch", "-columns", "bogus", "geocoder/testdata/data/batch-input.csv" }, "", exitError, "" },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      var stdout, stderr bytes.Buffer
+
+      // run command with test server URL
+      args := test.args
+      if len(args) > 0 {
+        args = append([]string { args[0], "-url", ms.URL }, args[1:]...)
+      }
+      got := run(context.Background(), args, strings.NewReader(test.stdin), &stdout, &stderr)
+
+      // check exit code
+      if got != test.exp {
+        t.Fatalf("got %d, exp %d (stderr: %s)", got, test.exp, stderr.String())
+      }
+
+      // check output
+      if !strings.Contains(stdout.String(), test.out) {
+        t.Fatalf("got %q, exp substring %q", stdout.String(), test.out)
+      }
+    })
+  }
+}
+
+func TestReadBatchRows(t *testing.T) {
+  br := geocoder.NewBatchInputReader(strings.NewReader("1,a,b,c,d\n2,a,b,c,d\n3,a,b,c,d\n"))
+
+  // read groups of at most 2 rows
+  for _, exp := range([]int { 2, 1 }) {
+    rows, err := readBatchRows(br, 2)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if len(rows) != exp {
+      t.Fatalf("got %d rows, exp %d", len(rows), exp)
+    }
+  }
+
+  // check for EOF
+  if _, err := readBatchRows(br, 2); err != io.EOF {
+    t.Fatalf("got %v, exp io.EOF", err)
+  }
+}
+
+func TestRunCache(t *testing.T) {
+  ms := newTestServer(t)
+  dir := t.TempDir()
+
+  for _, name := range([]string { "miss", "hit" }) {
+    var stdout, stderr bytes.Buffer
+    args := []string { "locate", "-url", ms.URL, "-cache", dir, "4600 silver hill rd" }
+    if got := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr); got != exitOk {
+      t.Fatalf("%s: got %d, exp %d (stderr: %s)", name, got, exitOk, stderr.String())
+    }
+
+    if !strings.Contains(stdout.String(), "4600 SILVER HILL RD") {
+      t.Fatalf("%s: got %q, exp match", name, stdout.String())
+    }
+
+    // stop server so the second run must use the cache
+    ms.Close()
+  }
+}